@@ -0,0 +1,49 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"tempo-otlp-trace-demo/models"
+)
+
+// Analyzer diagnoses the slowest spans of a trace from an analysis bundle
+type Analyzer interface {
+	// Name returns the backend name reported in diagnoses
+	Name() string
+	// Analyze returns a structured diagnosis for the bundle
+	Analyze(ctx context.Context, bundle *models.AnalysisBundle) (*models.Diagnosis, error)
+}
+
+// Backend names accepted by New
+const (
+	BackendRules  = "rules"
+	BackendOpenAI = "openai"
+)
+
+// New creates the analyzer backend with the given name.
+// An empty name selects the backend configured by ANALYZER_BACKEND, defaulting to the rule-based one.
+func New(name string) (Analyzer, error) {
+	if name == "" {
+		name = getEnv("ANALYZER_BACKEND", BackendRules)
+	}
+
+	switch strings.ToLower(name) {
+	case BackendRules:
+		return NewRuleBased(), nil
+	case BackendOpenAI:
+		return NewOpenAIFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown analyzer backend: %s", name)
+	}
+}
+
+// getEnv gets environment variable with default value
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,163 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"tempo-otlp-trace-demo/models"
+	"time"
+)
+
+const systemPrompt = `You are a performance engineer analyzing an OpenTelemetry trace.
+You receive the slowest spans of the trace ranked by self-time, each with its source code,
+attributes, parent chain and child timings, plus the critical path.
+Reply with a single JSON object with the fields:
+"suspected_cause" (string), "suggested_fix" (string), "confidence" (number between 0 and 1) and
+"evidence_spans" (array of objects with "span_id", "span_name" and "reason").`
+
+// OpenAI is an analyzer backed by an OpenAI-compatible chat completions API
+type OpenAI struct {
+	BaseURL     string
+	APIKey      string
+	Model       string
+	Temperature *float64 // nil leaves the provider default; some models reject any explicit value
+	Client      *http.Client
+}
+
+// NewOpenAIFromEnv creates an OpenAI-compatible analyzer configured from
+// OPENAI_BASE_URL, OPENAI_API_KEY, OPENAI_MODEL and OPENAI_TEMPERATURE
+func NewOpenAIFromEnv() (*OpenAI, error) {
+	baseURL := getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1")
+	apiKey := getEnv("OPENAI_API_KEY", "")
+	if apiKey == "" && strings.HasPrefix(baseURL, "https://api.openai.com") {
+		return nil, fmt.Errorf("OPENAI_API_KEY is required for %s", baseURL)
+	}
+
+	var temperature *float64
+	if value := getEnv("OPENAI_TEMPERATURE", ""); value != "" {
+		t, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OPENAI_TEMPERATURE %q: %w", value, err)
+		}
+		temperature = &t
+	}
+
+	return &OpenAI{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		APIKey:      apiKey,
+		Model:       getEnv("OPENAI_MODEL", "gpt-4o-mini"),
+		Temperature: temperature,
+		Client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}, nil
+}
+
+// Name returns the backend name
+func (a *OpenAI) Name() string {
+	return BackendOpenAI
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model          string            `json:"model"`
+	Messages       []chatMessage     `json:"messages"`
+	Temperature    *float64          `json:"temperature,omitempty"`
+	ResponseFormat map[string]string `json:"response_format"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// Analyze sends the bundle to the chat completions endpoint and decodes the JSON diagnosis
+func (a *OpenAI) Analyze(ctx context.Context, bundle *models.AnalysisBundle) (*models.Diagnosis, error) {
+	bundleJSON, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bundle: %w", err)
+	}
+
+	body, err := json.Marshal(chatRequest{
+		Model: a.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: string(bundleJSON)},
+		},
+		Temperature:    a.Temperature,
+		ResponseFormat: map[string]string{"type": "json_object"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.APIKey)
+	}
+
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call LLM: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LLM response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("LLM returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var chat chatResponse
+	if err := json.Unmarshal(respBody, &chat); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM response: %w", err)
+	}
+	if len(chat.Choices) == 0 {
+		return nil, fmt.Errorf("LLM response has no choices")
+	}
+
+	var diagnosis models.Diagnosis
+	if err := json.Unmarshal([]byte(stripCodeFence(chat.Choices[0].Message.Content)), &diagnosis); err != nil {
+		return nil, fmt.Errorf("failed to parse diagnosis: %w", err)
+	}
+
+	diagnosis.TraceID = bundle.TraceID
+	diagnosis.Analyzer = a.Name()
+	if diagnosis.EvidenceSpans == nil {
+		diagnosis.EvidenceSpans = make([]models.EvidenceSpan, 0)
+	}
+	return &diagnosis, nil
+}
+
+// stripCodeFence removes a surrounding ```json fence some models add despite the JSON response format
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+	return strings.TrimSpace(content)
+}
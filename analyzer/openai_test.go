@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tempo-otlp-trace-demo/models"
+)
+
+// newChatServer starts a stand-in for an OpenAI-compatible chat completions API.
+// It records the decoded request body and answers with the given assistant content.
+func newChatServer(t *testing.T, content string, received *map[string]interface{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer test-key")
+		}
+		if err := json.NewDecoder(r.Body).Decode(received); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": content}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAIAnalyze(t *testing.T) {
+	content := "```json\n" + `{"suspected_cause":"injected delay","suggested_fix":"remove it","confidence":0.9,` +
+		`"evidence_spans":[{"span_id":"abc","span_name":"processPayment","reason":"slow.reason=simulated_delay"}]}` + "\n```"
+	var received map[string]interface{}
+	server := newChatServer(t, content, &received)
+
+	backend := &OpenAI{BaseURL: server.URL, APIKey: "test-key", Model: "test-model"}
+	diagnosis, err := backend.Analyze(context.Background(), &models.AnalysisBundle{TraceID: "trace-1"})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	if _, ok := received["temperature"]; ok {
+		t.Errorf("temperature sent although unset: %v", received["temperature"])
+	}
+	if received["model"] != "test-model" {
+		t.Errorf("model = %v, want test-model", received["model"])
+	}
+	if diagnosis.TraceID != "trace-1" || diagnosis.Analyzer != BackendOpenAI {
+		t.Errorf("diagnosis = %+v, want trace-1 from %s", diagnosis, BackendOpenAI)
+	}
+	if diagnosis.SuspectedCause != "injected delay" || len(diagnosis.EvidenceSpans) != 1 {
+		t.Errorf("diagnosis not decoded from fenced content: %+v", diagnosis)
+	}
+}
+
+func TestOpenAIAnalyzeSendsExplicitTemperature(t *testing.T) {
+	var received map[string]interface{}
+	server := newChatServer(t, `{"suspected_cause":"x","suggested_fix":"y","confidence":0.1}`, &received)
+
+	temperature := 0.0
+	backend := &OpenAI{BaseURL: server.URL, APIKey: "test-key", Temperature: &temperature}
+	diagnosis, err := backend.Analyze(context.Background(), &models.AnalysisBundle{})
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	if got, ok := received["temperature"]; !ok || got != 0.0 {
+		t.Errorf("temperature = %v (present %v), want 0", got, ok)
+	}
+	if diagnosis.EvidenceSpans == nil {
+		t.Error("EvidenceSpans is nil, want empty slice")
+	}
+}
+
+func TestOpenAIAnalyzeErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	backend := &OpenAI{BaseURL: server.URL}
+	_, err := backend.Analyze(context.Background(), &models.AnalysisBundle{})
+	if err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Fatalf("err = %v, want status 503", err)
+	}
+}
+
+func TestNewOpenAIFromEnvTemperature(t *testing.T) {
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:11434/v1")
+	t.Setenv("OPENAI_TEMPERATURE", "")
+
+	backend, err := NewOpenAIFromEnv()
+	if err != nil {
+		t.Fatalf("NewOpenAIFromEnv: %v", err)
+	}
+	if backend.Temperature != nil {
+		t.Errorf("Temperature = %v, want nil", *backend.Temperature)
+	}
+
+	t.Setenv("OPENAI_TEMPERATURE", "0.2")
+	backend, err = NewOpenAIFromEnv()
+	if err != nil {
+		t.Fatalf("NewOpenAIFromEnv: %v", err)
+	}
+	if backend.Temperature == nil || *backend.Temperature != 0.2 {
+		t.Errorf("Temperature = %v, want 0.2", backend.Temperature)
+	}
+
+	t.Setenv("OPENAI_TEMPERATURE", "warm")
+	if _, err := NewOpenAIFromEnv(); err == nil {
+		t.Error("expected error for invalid OPENAI_TEMPERATURE")
+	}
+}
@@ -0,0 +1,221 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"tempo-otlp-trace-demo/models"
+)
+
+// Rule flags a span matching a known slowness pattern
+type Rule struct {
+	Name       string
+	Confidence float64
+	// Match returns the evidence reason when the span matches the rule
+	Match func(span *models.BundleSpan) (string, bool)
+	// Cause and Fix describe the diagnosis for the first span matching the rule
+	Cause func(span *models.BundleSpan) string
+	Fix   func(span *models.BundleSpan) string
+}
+
+// RuleBased is a deterministic analyzer that matches spans against a fixed rule set.
+// It needs no network access, which makes the analyze endpoint testable offline.
+type RuleBased struct {
+	rules []Rule
+}
+
+// NewRuleBased creates a rule-based analyzer with the default rules, highest priority first
+func NewRuleBased() *RuleBased {
+	return &RuleBased{rules: defaultRules()}
+}
+
+// Name returns the backend name
+func (a *RuleBased) Name() string {
+	return BackendRules
+}
+
+// Analyze matches every bundled span against the rules.
+// The highest priority rule with a match determines the suspected cause and fix;
+// all matches are reported as evidence.
+func (a *RuleBased) Analyze(ctx context.Context, bundle *models.AnalysisBundle) (*models.Diagnosis, error) {
+	diagnosis := &models.Diagnosis{
+		TraceID:       bundle.TraceID,
+		Analyzer:      a.Name(),
+		EvidenceSpans: make([]models.EvidenceSpan, 0),
+	}
+
+	var matched bool
+	for _, rule := range a.rules {
+		for i := range bundle.Spans {
+			span := &bundle.Spans[i]
+			reason, ok := rule.Match(span)
+			if !ok {
+				continue
+			}
+
+			diagnosis.EvidenceSpans = append(diagnosis.EvidenceSpans, models.EvidenceSpan{
+				SpanID:   span.SpanID,
+				SpanName: span.SpanName,
+				SelfTime: span.SelfTime,
+				Reason:   reason,
+			})
+
+			if !matched {
+				matched = true
+				diagnosis.SuspectedCause = rule.Cause(span)
+				diagnosis.SuggestedFix = rule.Fix(span)
+				diagnosis.Confidence = rule.Confidence
+			}
+		}
+	}
+
+	if !matched {
+		diagnosis.SuspectedCause = "No known slowness pattern found in the analyzed spans"
+		diagnosis.SuggestedFix = "Inspect the source of the spans with the longest self-time"
+	}
+
+	return diagnosis, nil
+}
+
+func defaultRules() []Rule {
+	return []Rule{
+		{
+			Name:       "simulated_delay",
+			Confidence: 0.95,
+			Match: func(span *models.BundleSpan) (string, bool) {
+				if span.Attributes["slow.reason"] == "simulated_delay" {
+					return "slow.reason=simulated_delay", true
+				}
+				return "", false
+			},
+			Cause: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("%s contains an injected delay (slow.reason=simulated_delay) accounting for %s of self-time", span.SpanName, span.SelfTime)
+			},
+			Fix: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("Remove the simulated delay from %s or send the request without \"sleep\": true", functionOrSpanName(span))
+			},
+		},
+		{
+			Name:       "error_span",
+			Confidence: 0.8,
+			Match: func(span *models.BundleSpan) (string, bool) {
+				if reason, ok := span.Attributes["error.reason"]; ok {
+					return "error.reason=" + reason, true
+				}
+				if len(span.Exceptions) > 0 {
+					return "exception: " + span.Exceptions[0].Message, true
+				}
+				if span.Attributes["error"] == "true" || span.Attributes["otel.status_code"] == "ERROR" {
+					return "span status is error", true
+				}
+				return "", false
+			},
+			Cause: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("%s failed and its error path is among the slowest spans", span.SpanName)
+			},
+			Fix: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("Investigate the failure in %s and add retries with backoff or fail fast", functionOrSpanName(span))
+			},
+		},
+		{
+			Name:       "retries",
+			Confidence: 0.7,
+			Match: func(span *models.BundleSpan) (string, bool) {
+				if retries := countEvents(span, "retry"); retries > 0 {
+					return fmt.Sprintf("%d retry events", retries), true
+				}
+				return "", false
+			},
+			Cause: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("%s retried %d times; the failed attempts and their backoff account for much of its %s", span.SpanName, countEvents(span, "retry"), span.Duration)
+			},
+			Fix: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("Find out why the first attempts of %s fail, and cap its retries and backoff", functionOrSpanName(span))
+			},
+		},
+		{
+			Name:       "gc_pause",
+			Confidence: 0.5,
+			Match: func(span *models.BundleSpan) (string, bool) {
+				if pauses := countEvents(span, "gc.pause"); pauses > 0 {
+					return fmt.Sprintf("%d GC pause events", pauses), true
+				}
+				return "", false
+			},
+			Cause: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("%s was stalled by garbage collection pauses", span.SpanName)
+			},
+			Fix: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("Reduce allocations in %s or tune GOGC/GOMEMLIMIT", functionOrSpanName(span))
+			},
+		},
+		{
+			Name:       "slow_external_call",
+			Confidence: 0.6,
+			Match: func(span *models.BundleSpan) (string, bool) {
+				if url, ok := span.Attributes["http.url"]; ok && span.OnCriticalPath {
+					return "external call to " + url + " on the critical path", true
+				}
+				return "", false
+			},
+			Cause: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("%s waits on an external HTTP dependency (%s)", span.SpanName, span.Attributes["http.url"])
+			},
+			Fix: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("Add a timeout and caching around %s, or call it asynchronously", functionOrSpanName(span))
+			},
+		},
+		{
+			Name:       "slow_database_query",
+			Confidence: 0.6,
+			Match: func(span *models.BundleSpan) (string, bool) {
+				if system, ok := span.Attributes["db.system"]; ok && span.OnCriticalPath {
+					return system + " query on the critical path", true
+				}
+				return "", false
+			},
+			Cause: func(span *models.BundleSpan) string {
+				if statement := span.Attributes["db.statement"]; statement != "" {
+					return fmt.Sprintf("%s spends %s in a %s query: %s", span.SpanName, span.SelfTime, span.Attributes["db.system"], statement)
+				}
+				return fmt.Sprintf("%s spends %s in a %s query", span.SpanName, span.SelfTime, span.Attributes["db.system"])
+			},
+			Fix: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("Check the query plan and indexes used by %s, or batch and cache its results", functionOrSpanName(span))
+			},
+		},
+		{
+			Name:       "dominant_self_time",
+			Confidence: 0.4,
+			Match: func(span *models.BundleSpan) (string, bool) {
+				if span.Rank == 1 && span.OnCriticalPath {
+					return "longest self-time on the critical path", true
+				}
+				return "", false
+			},
+			Cause: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("%s has the longest self-time (%s) on the critical path", span.SpanName, span.SelfTime)
+			},
+			Fix: func(span *models.BundleSpan) string {
+				return fmt.Sprintf("Profile the work done directly in %s", functionOrSpanName(span))
+			},
+		},
+	}
+}
+
+func functionOrSpanName(span *models.BundleSpan) string {
+	if span.FunctionName != "" {
+		return span.FunctionName
+	}
+	return span.SpanName
+}
+
+// countEvents counts the span's events with the given name
+func countEvents(span *models.BundleSpan, name string) int {
+	count := 0
+	for _, event := range span.Events {
+		if event.Name == name {
+			count++
+		}
+	}
+	return count
+}
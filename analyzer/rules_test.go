@@ -0,0 +1,79 @@
+package analyzer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"tempo-otlp-trace-demo/models"
+)
+
+func TestRuleBasedSimulatedDelay(t *testing.T) {
+	bundle := &models.AnalysisBundle{
+		TraceID: "trace-1",
+		Spans: []models.BundleSpan{
+			{
+				Rank:           1,
+				SpanID:         "abc",
+				SpanName:       "processPayment",
+				SelfTime:       "5.00s",
+				OnCriticalPath: true,
+				FunctionName:   "processPayment",
+				Attributes:     map[string]string{"slow.reason": "simulated_delay"},
+			},
+		},
+	}
+
+	diagnosis, err := NewRuleBased().Analyze(context.Background(), bundle)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	if diagnosis.Confidence != 0.95 {
+		t.Errorf("Confidence = %v, want 0.95", diagnosis.Confidence)
+	}
+	if !strings.Contains(diagnosis.SuspectedCause, "processPayment") {
+		t.Errorf("SuspectedCause = %q, want it to name processPayment", diagnosis.SuspectedCause)
+	}
+	// simulated_delay and dominant_self_time both match; the former has priority
+	if len(diagnosis.EvidenceSpans) != 2 || diagnosis.EvidenceSpans[0].Reason != "slow.reason=simulated_delay" {
+		t.Errorf("EvidenceSpans = %+v", diagnosis.EvidenceSpans)
+	}
+}
+
+func TestRuleBasedNoMatch(t *testing.T) {
+	bundle := &models.AnalysisBundle{
+		TraceID: "trace-2",
+		Spans: []models.BundleSpan{
+			{Rank: 2, SpanID: "def", SpanName: "validateOrder", Attributes: map[string]string{}},
+		},
+	}
+
+	diagnosis, err := NewRuleBased().Analyze(context.Background(), bundle)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	if diagnosis.Confidence != 0 || len(diagnosis.EvidenceSpans) != 0 {
+		t.Errorf("diagnosis = %+v, want no evidence and zero confidence", diagnosis)
+	}
+	if !strings.HasPrefix(diagnosis.SuspectedCause, "No known slowness pattern") {
+		t.Errorf("SuspectedCause = %q", diagnosis.SuspectedCause)
+	}
+}
+
+func TestNewDefaultsToRules(t *testing.T) {
+	t.Setenv("ANALYZER_BACKEND", "")
+
+	backend, err := New("")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if backend.Name() != BackendRules {
+		t.Errorf("Name() = %q, want %q", backend.Name(), BackendRules)
+	}
+
+	if _, err := New("unknown"); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
@@ -0,0 +1,225 @@
+package anomalies
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"tempo-otlp-trace-demo/clock"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultCapacity is the number of anomalies the default ledger keeps in memory
+const DefaultCapacity = 10000
+
+// Anomaly types
+const (
+	TypeLatency = "latency"
+	TypeError   = "error"
+	TypeTimeout = "timeout"
+	TypePanic   = "panic"
+)
+
+// Magnitude units
+const (
+	UnitMilliseconds = "ms"
+	UnitProbability  = "probability"
+)
+
+// Anomaly is the ground-truth record of an anomaly injected into a span
+type Anomaly struct {
+	TraceID   string    `json:"trace_id" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+	SpanID    string    `json:"span_id" example:"00f067aa0ba902b7"`
+	SpanName  string    `json:"span_name" example:"processPayment"`
+	Type      string    `json:"type" example:"latency"`                    // latency, error, timeout or panic
+	Source    string    `json:"source" example:"request"`                  // What injected it: request (e.g. the sleep flag), random, scenario, registry or header
+	Magnitude float64   `json:"magnitude" example:"5000"`                  // Added latency, or the probability the error was drawn with
+	Unit      string    `json:"unit" example:"ms"`                         // ms or probability
+	Message   string    `json:"message,omitempty" example:"card declined"` // Error message, if any
+	Timestamp time.Time `json:"timestamp" example:"2024-01-01T00:00:00Z"`
+}
+
+// Filter selects anomalies from the ledger; empty fields match everything
+type Filter struct {
+	TraceID  string
+	SpanName string
+	Type     string
+	Since    time.Time
+	Limit    int // Most recent anomalies to return (0: all)
+}
+
+func (f Filter) matches(a *Anomaly) bool {
+	return (f.TraceID == "" || strings.EqualFold(f.TraceID, a.TraceID)) &&
+		(f.SpanName == "" || f.SpanName == a.SpanName) &&
+		(f.Type == "" || f.Type == a.Type) &&
+		(f.Since.IsZero() || !a.Timestamp.Before(f.Since))
+}
+
+// Ledger keeps the most recent injected anomalies in a ring buffer
+// and optionally appends every anomaly to a JSONL file
+type Ledger struct {
+	mu       sync.RWMutex
+	records  []Anomaly
+	next     int
+	full     bool
+	total    int
+	export   io.WriteCloser
+	exportMu sync.Mutex
+}
+
+// NewLedger creates a ledger that keeps up to capacity anomalies in memory
+func NewLedger(capacity int) *Ledger {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Ledger{
+		records: make([]Anomaly, capacity),
+	}
+}
+
+// Default is the ledger served by /api/anomalies
+var Default = NewLedger(DefaultCapacity)
+
+// Record adds an anomaly injected into span to the default ledger
+func Record(ctx context.Context, span trace.Span, a Anomaly) {
+	Default.Record(ctx, span, a)
+}
+
+// Record fills in the trace and span IDs of span and the current time on the clock of ctx,
+// then adds the anomaly
+func (l *Ledger) Record(ctx context.Context, span trace.Span, a Anomaly) {
+	sc := span.SpanContext()
+	if sc.HasTraceID() {
+		a.TraceID = sc.TraceID().String()
+	}
+	if sc.HasSpanID() {
+		a.SpanID = sc.SpanID().String()
+	}
+	if a.Timestamp.IsZero() {
+		a.Timestamp = clock.Now(ctx)
+	}
+	l.Add(a)
+}
+
+// Add appends an anomaly, evicting the oldest one when the ledger is full
+func (l *Ledger) Add(a Anomaly) {
+	l.mu.Lock()
+	l.records[l.next] = a
+	l.next = (l.next + 1) % len(l.records)
+	if l.next == 0 {
+		l.full = true
+	}
+	l.total++
+	l.mu.Unlock()
+
+	l.exportMu.Lock()
+	defer l.exportMu.Unlock()
+	if l.export != nil {
+		if err := json.NewEncoder(l.export).Encode(a); err != nil {
+			slog.Error("Failed to export anomaly", "error", err)
+		}
+	}
+}
+
+// List returns the matching anomalies, oldest first
+func (l *Ledger) List(filter Filter) []Anomaly {
+	l.mu.RLock()
+	ordered := make([]Anomaly, 0, l.lenLocked())
+	if l.full {
+		ordered = append(ordered, l.records[l.next:]...)
+	}
+	ordered = append(ordered, l.records[:l.next]...)
+	l.mu.RUnlock()
+
+	list := make([]Anomaly, 0)
+	for i := range ordered {
+		if filter.matches(&ordered[i]) {
+			list = append(list, ordered[i])
+		}
+	}
+	if filter.Limit > 0 && len(list) > filter.Limit {
+		list = list[len(list)-filter.Limit:]
+	}
+	return list
+}
+
+// Total returns the number of anomalies recorded since the ledger was created or cleared,
+// including those evicted from memory
+func (l *Ledger) Total() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.total
+}
+
+// Clear removes all anomalies from memory and returns how many were kept
+func (l *Ledger) Clear() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	count := l.lenLocked()
+	l.records = make([]Anomaly, len(l.records))
+	l.next = 0
+	l.full = false
+	l.total = 0
+	return count
+}
+
+func (l *Ledger) lenLocked() int {
+	if l.full {
+		return len(l.records)
+	}
+	return l.next
+}
+
+// ExportTo appends every anomaly recorded from now on to a JSONL file
+func (l *Ledger) ExportTo(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open anomaly export file: %w", err)
+	}
+
+	l.exportMu.Lock()
+	defer l.exportMu.Unlock()
+	if l.export != nil {
+		l.export.Close()
+	}
+	l.export = file
+	return nil
+}
+
+// Close closes the JSONL export file, if any
+func (l *Ledger) Close() error {
+	l.exportMu.Lock()
+	defer l.exportMu.Unlock()
+	if l.export == nil {
+		return nil
+	}
+	err := l.export.Close()
+	l.export = nil
+	return err
+}
+
+// ExportFromEnv starts exporting the default ledger to the JSONL file named by ANOMALY_LEDGER_FILE, if set
+func ExportFromEnv() error {
+	path := strings.TrimSpace(os.Getenv("ANOMALY_LEDGER_FILE"))
+	if path == "" {
+		return nil
+	}
+	return Default.ExportTo(path)
+}
+
+// WriteJSONL writes anomalies as JSON lines
+func WriteJSONL(w io.Writer, list []Anomaly) error {
+	encoder := json.NewEncoder(w)
+	for i := range list {
+		if err := encoder.Encode(&list[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"tempo-otlp-trace-demo/loadgen"
+	"tempo-otlp-trace-demo/tracing"
+	"time"
+)
+
+// runBackfill implements the "backfill" subcommand:
+//
+//	trace-demo-app backfill [-config backfill.yaml] [-days 7] [-end 2024-06-01T00:00:00Z] [-rate 2] ...
+//
+// It generates the traffic of the past days ending at -end on a virtual clock, so every span is
+// timestamped in the window, and exports it through the OTLP exporter of tracing.InitTracer.
+// Without a config the rate follows a diurnal curve over the order, profile, report, search and
+// batch endpoints; incident windows are declared as anomalies in the config, as offsets from the start.
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	configPath := fs.String("config", "", "YAML or JSON load config (see backfill.example.yaml)")
+	target := fs.String("target", "", "Base URL of the service (default: drive the handlers in-process)")
+	days := fs.Float64("days", 7, "Number of days to backfill")
+	end := fs.String("end", "", "End of the window, RFC3339 (default: now)")
+	rate := fs.Float64("rate", 2, "Mean requests per second")
+	amplitude := fs.Float64("amplitude", 1.5, "Daily swing of the rate around the mean")
+	peakHour := fs.Float64("peak-hour", 14, "UTC hour of the daily peak")
+	concurrency := fs.Int("concurrency", 50, "Maximum requests in flight")
+	seed := fs.Int64("seed", 0, "Seed for the endpoint sequence and trace IDs (default: random)")
+	reportPath := fs.String("report", "", "Write the JSON report to this file (- for stdout)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	endTime := time.Now()
+	if *end != "" {
+		parsed, err := time.Parse(time.RFC3339, *end)
+		if err != nil {
+			return fmt.Errorf("invalid -end %q: expected an RFC3339 timestamp", *end)
+		}
+		endTime = parsed
+	}
+
+	cfg := &loadgen.Config{}
+	if *configPath != "" {
+		loaded, err := loadgen.LoadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		cfg = loaded
+	}
+
+	// Flags override the config file when given explicitly, and provide the defaults without one
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	override := func(name string) bool {
+		return set[name] || *configPath == ""
+	}
+	if override("target") {
+		cfg.Target = *target
+	}
+	if override("days") {
+		cfg.Duration.Duration = time.Duration(*days * float64(24*time.Hour))
+	}
+	if *configPath == "" {
+		cfg.Profile.Type = loadgen.ProfileDiurnal
+	}
+	if override("rate") {
+		cfg.Profile.Rate = *rate
+	}
+	if override("amplitude") {
+		cfg.Profile.Amplitude = *amplitude
+	}
+	if override("peak-hour") {
+		cfg.Profile.PeakHour = *peakHour
+	}
+	if override("concurrency") {
+		cfg.Concurrency = *concurrency
+	}
+	if set["seed"] {
+		cfg.Seed = *seed
+	}
+
+	if err := cfg.ValidateBackfill(); err != nil {
+		return fmt.Errorf("invalid backfill config: %w", err)
+	}
+	if endTime.After(time.Now()) {
+		return fmt.Errorf("-end must not be in the future")
+	}
+	start := endTime.Add(-cfg.Duration.Duration)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// The exporter blocks instead of dropping spans when the backfill outpaces it
+	doer, cleanup, err := newLoadDoer(ctx, cfg.Target, tracing.WithBlockingExport())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	slog.Info("Backfill started", "profile", cfg.Profile.String(),
+		"from", start.UTC().Format(time.RFC3339), "to", endTime.UTC().Format(time.RFC3339), "target", targetName(cfg.Target))
+	report := loadgen.NewRunner(cfg, doer).Backfill(ctx, start)
+	report.WriteText(os.Stdout)
+
+	return writeLoadReport(report, *reportPath)
+}
@@ -0,0 +1,72 @@
+// Package buildinfo describes the build of the binary. Version, Commit and Date are stamped at build time:
+//
+//	go build -ldflags "-X tempo-otlp-trace-demo/buildinfo.Version=v1.2.0 \
+//	  -X tempo-otlp-trace-demo/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X tempo-otlp-trace-demo/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Unstamped builds fall back to the module version and VCS information recorded by the Go toolchain.
+package buildinfo
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Set with -ldflags "-X tempo-otlp-trace-demo/buildinfo.<Name>=<value>"
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// Info describes a build
+type Info struct {
+	Version  string // Release version, "dev" when unknown
+	Commit   string // VCS revision, empty when unknown
+	Date     string // Build or commit time, RFC3339, empty when unknown
+	Modified bool   // The working tree had uncommitted changes, known only for unstamped builds
+}
+
+var (
+	info     Info
+	infoOnce sync.Once
+)
+
+// Get returns the build of the running binary
+func Get() Info {
+	infoOnce.Do(func() {
+		info = Info{Version: Version, Commit: Commit, Date: Date}
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			fromBuildInfo(&info, bi)
+		}
+		if info.Version == "" {
+			info.Version = "dev"
+		}
+	})
+	return info
+}
+
+// fromBuildInfo fills in what was not stamped from the information recorded by the Go toolchain
+func fromBuildInfo(info *Info, bi *debug.BuildInfo) {
+	if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	// A stamped commit may not be the checkout the toolchain saw, so its state is not taken either
+	stamped := info.Commit != ""
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if !stamped {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = setting.Value
+			}
+		case "vcs.modified":
+			if !stamped {
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+}
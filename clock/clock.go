@@ -0,0 +1,152 @@
+package clock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header and QueryParam switch a request to virtual time.
+// The value is "true" (start now) or an RFC3339 timestamp to start the trace at.
+const (
+	Header     = "X-Synthetic-Time"
+	QueryParam = "synthetic_time"
+)
+
+// EnvVar switches every request to virtual time, with the same values as Header
+const EnvVar = "DEMO_SYNTHETIC_TIME"
+
+// Clock is a virtual clock that only moves when Sleep is called.
+// Spans started and ended under it get its timestamps instead of the wall clock,
+// so a trace of any length is generated without waiting.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now returns the clock's current time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// AdvanceTo moves the clock forward to t; it never moves backwards
+func (c *Clock) AdvanceTo(t time.Time) {
+	c.mu.Lock()
+	if t.After(c.now) {
+		c.now = t
+	}
+	c.mu.Unlock()
+}
+
+type clockKey struct{}
+
+// WithVirtual returns a context whose spans and sleeps run on a virtual clock starting at start
+func WithVirtual(ctx context.Context, start time.Time) context.Context {
+	return context.WithValue(ctx, clockKey{}, &Clock{now: start})
+}
+
+// FromContext returns the virtual clock of ctx
+func FromContext(ctx context.Context) (*Clock, bool) {
+	c, ok := ctx.Value(clockKey{}).(*Clock)
+	return c, ok
+}
+
+// Fork returns a context with a copy of the virtual clock, for work that runs concurrently
+// with its siblings; join it back with Join. Without a virtual clock ctx is returned unchanged.
+func Fork(ctx context.Context) context.Context {
+	if c, ok := FromContext(ctx); ok {
+		return WithVirtual(ctx, c.Now())
+	}
+	return ctx
+}
+
+// Join advances the virtual clock of ctx to the latest of the forked clocks,
+// as if it had waited for all of them
+func Join(ctx context.Context, forks ...context.Context) {
+	c, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+	for _, fork := range forks {
+		if fc, ok := FromContext(fork); ok {
+			c.AdvanceTo(fc.Now())
+		}
+	}
+}
+
+// Now returns the virtual time of ctx, or the wall clock
+func Now(ctx context.Context) time.Time {
+	if c, ok := FromContext(ctx); ok {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Since returns the time elapsed since t on the clock of ctx
+func Since(ctx context.Context, t time.Time) time.Duration {
+	return Now(ctx).Sub(t)
+}
+
+// Sleep advances the virtual clock of ctx by d, or waits for d on the wall clock
+// until ctx is done
+func Sleep(ctx context.Context, d time.Duration) {
+	if c, ok := FromContext(ctx); ok {
+		c.Advance(d)
+		return
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// StartFromRequest returns the virtual start time requested by the X-Synthetic-Time header,
+// the synthetic_time query parameter or DEMO_SYNTHETIC_TIME, in that order
+func StartFromRequest(r *http.Request) (time.Time, bool, error) {
+	value := r.Header.Get(Header)
+	if value == "" {
+		value = r.URL.Query().Get(QueryParam)
+	}
+	if value == "" {
+		value = os.Getenv(EnvVar)
+	}
+	return ParseStart(value)
+}
+
+// ParseStart parses a synthetic time value: empty or false for wall-clock time,
+// true or now to start at the current time, or an RFC3339 timestamp
+func ParseStart(value string) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "now") {
+		return time.Now(), value != "", nil
+	}
+	if enabled, err := strconv.ParseBool(value); err == nil {
+		return time.Now(), enabled, nil
+	}
+
+	start, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid synthetic time %q: expected true, now or an RFC3339 timestamp", value)
+	}
+	return start, true, nil
+}
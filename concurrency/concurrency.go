@@ -0,0 +1,113 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/random"
+)
+
+// Header and QueryParam carry the fan-out limit of a request
+const (
+	Header     = "X-Concurrency"
+	QueryParam = "concurrency"
+)
+
+// EnvVar sets the fan-out limit of requests that do not carry their own
+const EnvVar = "DEMO_CONCURRENCY"
+
+// MaxLimit bounds the workers of a single fan-out stage
+const MaxLimit = 64
+
+type limitKey struct{}
+
+// WithLimit returns a context whose fan-out stages run up to limit tasks at once
+func WithLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, limitKey{}, limit)
+}
+
+// FromContext returns the fan-out limit carried by ctx
+func FromContext(ctx context.Context) (int, bool) {
+	limit, ok := ctx.Value(limitKey{}).(int)
+	return limit, ok
+}
+
+// Limit returns the fan-out limit of ctx; stages run sequentially by default
+func Limit(ctx context.Context) int {
+	if limit, ok := FromContext(ctx); ok {
+		return limit
+	}
+	return 1
+}
+
+// LimitFromRequest returns the fan-out limit of a request: the X-Concurrency header,
+// the concurrency query parameter or DEMO_CONCURRENCY, in that order
+func LimitFromRequest(r *http.Request) (int, bool, error) {
+	value := r.Header.Get(Header)
+	if value == "" {
+		value = r.URL.Query().Get(QueryParam)
+	}
+	if value == "" {
+		value = os.Getenv(EnvVar)
+	}
+	return ParseLimit(value)
+}
+
+// ParseLimit parses a fan-out limit between 1 and MaxLimit; empty means unset
+func ParseLimit(value string) (int, bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > MaxLimit {
+		return 0, false, fmt.Errorf("invalid concurrency %q: must be an integer between 1 and %d", value, MaxLimit)
+	}
+	return limit, true, nil
+}
+
+// Run runs the tasks on up to limit workers and returns when all of them are done;
+// a limit below 1 runs every task at once. Task i goes to worker i mod limit, and every
+// task gets its own fork of the random source, drawn in task order, so the same seed
+// yields the same trace whatever the limit and however the workers interleave.
+// Each worker runs on a fork of the virtual clock, if any, that is joined back at the end.
+func Run(ctx context.Context, limit int, tasks ...func(ctx context.Context)) {
+	if limit < 1 || limit > len(tasks) {
+		limit = len(tasks)
+	}
+
+	rng := random.FromContext(ctx)
+	rands := make([]*random.Rand, len(tasks))
+	for i := range tasks {
+		rands[i] = rng.Fork()
+	}
+
+	if limit <= 1 {
+		for i, task := range tasks {
+			task(random.WithRand(ctx, rands[i]))
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	forks := make([]context.Context, limit)
+	for w := 0; w < limit; w++ {
+		fork := clock.Fork(ctx)
+		forks[w] = fork
+
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := worker; i < len(tasks); i += limit {
+				tasks[i](random.WithRand(fork, rands[i]))
+			}
+		}(w)
+	}
+	wg.Wait()
+	clock.Join(ctx, forks...)
+}
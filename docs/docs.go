@@ -15,9 +15,144 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/analyze": {
+            "post": {
+                "description": "Builds the analysis bundle for a trace (or uses the bundle from the request body) and passes it to an analyzer backend, returning the suspected cause, evidence spans and suggested fix. Backends: rules (deterministic, offline) and openai (OpenAI-compatible chat completions API).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Trace Analysis"
+                ],
+                "summary": "Diagnose a trace",
+                "parameters": [
+                    {
+                        "description": "Trace ID or analysis bundle to diagnose",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AnalyzeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Diagnosis"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Trace not found or has no spans",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Failed to query Tempo or analyzer failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/anomalies": {
+            "get": {
+                "description": "Returns the ground-truth ledger of anomalies injected into spans (the order sleep flag, random processItem failures, scenario errors and fault injection), with trace ID, span name, type, magnitude and timestamp. Use format=jsonl to export one anomaly per line.",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "Anomalies"
+                ],
+                "summary": "Get injected anomalies",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only anomalies in this trace",
+                        "name": "trace_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only anomalies in spans with this name",
+                        "name": "span_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only anomalies of this type (latency, error, timeout, panic)",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only anomalies injected at or after this time (RFC3339)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Return only the most recent anomalies (default: all)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json (default) or jsonl",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AnomaliesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes all anomalies from the in-memory ledger, e.g. before a labelled load run",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Anomalies"
+                ],
+                "summary": "Clear injected anomalies",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AnomaliesClearResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/api/batch/process": {
             "post": {
-                "description": "Processes a batch of items with comprehensive tracing. Generates 6-15 spans with 300-1500ms duration depending on batch size.",
+                "description": "Processes a batch of items with comprehensive tracing. Generates 6-15 spans with 300-1500ms duration depending on batch size. The processItems span links to the trace that enqueued each item (traceparents, or a stand-in upstream trace), and failed items record an exception with a stack trace. With X-Concurrency the items are processed on that many workers.",
                 "consumes": [
                     "application/json"
                 ],
@@ -51,6 +186,108 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "500": {
+                        "description": "Injected fault",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Injected timeout",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/faults": {
+            "get": {
+                "description": "Returns the faults configured in the fault-injection registry, keyed by span name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Faults"
+                ],
+                "summary": "Get injected faults",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FaultsResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds faults to the registry, replacing any fault for the same span name. Every span created by the demo handlers with that name gets the added latency (fixed or distribution), timeout, panic or error rate, activated with the given probability.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Faults"
+                ],
+                "summary": "Add or replace injected faults",
+                "parameters": [
+                    {
+                        "description": "Faults to add or replace",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FaultsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FaultUpdateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the fault for a span name, or all faults when span_name is omitted",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Faults"
+                ],
+                "summary": "Remove injected faults",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Span name whose fault to remove (default: all)",
+                        "name": "span_name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FaultUpdateResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Fault not found",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -180,7 +417,7 @@ const docTemplate = `{
         },
         "/api/order/create": {
             "post": {
-                "description": "Creates an order with comprehensive tracing. Generates 10-12 spans with 600-1500ms duration. If sleep=true, adds 5s delay to payment processing to simulate slow operation.",
+                "description": "Creates an order with comprehensive tracing. Inventory, payment and notification are separate services called over HTTP, so the trace has 18 spans across 4 services with 600-1500ms duration. If sleep=true, adds 5s delay to payment processing to simulate slow operation. With X-Concurrency the email and SMS are sent concurrently.",
                 "consumes": [
                     "application/json"
                 ],
@@ -214,13 +451,31 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "500": {
+                        "description": "Injected fault",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Inventory, payment or notification service failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Injected timeout",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
         "/api/report/generate": {
             "post": {
-                "description": "Generates a report with comprehensive tracing. LONG TRACE - Generates 10-12 spans with 1500-3500ms duration.",
+                "description": "Generates a report with comprehensive tracing. LONG TRACE - Generates 10-12 spans with 1500-3500ms duration. generatePDF sometimes records a gc.pause span event. With X-Concurrency the three data sources are fetched concurrently.",
                 "consumes": [
                     "application/json"
                 ],
@@ -254,6 +509,59 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "500": {
+                        "description": "Injected fault",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Injected timeout",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/scenarios/run": {
+            "post": {
+                "description": "Generates a trace from a scenario written in YAML or JSON. Each span declares its name, kind, duration (a fixed value or a constant/uniform/normal/exponential distribution), attributes, error probability, repeat count and children, which run sequentially or in parallel. A scenario may run for at most 2 minutes on its critical path; the response is written when the run ends, past the server's 15s write timeout if needed. See the scenarios/ directory for examples.",
+                "consumes": [
+                    "application/json",
+                    "application/x-yaml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Simulation"
+                ],
+                "summary": "Run a trace scenario",
+                "parameters": [
+                    {
+                        "description": "Scenario to run",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/scenario.Scenario"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Scenario completed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ScenarioRunResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid scenario",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -294,6 +602,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.SearchResponse"
                         }
+                    },
+                    "500": {
+                        "description": "Injected fault",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Injected timeout",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -340,11 +660,75 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.SimulateResponse"
                         }
+                    },
+                    "500": {
+                        "description": "Injected fault",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Injected timeout",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
         "/api/source-code": {
+            "get": {
+                "description": "Queries Tempo for the trace, finds the span and returns its mapped source code together with duration, attributes and child spans",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Source Code"
+                ],
+                "summary": "Get source code for a span in a trace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Span ID",
+                        "name": "span_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Trace ID",
+                        "name": "trace_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SpanSourceCodeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Trace, span or mapping not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Failed to query Tempo",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
             "post": {
                 "description": "Retrieves the source code associated with a specific span name",
                 "consumes": [
@@ -410,9 +794,264 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/traces/analysis-bundle": {
+            "get": {
+                "description": "Loads a trace from Tempo and returns the top-N spans ranked by self-time, each with its mapped source code, attributes, parent chain and child timings. Use format=markdown to get a document that can be pasted directly into an LLM prompt.",
+                "produces": [
+                    "application/json",
+                    "text/markdown"
+                ],
+                "tags": [
+                    "Trace Analysis"
+                ],
+                "summary": "Get LLM-ready analysis bundle for a trace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Trace ID",
+                        "name": "trace_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of spans to include (default: 5, max: 20)",
+                        "name": "top",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Output format: json or markdown (default: json)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AnalysisBundle"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Trace not found or has no spans",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Failed to query Tempo",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/traces/critical-path": {
+            "get": {
+                "description": "Loads a trace from Tempo, rebuilds the span tree and returns the critical path together with each span's self-time (duration minus time covered by children). Spans are sorted by self-time, longest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Trace Analysis"
+                ],
+                "summary": "Get critical path of a trace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Trace ID",
+                        "name": "trace_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CriticalPathResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Trace not found or has no spans",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Failed to query Tempo",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/traces/diff": {
+            "get": {
+                "description": "Aligns the spans of a base and a target trace with the same root operation by operation name and tree position and reports duration and self-time deltas, changed attributes and added or missing spans. Spans are sorted by absolute self-time delta; the hotspot is the span whose self-time changed the most, with its mapped source code. Example: compare a normal POST /api/order/create trace (base) with one created with \"sleep\": true (target).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Trace Analysis"
+                ],
+                "summary": "Compare two traces",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Base trace ID",
+                        "name": "base",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target trace ID",
+                        "name": "target",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TraceDiffResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Trace not found or has no spans",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Root spans differ",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Failed to query Tempo",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/traces/search": {
+            "get": {
+                "description": "Proxies Tempo's /api/search with TraceQL, tag filters, duration bounds, limits and time ranges. Example: q={ name = \"POST /api/order/create\" \u0026\u0026 duration \u003e 2s }\u0026since=1h",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Trace Analysis"
+                ],
+                "summary": "Search traces in Tempo",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "TraceQL query",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tag filters in logfmt, e.g. http.method=POST",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum trace duration, e.g. 2s",
+                        "name": "min_duration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum trace duration, e.g. 10s",
+                        "name": "max_duration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of traces (default: 20, max: 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Spans per span set",
+                        "name": "spss",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Relative time range ending now, e.g. 1h (overrides start/end)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of time range (unix seconds or RFC3339)",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of time range (unix seconds or RFC3339)",
+                        "name": "end",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/tracing.SearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Failed to query Tempo",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/api/user/profile": {
             "get": {
-                "description": "Retrieves user profile information. Generates 4-5 spans with 110-310ms duration.",
+                "description": "Retrieves user profile information. Generates 4-5 spans with 110-310ms duration. loadPreferences records cache.hit or cache.miss/cache.fill span events.",
                 "produces": [
                     "application/json"
                 ],
@@ -434,6 +1073,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.UserProfileResponse"
                         }
+                    },
+                    "500": {
+                        "description": "Injected fault",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Injected timeout",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -460,24 +1111,197 @@ const docTemplate = `{
         }
     },
     "definitions": {
-        "handlers.SourceCodeRequest": {
+        "anomalies.Anomaly": {
             "type": "object",
             "properties": {
-                "spanName": {
+                "magnitude": {
+                    "description": "Added latency, or the probability the error was drawn with",
+                    "type": "number",
+                    "example": 5000
+                },
+                "message": {
+                    "description": "Error message, if any",
                     "type": "string",
-                    "example": "POST /api/order/create"
-                }
-            }
-        },
-        "handlers.SpanNameInfo": {
-            "type": "object",
-            "properties": {
-                "description": {
+                    "example": "card declined"
+                },
+                "source": {
+                    "description": "What injected it: request (e.g. the sleep flag), random, scenario, registry or header",
                     "type": "string",
-                    "example": "Handles order creation with comprehensive tracing"
+                    "example": "request"
                 },
-                "end_line": {
-                    "type": "integer",
+                "span_id": {
+                    "type": "string",
+                    "example": "00f067aa0ba902b7"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2024-01-01T00:00:00Z"
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "4bf92f3577b34da6a3ce929d0e0e4736"
+                },
+                "type": {
+                    "description": "latency, error, timeout or panic",
+                    "type": "string",
+                    "example": "latency"
+                },
+                "unit": {
+                    "description": "ms or probability",
+                    "type": "string",
+                    "example": "ms"
+                }
+            }
+        },
+        "faults.Fault": {
+            "type": "object",
+            "properties": {
+                "error_message": {
+                    "description": "Defaults to \"injected error in \u003cspan\u003e\"",
+                    "type": "string",
+                    "example": "card declined"
+                },
+                "error_rate": {
+                    "description": "Probability that the call fails with ErrorMessage",
+                    "type": "number",
+                    "example": 0.5
+                },
+                "latency": {
+                    "description": "Added latency, a fixed duration or a distribution",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/scenario.DurationSpec"
+                        }
+                    ]
+                },
+                "panic": {
+                    "description": "The call panics; the panic is recovered into an error span and fails the call",
+                    "type": "boolean",
+                    "example": false
+                },
+                "probability": {
+                    "description": "Probability that the fault is active for a call (0 or omitted: always)",
+                    "type": "number",
+                    "example": 1
+                },
+                "source": {
+                    "description": "\"registry\" or \"header\"",
+                    "type": "string",
+                    "example": "registry"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "timeout": {
+                    "description": "The call hangs for this long, then fails with a timeout error; with the latency at most 10s",
+                    "type": "string",
+                    "example": "3s"
+                }
+            }
+        },
+        "handlers.AnomaliesClearResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Anomaly ledger cleared"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "success"
+                }
+            }
+        },
+        "handlers.AnomaliesResponse": {
+            "type": "object",
+            "properties": {
+                "anomalies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/anomalies.Anomaly"
+                    }
+                },
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "total": {
+                    "description": "Anomalies recorded since the ledger was cleared, including those evicted from memory",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "handlers.FaultUpdateResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Faults updated successfully"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "success"
+                }
+            }
+        },
+        "handlers.FaultsRequest": {
+            "type": "object",
+            "properties": {
+                "faults": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/faults.Fault"
+                    }
+                }
+            }
+        },
+        "handlers.FaultsResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "faults": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/faults.Fault"
+                    }
+                }
+            }
+        },
+        "handlers.SourceCodeRequest": {
+            "type": "object",
+            "properties": {
+                "spanName": {
+                    "type": "string",
+                    "example": "POST /api/order/create"
+                }
+            }
+        },
+        "handlers.SpanNameInfo": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Handles order creation with comprehensive tracing"
+                },
+                "end_line": {
+                    "type": "integer",
                     "example": 85
                 },
                 "file_path": {
@@ -513,291 +1337,1339 @@ const docTemplate = `{
                 }
             }
         },
+        "models.AnalysisBundle": {
+            "type": "object",
+            "properties": {
+                "critical_path": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CriticalPathStep"
+                    }
+                },
+                "root_span_name": {
+                    "type": "string",
+                    "example": "POST /api/order/create"
+                },
+                "span_count": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "spans": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BundleSpan"
+                    }
+                },
+                "total_duration": {
+                    "type": "string",
+                    "example": "5.90s"
+                },
+                "total_duration_us": {
+                    "type": "integer",
+                    "example": 5900000
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "xyz789"
+                }
+            }
+        },
+        "models.AnalyzeRequest": {
+            "type": "object",
+            "properties": {
+                "analyzer": {
+                    "type": "string",
+                    "example": "rules"
+                },
+                "bundle": {
+                    "$ref": "#/definitions/models.AnalysisBundle"
+                },
+                "top": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "xyz789"
+                }
+            }
+        },
+        "models.AttributeChange": {
+            "type": "object",
+            "properties": {
+                "base": {
+                    "type": "string",
+                    "example": "false"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "simulate.slow"
+                },
+                "target": {
+                    "type": "string",
+                    "example": "true"
+                }
+            }
+        },
         "models.BatchRequest": {
             "type": "object",
             "properties": {
                 "items": {
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "type": "string"
+                    }
+                },
+                "traceparents": {
+                    "description": "W3C traceparent of the trace that enqueued each item, in item order",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.BatchResponse": {
+            "type": "object",
+            "properties": {
+                "batch_id": {
+                    "type": "string"
+                },
+                "failed_count": {
+                    "type": "integer"
+                },
+                "processed_count": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BundleSpan": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SpanRef"
+                    }
+                },
+                "duration": {
+                    "type": "string",
+                    "example": "5.35s"
+                },
+                "duration_us": {
+                    "type": "integer",
+                    "example": 5350000
+                },
+                "end_line": {
+                    "type": "integer",
+                    "example": 167
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SpanEvent"
+                    }
+                },
+                "exceptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SpanException"
+                    }
+                },
+                "file_path": {
+                    "type": "string",
+                    "example": "handlers/order.go"
+                },
+                "function_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "links": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SpanLink"
+                    }
+                },
+                "on_critical_path": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "parent_chain": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SpanRef"
+                    }
+                },
+                "rank": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "self_time": {
+                    "type": "string",
+                    "example": "5.00s"
+                },
+                "self_time_us": {
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "source_code": {
+                    "type": "string",
+                    "example": "func processPayment(...) {...}"
+                },
+                "source_error": {
+                    "type": "string",
+                    "example": "no source code mapping"
+                },
+                "span_id": {
+                    "type": "string",
+                    "example": "abc123"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "start_line": {
+                    "type": "integer",
+                    "example": 142
+                }
+            }
+        },
+        "models.ChildSpanInfo": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string",
+                    "example": "52.3ms"
+                },
+                "function_name": {
+                    "type": "string",
+                    "example": "validateOrder"
+                },
+                "span_id": {
+                    "type": "string",
+                    "example": "def456"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "validateOrder"
+                }
+            }
+        },
+        "models.CriticalPathResponse": {
+            "type": "object",
+            "properties": {
+                "critical_path": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CriticalPathStep"
+                    }
+                },
+                "root_span_id": {
+                    "type": "string",
+                    "example": "abc123"
+                },
+                "root_span_name": {
+                    "type": "string",
+                    "example": "POST /api/order/create"
+                },
+                "spans": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SpanTiming"
+                    }
+                },
+                "total_duration": {
+                    "type": "string",
+                    "example": "5.90s"
+                },
+                "total_duration_us": {
+                    "type": "integer",
+                    "example": 5900000
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "xyz789"
+                }
+            }
+        },
+        "models.CriticalPathStep": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string",
+                    "example": "5.00s"
+                },
+                "duration_us": {
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "function_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "offset_us": {
+                    "type": "integer",
+                    "example": 250000
+                },
+                "span_id": {
+                    "type": "string",
+                    "example": "def456"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                }
+            }
+        },
+        "models.Diagnosis": {
+            "type": "object",
+            "properties": {
+                "analyzer": {
+                    "type": "string",
+                    "example": "rules"
+                },
+                "confidence": {
+                    "type": "number",
+                    "example": 0.95
+                },
+                "evidence_spans": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.EvidenceSpan"
+                    }
+                },
+                "suggested_fix": {
+                    "type": "string",
+                    "example": "Remove the simulated delay from processPayment"
+                },
+                "suspected_cause": {
+                    "type": "string",
+                    "example": "processPayment contains an injected 5s delay"
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "xyz789"
+                }
+            }
+        },
+        "models.DiffHotspot": {
+            "type": "object",
+            "properties": {
+                "base_duration_us": {
+                    "type": "integer",
+                    "example": 350000
+                },
+                "base_span_id": {
+                    "type": "string",
+                    "example": "def456"
+                },
+                "changed_attributes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AttributeChange"
+                    }
+                },
+                "duration_delta": {
+                    "type": "string",
+                    "example": "+5.00s"
+                },
+                "duration_delta_us": {
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "end_line": {
+                    "type": "integer",
+                    "example": 171
+                },
+                "file_path": {
+                    "type": "string",
+                    "example": "handlers/order.go"
+                },
+                "function_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "POST /api/order/create/processPayment[0]"
+                },
+                "self_time_delta": {
+                    "type": "string",
+                    "example": "+5.00s"
+                },
+                "self_time_delta_us": {
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "source_code": {
+                    "type": "string",
+                    "example": "func processPayment(...) {...}"
+                },
+                "source_error": {
+                    "type": "string",
+                    "example": "no source code mapping"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "start_line": {
+                    "type": "integer",
+                    "example": 147
+                },
+                "target_duration_us": {
+                    "type": "integer",
+                    "example": 5350000
+                },
+                "target_span_id": {
+                    "type": "string",
+                    "example": "fed654"
+                }
+            }
+        },
+        "models.DiffSpan": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string",
+                    "example": "120.00ms"
+                },
+                "duration_us": {
+                    "type": "integer",
+                    "example": 120000
+                },
+                "function_name": {
+                    "type": "string",
+                    "example": "retryPayment"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "POST /api/order/create/retryPayment[0]"
+                },
+                "span_id": {
+                    "type": "string",
+                    "example": "aaa111"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "retryPayment"
+                }
+            }
+        },
+        "models.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.EvidenceSpan": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "slow.reason=simulated_delay"
+                },
+                "self_time": {
+                    "type": "string",
+                    "example": "5.00s"
+                },
+                "span_id": {
+                    "type": "string",
+                    "example": "abc123"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                }
+            }
+        },
+        "models.MappingRequest": {
+            "type": "object",
+            "properties": {
+                "mappings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SourceCodeMapping"
+                    }
+                }
+            }
+        },
+        "models.MappingResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Mappings updated successfully"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "success"
+                }
+            }
+        },
+        "models.OrderRequest": {
+            "type": "object",
+            "properties": {
+                "price": {
+                    "type": "number"
+                },
+                "product_id": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "sleep": {
+                    "description": "If true, simulate slow operation by adding 5s delay to processPayment",
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.OrderResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "order_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_cost": {
+                    "type": "number"
+                }
+            }
+        },
+        "models.ReportRequest": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "type": "string"
+                },
+                "filters": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "report_type": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReportResponse": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "report_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ScenarioRunResponse": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string",
+                    "example": "1.234s"
+                },
+                "error_count": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "message": {
+                    "type": "string",
+                    "example": "Scenario checkout-slow-payment generated 12 spans"
+                },
+                "scenario": {
+                    "type": "string",
+                    "example": "checkout-slow-payment"
+                },
+                "span_count": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "timed_out": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "xyz789"
+                }
+            }
+        },
+        "models.SearchResponse": {
+            "type": "object",
+            "properties": {
+                "page": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SearchResult"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SearchResult": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "score": {
+                    "type": "number"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SimulateResponse": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "span_count": {
+                    "type": "integer"
+                },
+                "trace_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SourceCodeMapping": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "description": "Optional description",
+                    "type": "string",
+                    "example": "Handles order creation"
+                },
+                "end_line": {
+                    "description": "Ending line number",
+                    "type": "integer",
+                    "example": 85
+                },
+                "file_path": {
+                    "description": "e.g., \"handlers/order.go\"",
+                    "type": "string",
+                    "example": "handlers/order.go"
+                },
+                "function_name": {
+                    "description": "e.g., \"CreateOrder\"",
+                    "type": "string",
+                    "example": "CreateOrder"
+                },
+                "span_name": {
+                    "description": "e.g., \"POST /api/order/create\"",
+                    "type": "string",
+                    "example": "POST /api/order/create"
+                },
+                "start_line": {
+                    "description": "Starting line number",
+                    "type": "integer",
+                    "example": 21
+                }
+            }
+        },
+        "models.SourceCodeResponse": {
+            "type": "object",
+            "properties": {
+                "end_line": {
+                    "type": "integer",
+                    "example": 85
+                },
+                "file_path": {
+                    "type": "string",
+                    "example": "handlers/order.go"
+                },
+                "function_name": {
+                    "type": "string",
+                    "example": "CreateOrder"
+                },
+                "source_code": {
+                    "type": "string",
+                    "example": "func CreateOrder(w http.ResponseWriter, r *http.Request) {...}"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "POST /api/order/create"
+                },
+                "start_line": {
+                    "type": "integer",
+                    "example": 21
+                }
+            }
+        },
+        "models.SpanDiff": {
+            "type": "object",
+            "properties": {
+                "base_duration_us": {
+                    "type": "integer",
+                    "example": 350000
+                },
+                "base_span_id": {
+                    "type": "string",
+                    "example": "def456"
+                },
+                "changed_attributes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AttributeChange"
+                    }
+                },
+                "duration_delta": {
+                    "type": "string",
+                    "example": "+5.00s"
+                },
+                "duration_delta_us": {
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "function_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "POST /api/order/create/processPayment[0]"
+                },
+                "self_time_delta": {
+                    "type": "string",
+                    "example": "+5.00s"
+                },
+                "self_time_delta_us": {
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "target_duration_us": {
+                    "type": "integer",
+                    "example": 5350000
+                },
+                "target_span_id": {
+                    "type": "string",
+                    "example": "fed654"
+                }
+            }
+        },
+        "models.SpanEvent": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "retry"
+                },
+                "offset_us": {
+                    "description": "Time since the span started",
+                    "type": "integer",
+                    "example": 320000
+                }
+            }
+        },
+        "models.SpanException": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string",
+                    "example": "payment provider timeout"
+                },
+                "stack_trace": {
+                    "type": "string",
+                    "example": "goroutine 42 [running]:..."
+                },
+                "type": {
+                    "type": "string",
+                    "example": "*faults.InjectedError"
+                }
+            }
+        },
+        "models.SpanLink": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "span_id": {
+                    "type": "string",
+                    "example": "00f067aa0ba902b7"
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "4bf92f3577b34da6a3ce929d0e0e4736"
+                }
+            }
+        },
+        "models.SpanRef": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string",
+                    "example": "320.00ms"
+                },
+                "duration_us": {
+                    "type": "integer",
+                    "example": 320000
+                },
+                "function_name": {
+                    "type": "string",
+                    "example": "callPaymentGateway"
+                },
+                "span_id": {
+                    "type": "string",
+                    "example": "def456"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "callPaymentGateway"
+                }
+            }
+        },
+        "models.SpanSourceCodeResponse": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "child_spans": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ChildSpanInfo"
+                    }
+                },
+                "duration": {
+                    "type": "string",
+                    "example": "1.23s"
+                },
+                "end_line": {
+                    "type": "integer",
+                    "example": 85
+                },
+                "file_path": {
+                    "type": "string",
+                    "example": "handlers/order.go"
+                },
+                "function_name": {
+                    "type": "string",
+                    "example": "CreateOrder"
+                },
+                "source_code": {
+                    "type": "string",
+                    "example": "func CreateOrder(w http.ResponseWriter, r *http.Request) {...}"
+                },
+                "span_id": {
+                    "type": "string",
+                    "example": "abc123"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "POST /api/order/create"
+                },
+                "start_line": {
+                    "type": "integer",
+                    "example": 21
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "xyz789"
+                }
+            }
+        },
+        "models.SpanTiming": {
+            "type": "object",
+            "properties": {
+                "critical_path_us": {
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "depth": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "duration": {
+                    "type": "string",
+                    "example": "5.35s"
+                },
+                "duration_us": {
+                    "type": "integer",
+                    "example": 5350000
+                },
+                "on_critical_path": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "parent_span_id": {
+                    "type": "string",
+                    "example": "abc123"
+                },
+                "self_time": {
+                    "type": "string",
+                    "example": "5.00s"
+                },
+                "self_time_us": {
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "span_id": {
+                    "type": "string",
+                    "example": "def456"
+                },
+                "span_name": {
+                    "type": "string",
+                    "example": "processPayment"
+                }
+            }
+        },
+        "models.TraceDiffResponse": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DiffSpan"
+                    }
+                },
+                "base_duration_us": {
+                    "type": "integer",
+                    "example": 900000
+                },
+                "base_trace_id": {
+                    "type": "string",
+                    "example": "xyz789"
+                },
+                "duration_delta": {
+                    "type": "string",
+                    "example": "+5.00s"
+                },
+                "duration_delta_us": {
+                    "type": "integer",
+                    "example": 5000000
+                },
+                "hotspot": {
+                    "$ref": "#/definitions/models.DiffHotspot"
+                },
+                "missing": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DiffSpan"
+                    }
+                },
+                "spans": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SpanDiff"
                     }
+                },
+                "target_duration_us": {
+                    "type": "integer",
+                    "example": 5900000
+                },
+                "target_trace_id": {
+                    "type": "string",
+                    "example": "uvw456"
                 }
             }
         },
-        "models.BatchResponse": {
+        "models.UserProfileResponse": {
             "type": "object",
             "properties": {
-                "batch_id": {
+                "email": {
                     "type": "string"
                 },
-                "failed_count": {
-                    "type": "integer"
-                },
-                "processed_count": {
-                    "type": "integer"
+                "name": {
+                    "type": "string"
                 },
-                "results": {
-                    "type": "array",
-                    "items": {
+                "preferences": {
+                    "type": "object",
+                    "additionalProperties": {
                         "type": "string"
                     }
                 },
-                "status": {
+                "user_id": {
                     "type": "string"
                 }
             }
         },
-        "models.ErrorResponse": {
+        "scenario.DurationSpec": {
             "type": "object",
             "properties": {
-                "code": {
-                    "type": "integer"
+                "distribution": {
+                    "type": "string",
+                    "example": "uniform"
                 },
-                "error": {
-                    "type": "string"
+                "max": {
+                    "type": "string",
+                    "example": "200ms"
                 },
-                "message": {
-                    "type": "string"
+                "mean": {
+                    "type": "string",
+                    "example": "120ms"
+                },
+                "min": {
+                    "type": "string",
+                    "example": "50ms"
+                },
+                "stddev": {
+                    "type": "string",
+                    "example": "30ms"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "120ms"
                 }
             }
         },
-        "models.MappingRequest": {
+        "scenario.EventSpec": {
             "type": "object",
             "properties": {
-                "mappings": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.SourceCodeMapping"
-                    }
+                "at": {
+                    "$ref": "#/definitions/scenario.DurationSpec"
+                },
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "name": {
+                    "type": "string",
+                    "example": "cache.miss"
+                },
+                "probability": {
+                    "type": "number",
+                    "example": 0.3
                 }
             }
         },
-        "models.MappingResponse": {
+        "scenario.LinkSpec": {
             "type": "object",
             "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
                 "count": {
                     "type": "integer",
                     "example": 5
                 },
-                "message": {
+                "span": {
                     "type": "string",
-                    "example": "Mappings updated successfully"
+                    "example": "publishOrderCreated"
                 },
-                "status": {
+                "span_id": {
                     "type": "string",
-                    "example": "success"
+                    "example": "00f067aa0ba902b7"
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "4bf92f3577b34da6a3ce929d0e0e4736"
                 }
             }
         },
-        "models.OrderRequest": {
+        "scenario.Scenario": {
             "type": "object",
             "properties": {
-                "price": {
-                    "type": "number"
-                },
-                "product_id": {
-                    "type": "string"
-                },
-                "quantity": {
-                    "type": "integer"
+                "description": {
+                    "type": "string",
+                    "example": "Checkout where the payment provider is slow"
                 },
-                "sleep": {
-                    "description": "If true, simulate slow operation by adding 5s delay to processPayment",
-                    "type": "boolean"
+                "name": {
+                    "type": "string",
+                    "example": "checkout-slow-payment"
                 },
-                "user_id": {
-                    "type": "string"
+                "root": {
+                    "$ref": "#/definitions/scenario.SpanSpec"
                 }
             }
         },
-        "models.OrderResponse": {
+        "scenario.SpanSpec": {
             "type": "object",
             "properties": {
-                "message": {
-                    "type": "string"
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": true
                 },
-                "order_id": {
-                    "type": "string"
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scenario.SpanSpec"
+                    }
                 },
-                "status": {
-                    "type": "string"
+                "concurrency": {
+                    "description": "Max parallel children running at once (default: all)",
+                    "type": "integer",
+                    "example": 4
                 },
-                "total_cost": {
-                    "type": "number"
+                "duration": {
+                    "$ref": "#/definitions/scenario.DurationSpec"
+                },
+                "error_message": {
+                    "type": "string",
+                    "example": "card declined"
+                },
+                "error_probability": {
+                    "type": "number",
+                    "example": 0.1
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scenario.EventSpec"
+                    }
+                },
+                "kind": {
+                    "description": "internal (default), server, client, producer, consumer",
+                    "type": "string",
+                    "example": "client"
+                },
+                "links": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/scenario.LinkSpec"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "processPayment"
+                },
+                "parallel": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "repeat": {
+                    "description": "Number of sibling copies of this span (default: 1)",
+                    "type": "integer",
+                    "example": 1
                 }
             }
         },
-        "models.ReportRequest": {
+        "tracing.OTLPArrayValue": {
             "type": "object",
             "properties": {
-                "end_date": {
-                    "type": "string"
-                },
-                "filters": {
+                "values": {
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "$ref": "#/definitions/tracing.OTLPValue"
                     }
-                },
-                "report_type": {
-                    "type": "string"
-                },
-                "start_date": {
-                    "type": "string"
                 }
             }
         },
-        "models.ReportResponse": {
+        "tracing.OTLPAttribute": {
             "type": "object",
             "properties": {
-                "duration": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
-                },
-                "report_id": {
-                    "type": "string"
-                },
-                "status": {
+                "key": {
                     "type": "string"
                 },
-                "url": {
-                    "type": "string"
+                "value": {
+                    "$ref": "#/definitions/tracing.OTLPValue"
                 }
             }
         },
-        "models.SearchResponse": {
+        "tracing.OTLPKvlist": {
             "type": "object",
             "properties": {
-                "page": {
-                    "type": "integer"
-                },
-                "results": {
+                "values": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.SearchResult"
+                        "$ref": "#/definitions/tracing.OTLPAttribute"
                     }
-                },
-                "total": {
-                    "type": "integer"
                 }
             }
         },
-        "models.SearchResult": {
+        "tracing.OTLPValue": {
             "type": "object",
             "properties": {
-                "description": {
-                    "type": "string"
+                "arrayValue": {
+                    "$ref": "#/definitions/tracing.OTLPArrayValue"
                 },
-                "id": {
+                "boolValue": {
+                    "type": "boolean"
+                },
+                "bytesValue": {
                     "type": "string"
                 },
-                "score": {
+                "doubleValue": {
                     "type": "number"
                 },
-                "title": {
+                "intValue": {
+                    "type": "string"
+                },
+                "kvlistValue": {
+                    "$ref": "#/definitions/tracing.OTLPKvlist"
+                },
+                "stringValue": {
                     "type": "string"
                 }
             }
         },
-        "models.SimulateResponse": {
+        "tracing.SearchMetrics": {
             "type": "object",
             "properties": {
-                "duration": {
-                    "type": "string"
+                "completedJobs": {
+                    "type": "integer"
                 },
-                "message": {
+                "inspectedBytes": {
                     "type": "string"
                 },
-                "span_count": {
+                "inspectedTraces": {
                     "type": "integer"
                 },
-                "trace_id": {
-                    "type": "string"
+                "totalBlocks": {
+                    "type": "integer"
+                },
+                "totalJobs": {
+                    "type": "integer"
                 }
             }
         },
-        "models.SourceCodeMapping": {
+        "tracing.SearchResponse": {
             "type": "object",
             "properties": {
-                "description": {
-                    "description": "Optional description",
-                    "type": "string",
-                    "example": "Handles order creation"
-                },
-                "end_line": {
-                    "description": "Ending line number",
-                    "type": "integer",
-                    "example": 85
-                },
-                "file_path": {
-                    "description": "e.g., \"handlers/order.go\"",
-                    "type": "string",
-                    "example": "handlers/order.go"
-                },
-                "function_name": {
-                    "description": "e.g., \"CreateOrder\"",
-                    "type": "string",
-                    "example": "CreateOrder"
-                },
-                "span_name": {
-                    "description": "e.g., \"POST /api/order/create\"",
-                    "type": "string",
-                    "example": "POST /api/order/create"
+                "metrics": {
+                    "$ref": "#/definitions/tracing.SearchMetrics"
                 },
-                "start_line": {
-                    "description": "Starting line number",
-                    "type": "integer",
-                    "example": 21
+                "traces": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tracing.TraceSearchResult"
+                    }
                 }
             }
         },
-        "models.SourceCodeResponse": {
+        "tracing.SpanSet": {
             "type": "object",
             "properties": {
-                "end_line": {
-                    "type": "integer",
-                    "example": 85
+                "matched": {
+                    "type": "integer"
                 },
-                "file_path": {
-                    "type": "string",
-                    "example": "handlers/order.go"
+                "spans": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tracing.SpanSetSpan"
+                    }
+                }
+            }
+        },
+        "tracing.SpanSetSpan": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tracing.OTLPAttribute"
+                    }
                 },
-                "function_name": {
-                    "type": "string",
-                    "example": "CreateOrder"
+                "durationNanos": {
+                    "type": "string"
                 },
-                "source_code": {
-                    "type": "string",
-                    "example": "func CreateOrder(w http.ResponseWriter, r *http.Request) {...}"
+                "name": {
+                    "type": "string"
                 },
-                "span_name": {
-                    "type": "string",
-                    "example": "POST /api/order/create"
+                "spanID": {
+                    "type": "string"
                 },
-                "start_line": {
-                    "type": "integer",
-                    "example": 21
+                "startTimeUnixNano": {
+                    "type": "string"
                 }
             }
         },
-        "models.UserProfileResponse": {
+        "tracing.TraceSearchResult": {
             "type": "object",
             "properties": {
-                "email": {
+                "durationMs": {
+                    "type": "integer"
+                },
+                "rootServiceName": {
                     "type": "string"
                 },
-                "name": {
+                "rootTraceName": {
                     "type": "string"
                 },
-                "preferences": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "string"
+                "spanSet": {
+                    "$ref": "#/definitions/tracing.SpanSet"
+                },
+                "spanSets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/tracing.SpanSet"
                     }
                 },
-                "user_id": {
+                "startTimeUnixNano": {
+                    "type": "string"
+                },
+                "traceID": {
                     "type": "string"
                 }
             }
@@ -0,0 +1,129 @@
+package faults
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"tempo-otlp-trace-demo/scenario"
+	"time"
+)
+
+// MaxDuration bounds how long a fault holds a call, its latency and timeout together. It stays
+// below the 15s WriteTimeout of the demo's servers, so a request failed by an injected timeout
+// is still answered with a 504 rather than a dropped connection.
+const MaxDuration = 10 * time.Second
+
+// Fault describes a fault injected into every span with a given name.
+// Latency is added first; then the call times out, panics or fails with an error,
+// in that order of precedence.
+type Fault struct {
+	SpanName     string                `json:"span_name" example:"processPayment"`
+	Latency      scenario.DurationSpec `json:"latency,omitempty"`                               // Added latency, a fixed duration or a distribution
+	ErrorRate    float64               `json:"error_rate,omitempty" example:"0.5"`              // Probability that the call fails with ErrorMessage
+	ErrorMessage string                `json:"error_message,omitempty" example:"card declined"` // Defaults to "injected error in <span>"
+	Timeout      string                `json:"timeout,omitempty" example:"3s"`                  // The call hangs for this long, then fails with a timeout error; with the latency at most 10s
+	Panic        bool                  `json:"panic,omitempty" example:"false"`                 // The call panics; the panic is recovered into an error span and fails the call
+	Probability  float64               `json:"probability,omitempty" example:"1"`               // Probability that the fault is active for a call (0 or omitted: always)
+	Source       string                `json:"source,omitempty" example:"registry"`             // "registry" or "header"
+
+	timeout time.Duration
+}
+
+// Validate checks the fault and resolves its durations
+func (f *Fault) Validate() error {
+	if f.SpanName == "" {
+		return fmt.Errorf("fault must have a span_name")
+	}
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		return fmt.Errorf("%s: error_rate must be between 0 and 1", f.SpanName)
+	}
+	if f.Probability < 0 || f.Probability > 1 {
+		return fmt.Errorf("%s: probability must be between 0 and 1", f.SpanName)
+	}
+	if err := f.Latency.Resolve(); err != nil {
+		return fmt.Errorf("%s: latency: %w", f.SpanName, err)
+	}
+
+	f.timeout = 0
+	if f.Timeout != "" {
+		timeout, err := time.ParseDuration(f.Timeout)
+		if err != nil || timeout <= 0 || timeout > MaxDuration {
+			return fmt.Errorf("%s: timeout must be a duration between 0 and %s", f.SpanName, MaxDuration)
+		}
+		f.timeout = timeout
+	}
+	if held := f.Latency.UpperBound() + f.timeout; held > MaxDuration {
+		return fmt.Errorf("%s: latency and timeout hold the call for up to %s, more than the limit of %s", f.SpanName, held, MaxDuration)
+	}
+	return nil
+}
+
+// Registry holds the faults configured at runtime, keyed by span name
+type Registry struct {
+	mu     sync.RWMutex
+	faults map[string]Fault
+}
+
+// NewRegistry creates an empty fault registry
+func NewRegistry() *Registry {
+	return &Registry{
+		faults: make(map[string]Fault),
+	}
+}
+
+// Default is the registry configured through /api/faults
+var Default = NewRegistry()
+
+// Set validates a fault and adds or replaces the fault for its span name
+func (r *Registry) Set(f Fault) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	f.Source = "registry"
+
+	r.mu.Lock()
+	r.faults[f.SpanName] = f
+	r.mu.Unlock()
+	return nil
+}
+
+// Get returns the fault for a span name
+func (r *Registry) Get(spanName string) (Fault, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f, ok := r.faults[spanName]
+	return f, ok
+}
+
+// Delete removes the fault for a span name and reports whether it existed
+func (r *Registry) Delete(spanName string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.faults[spanName]
+	delete(r.faults, spanName)
+	return ok
+}
+
+// Clear removes all faults and returns how many were removed
+func (r *Registry) Clear() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := len(r.faults)
+	r.faults = make(map[string]Fault)
+	return count
+}
+
+// List returns all faults sorted by span name
+func (r *Registry) List() []Fault {
+	r.mu.RLock()
+	list := make([]Fault, 0, len(r.faults))
+	for _, f := range r.faults {
+		list = append(list, f)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].SpanName < list[j].SpanName
+	})
+	return list
+}
@@ -0,0 +1,96 @@
+package faults
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"tempo-otlp-trace-demo/scenario"
+)
+
+// Header is the request header carrying faults that apply only to that request
+const Header = "X-Inject-Fault"
+
+// ParseHeader parses the X-Inject-Fault header.
+// Faults are separated by ";" and written as "<span name>:<key>=<value>,<key>=<value>", e.g.
+//
+//	processPayment:latency=2s;checkInventory:error_rate=1,error_message=out of stock
+//
+// Keys: latency, error_rate, error_message, timeout, panic, probability.
+// latency is a duration or a distribution: uniform:<min>:<max>, normal:<mean>:<stddev> or exponential:<mean>.
+func ParseHeader(value string) ([]Fault, error) {
+	faults := make([]Fault, 0)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		// The span name ends at the last ":" before the first option
+		colon := -1
+		if eq := strings.Index(entry, "="); eq >= 0 {
+			colon = strings.LastIndex(entry[:eq], ":")
+		}
+		if colon <= 0 {
+			return nil, fmt.Errorf("invalid fault %q: expected <span name>:<key>=<value>", entry)
+		}
+
+		f := Fault{SpanName: strings.TrimSpace(entry[:colon])}
+		for _, option := range strings.Split(entry[colon+1:], ",") {
+			key, val, ok := strings.Cut(strings.TrimSpace(option), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid fault option %q for %s: expected key=value", option, f.SpanName)
+			}
+			if err := f.setOption(strings.TrimSpace(key), strings.TrimSpace(val)); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.SpanName, err)
+			}
+		}
+
+		if err := f.Validate(); err != nil {
+			return nil, err
+		}
+		f.Source = "header"
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
+
+func (f *Fault) setOption(key, value string) error {
+	var err error
+	switch key {
+	case "latency":
+		f.Latency, err = parseLatency(value)
+	case "error_rate":
+		f.ErrorRate, err = strconv.ParseFloat(value, 64)
+	case "error_message":
+		f.ErrorMessage = value
+	case "timeout":
+		f.Timeout = value
+	case "panic":
+		f.Panic, err = strconv.ParseBool(value)
+	case "probability":
+		f.Probability, err = strconv.ParseFloat(value, 64)
+	default:
+		return fmt.Errorf("unknown fault option %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %v", key, value, err)
+	}
+	return nil
+}
+
+// parseLatency parses "2s", "uniform:100ms:500ms", "normal:1s:200ms" or "exponential:300ms"
+func parseLatency(value string) (scenario.DurationSpec, error) {
+	parts := strings.Split(value, ":")
+	switch {
+	case len(parts) == 1:
+		return scenario.DurationSpec{Distribution: scenario.DistributionConstant, Value: parts[0]}, nil
+	case parts[0] == scenario.DistributionUniform && len(parts) == 3:
+		return scenario.DurationSpec{Distribution: parts[0], Min: parts[1], Max: parts[2]}, nil
+	case parts[0] == scenario.DistributionNormal && len(parts) == 3:
+		return scenario.DurationSpec{Distribution: parts[0], Mean: parts[1], StdDev: parts[2]}, nil
+	case parts[0] == scenario.DistributionExponential && len(parts) == 2:
+		return scenario.DurationSpec{Distribution: parts[0], Mean: parts[1]}, nil
+	default:
+		return scenario.DurationSpec{}, fmt.Errorf("expected a duration, uniform:<min>:<max>, normal:<mean>:<stddev> or exponential:<mean>")
+	}
+}
@@ -0,0 +1,195 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"tempo-otlp-trace-demo/anomalies"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/random"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InjectedError is returned by Inject for injected errors, timeouts and panics
+type InjectedError struct {
+	Type    string // "error", "timeout" or "panic"
+	Message string
+
+	stack string // Stack trace of an injected panic
+}
+
+func (e *InjectedError) Error() string {
+	return e.Message
+}
+
+// StatusCode returns the HTTP status a request failed by err answers with:
+// 504 for injected timeouts, 500 for anything else
+func StatusCode(err error) int {
+	var injected *InjectedError
+	if errors.As(err, &injected) && injected.Type == "timeout" {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+type requestFaultsKey struct{}
+
+type requestHeaderKey struct{}
+
+// WithRequestHeader parses an X-Inject-Fault header value and returns a context carrying its faults,
+// keeping the value so calls to downstream services can forward it with RequestHeader
+func WithRequestHeader(ctx context.Context, value string) (context.Context, error) {
+	requestFaults, err := ParseHeader(value)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, requestHeaderKey{}, value)
+	return WithRequestFaults(ctx, requestFaults), nil
+}
+
+// RequestHeader returns the X-Inject-Fault header value the request context was created with
+func RequestHeader(ctx context.Context) string {
+	value, _ := ctx.Value(requestHeaderKey{}).(string)
+	return value
+}
+
+// WithRequestFaults returns a context carrying faults that apply only to the current request.
+// They take precedence over the registry for the same span name.
+func WithRequestFaults(ctx context.Context, faults []Fault) context.Context {
+	if len(faults) == 0 {
+		return ctx
+	}
+	byName := make(map[string]Fault, len(faults))
+	for _, f := range faults {
+		byName[f.SpanName] = f
+	}
+	return context.WithValue(ctx, requestFaultsKey{}, byName)
+}
+
+// lookup returns the fault for a span name, preferring request faults over the registry
+func lookup(ctx context.Context, spanName string) (Fault, bool) {
+	if byName, ok := ctx.Value(requestFaultsKey{}).(map[string]Fault); ok {
+		if f, ok := byName[spanName]; ok {
+			return f, true
+		}
+	}
+	return Default.Get(spanName)
+}
+
+// Inject applies the fault configured for spanName, if any, to the current call.
+// Latency is slept inline. Timeouts, injected errors and panics are recorded on span,
+// which is marked as failed, and returned as an *InjectedError; the caller returns it
+// so its parent span and the HTTP response report the failure too:
+//
+//	ctx, span := tracer.Start(ctx, "checkInventory")
+//	defer span.End()
+//	if err := faults.Inject(ctx, span, "checkInventory"); err != nil {
+//		return 0, err
+//	}
+func Inject(ctx context.Context, span trace.Span, spanName string) error {
+	f, ok := lookup(ctx, spanName)
+	if !ok {
+		return nil
+	}
+	// Draw from the request's seeded source so seeded requests fail the same way
+	rng := random.FromContext(ctx)
+	if f.Probability > 0 && f.Probability < 1 && rng.Float64() >= f.Probability {
+		return nil
+	}
+
+	span.SetAttributes(
+		attribute.Bool("fault.injected", true),
+		attribute.String("fault.source", f.Source),
+	)
+
+	// Distributions without an upper bound, such as normal, can exceed the validated bound
+	latency := min(f.Latency.Sample(rng), MaxDuration-f.timeout)
+	if latency > 0 {
+		span.SetAttributes(attribute.Int64("fault.latency_ms", latency.Milliseconds()))
+		anomalies.Record(ctx, span, anomalies.Anomaly{
+			SpanName:  spanName,
+			Type:      anomalies.TypeLatency,
+			Source:    f.Source,
+			Magnitude: float64(latency.Milliseconds()),
+			Unit:      anomalies.UnitMilliseconds,
+		})
+		clock.Sleep(ctx, latency)
+	}
+
+	if f.timeout > 0 {
+		anomalies.Record(ctx, span, anomalies.Anomaly{
+			SpanName:  spanName,
+			Type:      anomalies.TypeTimeout,
+			Source:    f.Source,
+			Magnitude: float64(f.timeout.Milliseconds()),
+			Unit:      anomalies.UnitMilliseconds,
+		})
+		clock.Sleep(ctx, f.timeout)
+		return fail(span, &InjectedError{
+			Type:    "timeout",
+			Message: fmt.Sprintf("%s timed out after %s", spanName, f.timeout),
+		})
+	}
+
+	if f.Panic {
+		message := fmt.Sprintf("injected panic in %s", spanName)
+		anomalies.Record(ctx, span, anomalies.Anomaly{
+			SpanName:  spanName,
+			Type:      anomalies.TypePanic,
+			Source:    f.Source,
+			Magnitude: 1,
+			Unit:      anomalies.UnitProbability,
+			Message:   message,
+		})
+		span.SetAttributes(attribute.Bool("panic.recovered", true))
+		return fail(span, panicAndRecover(message))
+	}
+
+	if f.ErrorRate > 0 && rng.Float64() < f.ErrorRate {
+		message := f.ErrorMessage
+		if message == "" {
+			message = fmt.Sprintf("injected error in %s", spanName)
+		}
+		anomalies.Record(ctx, span, anomalies.Anomaly{
+			SpanName:  spanName,
+			Type:      anomalies.TypeError,
+			Source:    f.Source,
+			Magnitude: f.ErrorRate,
+			Unit:      anomalies.UnitProbability,
+			Message:   message,
+		})
+		return fail(span, &InjectedError{Type: "error", Message: message})
+	}
+
+	return nil
+}
+
+// panicAndRecover raises a real panic and recovers it right away, the way a service
+// with recovery middleware would, keeping the stack trace of the panic
+func panicAndRecover(message string) (err *InjectedError) {
+	defer func() {
+		err = &InjectedError{
+			Type:    "panic",
+			Message: fmt.Sprintf("panic: %v", recover()),
+			stack:   string(debug.Stack()),
+		}
+	}()
+	panic(message)
+}
+
+// fail records an injected failure on span and marks the span as failed
+func fail(span trace.Span, err *InjectedError) error {
+	span.SetAttributes(attribute.String("fault.type", err.Type))
+	if err.stack != "" {
+		span.RecordError(err, trace.WithAttributes(attribute.String("exception.stacktrace", err.stack)))
+	} else {
+		span.RecordError(err, trace.WithStackTrace(true))
+	}
+	span.SetStatus(codes.Error, err.Message)
+	return err
+}
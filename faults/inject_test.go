@@ -0,0 +1,72 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectReturnsErrors(t *testing.T) {
+	tests := []struct {
+		fault      Fault
+		wantType   string
+		wantStatus int
+	}{
+		{fault: Fault{SpanName: "checkInventory", ErrorRate: 1, ErrorMessage: "out of stock"}, wantType: "error", wantStatus: http.StatusInternalServerError},
+		{fault: Fault{SpanName: "checkInventory", Timeout: "1ms"}, wantType: "timeout", wantStatus: http.StatusGatewayTimeout},
+		{fault: Fault{SpanName: "checkInventory", Panic: true}, wantType: "panic", wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wantType, func(t *testing.T) {
+			if err := tt.fault.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			ctx := WithRequestFaults(context.Background(), []Fault{tt.fault})
+			span := trace.SpanFromContext(ctx)
+
+			err := Inject(ctx, span, "checkInventory")
+
+			var injected *InjectedError
+			if !errors.As(err, &injected) || injected.Type != tt.wantType {
+				t.Fatalf("err = %v, want *InjectedError of type %q", err, tt.wantType)
+			}
+			// Callers wrap the error on the way up; the status must survive that
+			if got := StatusCode(fmt.Errorf("processOrder: %w", err)); got != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestInjectWithoutFault(t *testing.T) {
+	ctx := context.Background()
+	if err := Inject(ctx, trace.SpanFromContext(ctx), "noSuchSpan"); err != nil {
+		t.Errorf("err = %v, want nil", err)
+	}
+}
+
+func TestValidateLimitsHeldTime(t *testing.T) {
+	tests := []struct {
+		header  string
+		wantErr bool
+	}{
+		{header: "processPayment:timeout=10s"},
+		{header: "processPayment:latency=4s,timeout=6s"},
+		{header: "processPayment:timeout=11s", wantErr: true},
+		{header: "processPayment:latency=8s,timeout=3s", wantErr: true},
+		{header: "processPayment:latency=uniform:1s:12s", wantErr: true},
+		{header: "processPayment:latency=normal:5s:2s", wantErr: true}, // up to mean + 3 stddev
+	}
+
+	for _, tt := range tests {
+		_, err := ParseHeader(tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHeader(%q) error = %v, want error %v", tt.header, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetCriticalPath handles requests to compute the critical path of a stored trace
+// @Summary Get critical path of a trace
+// @Description Loads a trace from Tempo, rebuilds the span tree and returns the critical path together with each span's self-time (duration minus time covered by children). Spans are sorted by self-time, longest first.
+// @Tags Trace Analysis
+// @Produce json
+// @Param trace_id query string true "Trace ID"
+// @Success 200 {object} models.CriticalPathResponse
+// @Failure 400 {string} string "Missing parameter"
+// @Failure 404 {string} string "Trace not found or has no spans"
+// @Failure 502 {string} string "Failed to query Tempo"
+// @Router /api/traces/critical-path [get]
+func GetCriticalPath(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "GET /api/traces/critical-path",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/traces/critical-path"),
+	)
+
+	traceID := r.URL.Query().Get("trace_id")
+	if traceID == "" {
+		span.SetStatus(codes.Error, "missing parameter")
+		http.Error(w, "Missing required parameter: trace_id", http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(attribute.String("query.trace_id", traceID))
+
+	tempoTrace, err := tracing.QueryTraceByID(ctx, traceID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to query tempo")
+		http.Error(w, fmt.Sprintf("Failed to query trace: %v", err), queryTraceStatus(err))
+		return
+	}
+
+	tree := tracing.BuildSpanTree(tempoTrace)
+	root := tree.Root()
+	if root == nil {
+		span.SetStatus(codes.Error, "trace has no spans")
+		http.Error(w, fmt.Sprintf("Trace %s has no spans", traceID), http.StatusNotFound)
+		return
+	}
+
+	response := buildCriticalPathResponse(traceID, tree, root)
+
+	span.SetAttributes(
+		attribute.Int("trace.span_count", len(response.Spans)),
+		attribute.Int("critical_path.segments", len(response.CriticalPath)),
+	)
+	span.SetStatus(codes.Ok, "critical path computed")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// buildCriticalPathResponse assembles the critical path and per-span timings of a span tree
+func buildCriticalPathResponse(traceID string, tree *tracing.SpanTree, root *tracing.SpanNode) models.CriticalPathResponse {
+	segments := tracing.CriticalPath(root)
+
+	criticalTime := make(map[string]int64)
+	path := make([]models.CriticalPathStep, 0, len(segments))
+	mappingsLock.RLock()
+	for _, segment := range segments {
+		criticalTime[segment.SpanID] += segment.Duration
+		path = append(path, models.CriticalPathStep{
+			SpanID:       segment.SpanID,
+			SpanName:     segment.OperationName,
+			OffsetUs:     segment.StartTime - root.Span.StartTime,
+			DurationUs:   segment.Duration,
+			Duration:     tracing.FormatDuration(segment.Duration),
+			FunctionName: mappings[segment.OperationName].FunctionName,
+		})
+	}
+	mappingsLock.RUnlock()
+
+	spans := make([]models.SpanTiming, 0, len(tree.Nodes))
+	tree.Walk(func(node *tracing.SpanNode) {
+		timing := models.SpanTiming{
+			SpanID:         node.Span.SpanID,
+			SpanName:       node.Span.OperationName,
+			Depth:          node.Depth,
+			DurationUs:     node.Span.Duration,
+			SelfTimeUs:     node.SelfTime,
+			Duration:       tracing.FormatDuration(node.Span.Duration),
+			SelfTime:       tracing.FormatDuration(node.SelfTime),
+			CriticalPathUs: criticalTime[node.Span.SpanID],
+		}
+		timing.OnCriticalPath = timing.CriticalPathUs > 0
+		if node.Parent != nil {
+			timing.ParentSpanID = node.Parent.Span.SpanID
+		}
+		spans = append(spans, timing)
+	})
+
+	// Longest self-time first
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].SelfTimeUs > spans[j].SelfTimeUs
+	})
+
+	return models.CriticalPathResponse{
+		TraceID:         traceID,
+		RootSpanID:      root.Span.SpanID,
+		RootSpanName:    root.Span.OperationName,
+		TotalDurationUs: root.Span.Duration,
+		TotalDuration:   tracing.FormatDuration(root.Span.Duration),
+		CriticalPath:    path,
+		Spans:           spans,
+	}
+}
+
+// queryTraceStatus returns the status a failed trace query answers with:
+// 404 when Tempo does not have the trace, 502 for any other upstream failure
+func queryTraceStatus(err error) int {
+	if tracing.IsTraceNotFound(err) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"tempo-otlp-trace-demo/tracing"
+	"testing"
+)
+
+func TestQueryTraceStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{&tracing.StatusError{StatusCode: http.StatusNotFound, Body: "trace not found"}, http.StatusNotFound},
+		{fmt.Errorf("query: %w", &tracing.StatusError{StatusCode: http.StatusNotFound}), http.StatusNotFound},
+		{&tracing.StatusError{StatusCode: http.StatusInternalServerError}, http.StatusBadGateway},
+		{errors.New("connection refused"), http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		if got := queryTraceStatus(tt.err); got != tt.want {
+			t.Errorf("queryTraceStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"tempo-otlp-trace-demo/analyzer"
+	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Analyze handles requests to diagnose the slowest spans of a trace
+// @Summary Diagnose a trace
+// @Description Builds the analysis bundle for a trace (or uses the bundle from the request body) and passes it to an analyzer backend, returning the suspected cause, evidence spans and suggested fix. Backends: rules (deterministic, offline) and openai (OpenAI-compatible chat completions API).
+// @Tags Trace Analysis
+// @Accept json
+// @Produce json
+// @Param request body models.AnalyzeRequest true "Trace ID or analysis bundle to diagnose"
+// @Success 200 {object} models.Diagnosis
+// @Failure 400 {string} string "Invalid request"
+// @Failure 404 {string} string "Trace not found or has no spans"
+// @Failure 502 {string} string "Failed to query Tempo or analyzer failed"
+// @Router /api/analyze [post]
+func Analyze(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "POST /api/analyze",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/analyze"),
+	)
+
+	if r.Method != http.MethodPost {
+		span.SetStatus(codes.Error, "method not allowed")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.AnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid request")
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.TraceID == "" && req.Bundle == nil {
+		span.SetStatus(codes.Error, "missing parameter")
+		http.Error(w, "Missing required parameter: trace_id or bundle", http.StatusBadRequest)
+		return
+	}
+
+	backend, err := analyzer.New(req.Analyzer)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid analyzer")
+		http.Error(w, fmt.Sprintf("Invalid analyzer: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(attribute.String("analyzer.backend", backend.Name()))
+
+	bundle := req.Bundle
+	if bundle == nil {
+		top := req.Top
+		if top < 1 {
+			top = 5
+		}
+		if top > 20 {
+			top = 20
+		}
+
+		span.SetAttributes(attribute.String("query.trace_id", req.TraceID))
+
+		tempoTrace, err := tracing.QueryTraceByID(ctx, req.TraceID)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to query tempo")
+			http.Error(w, fmt.Sprintf("Failed to query Tempo: %v", err), queryTraceStatus(err))
+			return
+		}
+
+		tree := tracing.BuildSpanTree(tempoTrace)
+		root := tree.Root()
+		if root == nil {
+			span.SetStatus(codes.Error, "trace has no spans")
+			http.Error(w, fmt.Sprintf("Trace %s has no spans", req.TraceID), http.StatusNotFound)
+			return
+		}
+
+		built := buildAnalysisBundle(req.TraceID, tree, root, top)
+		bundle = &built
+	}
+
+	diagnosis, err := backend.Analyze(ctx, bundle)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "analyzer failed")
+		http.Error(w, fmt.Sprintf("Analyzer failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("diagnosis.evidence_count", len(diagnosis.EvidenceSpans)),
+		attribute.Float64("diagnosis.confidence", diagnosis.Confidence),
+	)
+	span.SetStatus(codes.Ok, "trace analyzed")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diagnosis)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"tempo-otlp-trace-demo/anomalies"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AnomaliesResponse represents the injected anomalies recorded in the ledger
+type AnomaliesResponse struct {
+	Anomalies []anomalies.Anomaly `json:"anomalies"`
+	Count     int                 `json:"count" example:"1"`
+	Total     int                 `json:"total" example:"1"` // Anomalies recorded since the ledger was cleared, including those evicted from memory
+}
+
+// AnomaliesClearResponse represents the result of clearing the ledger
+type AnomaliesClearResponse struct {
+	Status  string `json:"status" example:"success"`
+	Message string `json:"message" example:"Anomaly ledger cleared"`
+	Count   int    `json:"count" example:"1"`
+}
+
+// GetAnomalies handles requests to list injected anomalies
+// @Summary Get injected anomalies
+// @Description Returns the ground-truth ledger of anomalies injected into spans (the order sleep flag, random processItem failures, scenario errors and fault injection), with trace ID, span name, type, magnitude and timestamp. Use format=jsonl to export one anomaly per line.
+// @Tags Anomalies
+// @Produce json
+// @Produce application/x-ndjson
+// @Param trace_id query string false "Only anomalies in this trace"
+// @Param span_name query string false "Only anomalies in spans with this name"
+// @Param type query string false "Only anomalies of this type (latency, error, timeout, panic)"
+// @Param since query string false "Only anomalies injected at or after this time (RFC3339)"
+// @Param limit query int false "Return only the most recent anomalies (default: all)"
+// @Param format query string false "Response format: json (default) or jsonl"
+// @Success 200 {object} AnomaliesResponse
+// @Failure 400 {string} string "Invalid parameters"
+// @Router /api/anomalies [get]
+func GetAnomalies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "GET /api/anomalies",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/anomalies"),
+	)
+
+	query := r.URL.Query()
+	filter := anomalies.Filter{
+		TraceID:  query.Get("trace_id"),
+		SpanName: query.Get("span_name"),
+		Type:     query.Get("type"),
+	}
+	if since := query.Get("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "invalid since")
+			http.Error(w, fmt.Sprintf("Invalid since: %v", err), http.StatusBadRequest)
+			return
+		}
+		filter.Since = parsed
+	}
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 0 {
+			span.SetStatus(codes.Error, "invalid limit")
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "jsonl" {
+		span.SetStatus(codes.Error, "invalid format")
+		http.Error(w, "Invalid format: expected json or jsonl", http.StatusBadRequest)
+		return
+	}
+
+	list := anomalies.Default.List(filter)
+	span.SetAttributes(
+		attribute.Int("anomalies.count", len(list)),
+		attribute.String("response.format", format),
+	)
+	span.SetStatus(codes.Ok, "anomalies retrieved")
+
+	if format == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="anomalies.jsonl"`)
+		anomalies.WriteJSONL(w, list)
+		return
+	}
+
+	response := AnomaliesResponse{
+		Anomalies: list,
+		Count:     len(list),
+		Total:     anomalies.Default.Total(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteAnomalies handles requests to clear the anomaly ledger
+// @Summary Clear injected anomalies
+// @Description Removes all anomalies from the in-memory ledger, e.g. before a labelled load run
+// @Tags Anomalies
+// @Produce json
+// @Success 200 {object} AnomaliesClearResponse
+// @Router /api/anomalies [delete]
+func DeleteAnomalies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "DELETE /api/anomalies",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/anomalies"),
+	)
+
+	count := anomalies.Default.Clear()
+	response := AnomaliesClearResponse{
+		Status:  "success",
+		Message: "Anomaly ledger cleared",
+		Count:   count,
+	}
+
+	span.SetAttributes(attribute.Int("anomalies.count", count))
+	span.SetStatus(codes.Ok, "anomalies cleared")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -3,26 +3,35 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
+	"log/slog"
 	"net/http"
+	"tempo-otlp-trace-demo/anomalies"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/concurrency"
+	"tempo-otlp-trace-demo/faults"
 	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/random"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // ProcessBatch handles batch processing requests
 // @Summary Process a batch of items
-// @Description Processes a batch of items with comprehensive tracing. Generates 6-15 spans with 300-1500ms duration depending on batch size.
+// @Description Processes a batch of items with comprehensive tracing. Generates 6-15 spans with 300-1500ms duration depending on batch size. The processItems span links to the trace that enqueued each item (traceparents, or a stand-in upstream trace), and failed items record an exception with a stack trace. With X-Concurrency the items are processed on that many workers.
 // @Tags Batch
 // @Accept json
 // @Produce json
 // @Param request body models.BatchRequest true "Batch processing request"
 // @Success 200 {object} models.BatchResponse "Batch processed successfully"
 // @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 500 {string} string "Injected fault"
+// @Failure 504 {string} string "Injected timeout"
 // @Router /api/batch/process [post]
 func ProcessBatch(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -39,6 +48,7 @@ func ProcessBatch(w http.ResponseWriter, r *http.Request) {
 	// Parse request
 	var req models.BatchRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(ctx, "Invalid request", "error", err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "invalid request")
 		http.Error(w, "Invalid request", http.StatusBadRequest)
@@ -55,16 +65,31 @@ func ProcessBatch(w http.ResponseWriter, r *http.Request) {
 	)
 
 	// Step 1: Validate batch
-	validateBatch(ctx, req)
+	if err := validateBatch(ctx, req); err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 2: Process items (with nested spans for each item)
-	results := processItems(ctx, req.Items)
+	results, err := processItems(ctx, req.Items, req.Traceparents)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 3: Aggregate results
-	aggregated := aggregateResults(ctx, results)
+	aggregated, err := aggregateResults(ctx, results)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 4: Save results
-	batchID := saveBatchResults(ctx, aggregated)
+	batchID, err := saveBatchResults(ctx, aggregated)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Count successes and failures
 	processedCount := 0
@@ -90,50 +115,104 @@ func ProcessBatch(w http.ResponseWriter, r *http.Request) {
 		attribute.Int("batch.processed", processedCount),
 		attribute.Int("batch.failed", failedCount),
 	)
+	slog.InfoContext(ctx, "Batch processed", "batch.id", batchID, "batch.processed", processedCount, "batch.failed", failedCount)
 	span.SetStatus(codes.Ok, "batch processed")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func validateBatch(ctx context.Context, req models.BatchRequest) {
-	_, span := tracer.Start(ctx, "validateBatch")
+func validateBatch(ctx context.Context, req models.BatchRequest) error {
+	ctx, span := tracer.Start(ctx, "validateBatch")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "validateBatch"); err != nil {
+		return err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "validation"),
 		attribute.Int("batch.size", len(req.Items)),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate validation
-	time.Sleep(time.Duration(30+rand.Intn(30)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(30+rng.Intn(30))*time.Millisecond)
+	slog.DebugContext(ctx, "Batch validated")
 	span.SetStatus(codes.Ok, "batch validated")
+	return nil
 }
 
-func processItems(ctx context.Context, items []string) []string {
-	ctx, span := tracer.Start(ctx, "processItems")
+func processItems(ctx context.Context, items, traceparents []string) ([]string, error) {
+	// The items were enqueued by other traces: link to each of them (fan-in)
+	ctx, span := tracer.Start(ctx, "processItems",
+		trace.WithLinks(upstreamLinks(ctx, items, traceparents)...),
+	)
 	defer span.End()
+	if err := faults.Inject(ctx, span, "processItems"); err != nil {
+		return nil, err
+	}
 
+	limit := concurrency.Limit(ctx)
 	span.SetAttributes(
 		attribute.String("operation.type", "batch_processing"),
 		attribute.Int("items.count", len(items)),
+		attribute.Int("concurrency.limit", limit),
 	)
 
 	results := make([]string, len(items))
+	errs := make([]error, len(items))
 
-	// Process each item with its own span
+	// Process each item with its own span, on up to limit workers
+	tasks := make([]func(context.Context), len(items))
 	for i, item := range items {
-		result := processItem(ctx, i+1, item)
-		results[i] = result
+		tasks[i] = func(ctx context.Context) {
+			results[i], errs[i] = processItem(ctx, i+1, item)
+		}
+	}
+	concurrency.Run(ctx, limit, tasks...)
+	if err := errors.Join(errs...); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
 
+	slog.DebugContext(ctx, "Items processed")
 	span.SetStatus(codes.Ok, "items processed")
-	return results
+	return results, nil
+}
+
+// upstreamLinks links to the trace that enqueued each item: the given traceparent,
+// or a stand-in upstream trace drawn from the request's seed
+func upstreamLinks(ctx context.Context, items, traceparents []string) []trace.Link {
+	rng := random.FromContext(ctx)
+	links := make([]trace.Link, 0, len(items))
+	for i, item := range items {
+		source := "synthetic"
+		upstream := rng.RemoteSpanContext()
+		if i < len(traceparents) {
+			carrier := propagation.MapCarrier{"traceparent": traceparents[i]}
+			if sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(ctx, carrier)); sc.IsValid() {
+				source = "request"
+				upstream = sc
+			}
+		}
+		links = append(links, trace.Link{
+			SpanContext: upstream,
+			Attributes: []attribute.KeyValue{
+				attribute.String("messaging.message.id", item),
+				attribute.String("link.source", source),
+			},
+		})
+	}
+	return links
 }
 
-func processItem(ctx context.Context, index int, item string) string {
-	_, span := tracer.Start(ctx, fmt.Sprintf("processItem-%d", index))
+func processItem(ctx context.Context, index int, item string) (string, error) {
+	spanName := fmt.Sprintf("processItem-%d", index)
+	ctx, span := tracer.Start(ctx, spanName)
 	defer span.End()
+	if err := faults.Inject(ctx, span, spanName); err != nil {
+		return "", err
+	}
 
 	span.SetAttributes(
 		attribute.String("item.id", item),
@@ -141,34 +220,50 @@ func processItem(ctx context.Context, index int, item string) string {
 		attribute.String("operation.type", "item_processing"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate item processing
-	time.Sleep(time.Duration(50+rand.Intn(100)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(50+rng.Intn(100))*time.Millisecond)
 
 	// Randomly succeed or fail (90% success rate)
 	result := "success"
-	if rand.Float64() < 0.1 {
+	if rng.Float64() < 0.1 {
 		result = "failed"
+		slog.WarnContext(ctx, "Item processing failed", "item.id", item)
+		span.RecordError(errors.New("item processing failed"), trace.WithStackTrace(true))
 		span.SetStatus(codes.Error, "item processing failed")
 		span.SetAttributes(attribute.String("error.reason", "random_failure"))
+		anomalies.Record(ctx, span, anomalies.Anomaly{
+			SpanName:  spanName,
+			Type:      anomalies.TypeError,
+			Source:    "random",
+			Magnitude: 0.1,
+			Unit:      anomalies.UnitProbability,
+			Message:   "item processing failed",
+		})
 	} else {
+		slog.DebugContext(ctx, "Item processed")
 		span.SetStatus(codes.Ok, "item processed")
 	}
 
 	span.SetAttributes(attribute.String("item.result", result))
-	return result
+	return result, nil
 }
 
-func aggregateResults(ctx context.Context, results []string) map[string]interface{} {
-	_, span := tracer.Start(ctx, "aggregateResults")
+func aggregateResults(ctx context.Context, results []string) (map[string]interface{}, error) {
+	ctx, span := tracer.Start(ctx, "aggregateResults")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "aggregateResults"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "aggregation"),
 		attribute.Int("results.count", len(results)),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate aggregation
-	time.Sleep(time.Duration(40+rand.Intn(40)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(40+rng.Intn(40))*time.Millisecond)
 
 	successCount := 0
 	failedCount := 0
@@ -190,14 +285,18 @@ func aggregateResults(ctx context.Context, results []string) map[string]interfac
 		attribute.Int("aggregated.success", successCount),
 		attribute.Int("aggregated.failed", failedCount),
 	)
+	slog.DebugContext(ctx, "Results aggregated")
 	span.SetStatus(codes.Ok, "results aggregated")
 
-	return aggregated
+	return aggregated, nil
 }
 
-func saveBatchResults(ctx context.Context, results map[string]interface{}) string {
-	_, span := tracer.Start(ctx, "saveResults")
+func saveBatchResults(ctx context.Context, results map[string]interface{}) (string, error) {
+	ctx, span := tracer.Start(ctx, "saveResults")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "saveResults"); err != nil {
+		return "", err
+	}
 
 	span.SetAttributes(
 		attribute.String("db.system", "postgresql"),
@@ -205,12 +304,14 @@ func saveBatchResults(ctx context.Context, results map[string]interface{}) strin
 		attribute.String("db.operation", "INSERT"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate database write
-	time.Sleep(time.Duration(100+rand.Intn(100)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(100+rng.Intn(100))*time.Millisecond)
 
-	batchID := fmt.Sprintf("batch_%d", rand.Int())
+	batchID := fmt.Sprintf("batch_%d", rng.Int())
 	span.SetAttributes(attribute.String("batch.id", batchID))
+	slog.DebugContext(ctx, "Results saved", "batch.id", batchID)
 	span.SetStatus(codes.Ok, "results saved")
 
-	return batchID
+	return batchID, nil
 }
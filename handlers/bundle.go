@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetAnalysisBundle handles requests to build an LLM-ready analysis bundle for a trace
+// @Summary Get LLM-ready analysis bundle for a trace
+// @Description Loads a trace from Tempo and returns the top-N spans ranked by self-time, each with its mapped source code, attributes, parent chain and child timings. Use format=markdown to get a document that can be pasted directly into an LLM prompt.
+// @Tags Trace Analysis
+// @Produce json
+// @Produce text/markdown
+// @Param trace_id query string true "Trace ID"
+// @Param top query int false "Number of spans to include (default: 5, max: 20)"
+// @Param format query string false "Output format: json or markdown (default: json)"
+// @Success 200 {object} models.AnalysisBundle
+// @Failure 400 {string} string "Invalid parameter"
+// @Failure 404 {string} string "Trace not found or has no spans"
+// @Failure 502 {string} string "Failed to query Tempo"
+// @Router /api/traces/analysis-bundle [get]
+func GetAnalysisBundle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "GET /api/traces/analysis-bundle",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/traces/analysis-bundle"),
+	)
+
+	traceID := r.URL.Query().Get("trace_id")
+	if traceID == "" {
+		span.SetStatus(codes.Error, "missing parameter")
+		http.Error(w, "Missing required parameter: trace_id", http.StatusBadRequest)
+		return
+	}
+
+	top := getIntParam(r, "top", 5)
+	if top < 1 {
+		top = 1
+	}
+	if top > 20 {
+		top = 20
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "", "json":
+		format = "json"
+	case "markdown", "md":
+		format = "markdown"
+	default:
+		span.SetStatus(codes.Error, "invalid format")
+		http.Error(w, "Invalid format: must be json or markdown", http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("query.trace_id", traceID),
+		attribute.Int("bundle.top", top),
+		attribute.String("bundle.format", format),
+	)
+
+	tempoTrace, err := tracing.QueryTraceByID(ctx, traceID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to query tempo")
+		http.Error(w, fmt.Sprintf("Failed to query Tempo: %v", err), queryTraceStatus(err))
+		return
+	}
+
+	tree := tracing.BuildSpanTree(tempoTrace)
+	root := tree.Root()
+	if root == nil {
+		span.SetStatus(codes.Error, "trace has no spans")
+		http.Error(w, fmt.Sprintf("Trace %s has no spans", traceID), http.StatusNotFound)
+		return
+	}
+
+	bundle := buildAnalysisBundle(traceID, tree, root, top)
+
+	span.SetAttributes(attribute.Int("bundle.span_count", len(bundle.Spans)))
+	span.SetStatus(codes.Ok, "analysis bundle built")
+
+	if format == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(renderBundleMarkdown(bundle)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// buildAnalysisBundle collects the top-N spans by self-time together with their source and context
+func buildAnalysisBundle(traceID string, tree *tracing.SpanTree, root *tracing.SpanNode, top int) models.AnalysisBundle {
+	critical := buildCriticalPathResponse(traceID, tree, root)
+
+	onCriticalPath := make(map[string]bool)
+	for _, step := range critical.CriticalPath {
+		onCriticalPath[step.SpanID] = true
+	}
+
+	nodes := make([]*tracing.SpanNode, 0, len(tree.Nodes))
+	tree.Walk(func(node *tracing.SpanNode) {
+		nodes = append(nodes, node)
+	})
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].SelfTime > nodes[j].SelfTime
+	})
+	if len(nodes) > top {
+		nodes = nodes[:top]
+	}
+
+	spans := make([]models.BundleSpan, 0, len(nodes))
+	for i, node := range nodes {
+		bundleSpan := models.BundleSpan{
+			Rank:           i + 1,
+			SpanID:         node.Span.SpanID,
+			SpanName:       node.Span.OperationName,
+			DurationUs:     node.Span.Duration,
+			SelfTimeUs:     node.SelfTime,
+			Duration:       tracing.FormatDuration(node.Span.Duration),
+			SelfTime:       tracing.FormatDuration(node.SelfTime),
+			OnCriticalPath: onCriticalPath[node.Span.SpanID],
+			Attributes:     tracing.GetSpanAttributes(node.Span),
+			ParentChain:    make([]models.SpanRef, 0, node.Depth),
+			Children:       make([]models.SpanRef, 0, len(node.Children)),
+		}
+
+		// Parent chain is listed from the root down to the direct parent
+		for parent := node.Parent; parent != nil; parent = parent.Parent {
+			bundleSpan.ParentChain = append([]models.SpanRef{newSpanRef(parent)}, bundleSpan.ParentChain...)
+		}
+		for _, child := range node.Children {
+			bundleSpan.Children = append(bundleSpan.Children, newSpanRef(child))
+		}
+		for _, event := range tracing.GetSpanEvents(node.Span) {
+			if event.Name == "exception" {
+				continue
+			}
+			bundleSpan.Events = append(bundleSpan.Events, models.SpanEvent{
+				Name:       event.Name,
+				OffsetUs:   event.Timestamp - node.Span.StartTime,
+				Attributes: event.Attributes,
+			})
+		}
+		for _, exception := range tracing.GetSpanExceptions(node.Span) {
+			bundleSpan.Exceptions = append(bundleSpan.Exceptions, models.SpanException{
+				Type:       exception.Type,
+				Message:    exception.Message,
+				StackTrace: exception.StackTrace,
+			})
+		}
+		for _, link := range tracing.GetSpanLinks(node.Span) {
+			bundleSpan.Links = append(bundleSpan.Links, models.SpanLink{
+				TraceID:    link.TraceID,
+				SpanID:     link.SpanID,
+				Attributes: link.Attributes,
+			})
+		}
+
+		mappingsLock.RLock()
+		mapping, found := mappings[node.Span.OperationName]
+		mappingsLock.RUnlock()
+
+		if found {
+			bundleSpan.FilePath = mapping.FilePath
+			bundleSpan.FunctionName = mapping.FunctionName
+			bundleSpan.StartLine = mapping.StartLine
+			bundleSpan.EndLine = mapping.EndLine
+			sourceCode, err := readSourceCode(mapping.FilePath, mapping.StartLine, mapping.EndLine)
+			if err != nil {
+				bundleSpan.SourceError = err.Error()
+			} else {
+				bundleSpan.SourceCode = sourceCode
+			}
+		} else {
+			bundleSpan.SourceError = "no source code mapping"
+		}
+
+		spans = append(spans, bundleSpan)
+	}
+
+	return models.AnalysisBundle{
+		TraceID:         traceID,
+		RootSpanName:    root.Span.OperationName,
+		TotalDurationUs: root.Span.Duration,
+		TotalDuration:   tracing.FormatDuration(root.Span.Duration),
+		SpanCount:       len(tree.Nodes),
+		CriticalPath:    critical.CriticalPath,
+		Spans:           spans,
+	}
+}
+
+func newSpanRef(node *tracing.SpanNode) models.SpanRef {
+	mappingsLock.RLock()
+	functionName := mappings[node.Span.OperationName].FunctionName
+	mappingsLock.RUnlock()
+
+	return models.SpanRef{
+		SpanID:       node.Span.SpanID,
+		SpanName:     node.Span.OperationName,
+		DurationUs:   node.Span.Duration,
+		Duration:     tracing.FormatDuration(node.Span.Duration),
+		FunctionName: functionName,
+	}
+}
+
+// renderBundleMarkdown renders an analysis bundle as a Markdown document
+func renderBundleMarkdown(bundle models.AnalysisBundle) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Trace Performance Analysis: %s\n\n", bundle.RootSpanName)
+	fmt.Fprintf(&b, "- Trace ID: `%s`\n", bundle.TraceID)
+	fmt.Fprintf(&b, "- Total duration: %s\n", bundle.TotalDuration)
+	fmt.Fprintf(&b, "- Span count: %d\n\n", bundle.SpanCount)
+
+	b.WriteString("## Critical Path\n\n")
+	b.WriteString("| Offset | Span | Duration |\n|---|---|---|\n")
+	for _, step := range bundle.CriticalPath {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", tracing.FormatDuration(step.OffsetUs), step.SpanName, step.Duration)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "## Top %d Spans by Self-Time\n\n", len(bundle.Spans))
+	for _, s := range bundle.Spans {
+		fmt.Fprintf(&b, "### %d. %s\n\n", s.Rank, s.SpanName)
+		fmt.Fprintf(&b, "- Span ID: `%s`\n", s.SpanID)
+		fmt.Fprintf(&b, "- Duration: %s (self-time: %s)\n", s.Duration, s.SelfTime)
+		fmt.Fprintf(&b, "- On critical path: %t\n", s.OnCriticalPath)
+
+		if len(s.ParentChain) > 0 {
+			names := make([]string, 0, len(s.ParentChain))
+			for _, parent := range s.ParentChain {
+				names = append(names, parent.SpanName)
+			}
+			fmt.Fprintf(&b, "- Parent chain: %s\n", strings.Join(names, " → "))
+		}
+		b.WriteString("\n")
+
+		if len(s.Attributes) > 0 {
+			keys := make([]string, 0, len(s.Attributes))
+			for key := range s.Attributes {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			b.WriteString("**Attributes**\n\n")
+			for _, key := range keys {
+				fmt.Fprintf(&b, "- `%s`: %s\n", key, s.Attributes[key])
+			}
+			b.WriteString("\n")
+		}
+
+		if len(s.Children) > 0 {
+			b.WriteString("**Children**\n\n| Span | Duration |\n|---|---|\n")
+			for _, child := range s.Children {
+				fmt.Fprintf(&b, "| %s | %s |\n", child.SpanName, child.Duration)
+			}
+			b.WriteString("\n")
+		}
+
+		if len(s.Events) > 0 {
+			b.WriteString("**Events**\n\n| Offset | Event | Attributes |\n|---|---|---|\n")
+			for _, event := range s.Events {
+				fmt.Fprintf(&b, "| %s | %s | %s |\n", tracing.FormatDuration(event.OffsetUs), event.Name, formatAttributes(event.Attributes))
+			}
+			b.WriteString("\n")
+		}
+
+		if len(s.Exceptions) > 0 {
+			b.WriteString("**Exceptions**\n\n")
+			for _, exception := range s.Exceptions {
+				fmt.Fprintf(&b, "- `%s`: %s\n", exception.Type, exception.Message)
+				if exception.StackTrace != "" {
+					fmt.Fprintf(&b, "\n```\n%s\n```\n", strings.TrimSpace(exception.StackTrace))
+				}
+			}
+			b.WriteString("\n")
+		}
+
+		if len(s.Links) > 0 {
+			fmt.Fprintf(&b, "**Links** (%d)\n\n| Trace | Span | Attributes |\n|---|---|---|\n", len(s.Links))
+			for _, link := range s.Links {
+				fmt.Fprintf(&b, "| `%s` | `%s` | %s |\n", link.TraceID, link.SpanID, formatAttributes(link.Attributes))
+			}
+			b.WriteString("\n")
+		}
+
+		if s.SourceCode != "" {
+			fmt.Fprintf(&b, "**Source** (`%s:%d-%d`, `%s`)\n\n", s.FilePath, s.StartLine, s.EndLine, s.FunctionName)
+			fmt.Fprintf(&b, "```go\n%s\n```\n\n", s.SourceCode)
+		} else if s.SourceError != "" {
+			fmt.Fprintf(&b, "_Source unavailable: %s_\n\n", s.SourceError)
+		}
+	}
+
+	return b.String()
+}
+
+// formatAttributes renders attributes as a sorted, comma-separated list of key=value pairs
+func formatAttributes(attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+attrs[key])
+	}
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GetTraceDiff handles requests to compare two traces
+// @Summary Compare two traces
+// @Description Aligns the spans of a base and a target trace with the same root operation by operation name and tree position and reports duration and self-time deltas, changed attributes and added or missing spans. Spans are sorted by absolute self-time delta; the hotspot is the span whose self-time changed the most, with its mapped source code. Example: compare a normal POST /api/order/create trace (base) with one created with "sleep": true (target).
+// @Tags Trace Analysis
+// @Produce json
+// @Param base query string true "Base trace ID"
+// @Param target query string true "Target trace ID"
+// @Success 200 {object} models.TraceDiffResponse
+// @Failure 400 {string} string "Missing parameters"
+// @Failure 404 {string} string "Trace not found or has no spans"
+// @Failure 422 {string} string "Root spans differ"
+// @Failure 502 {string} string "Failed to query Tempo"
+// @Router /api/traces/diff [get]
+func GetTraceDiff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "GET /api/traces/diff",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/traces/diff"),
+	)
+
+	baseID := r.URL.Query().Get("base")
+	targetID := r.URL.Query().Get("target")
+	if baseID == "" || targetID == "" {
+		span.SetStatus(codes.Error, "missing parameters")
+		http.Error(w, "Missing required parameters: base and target", http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("query.base_trace_id", baseID),
+		attribute.String("query.target_trace_id", targetID),
+	)
+
+	roots := make([]*tracing.SpanNode, 0, 2)
+	for _, traceID := range []string{baseID, targetID} {
+		tempoTrace, err := tracing.QueryTraceByID(ctx, traceID)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "failed to query tempo")
+			http.Error(w, fmt.Sprintf("Failed to query Tempo: %v", err), queryTraceStatus(err))
+			return
+		}
+
+		root := tracing.BuildSpanTree(tempoTrace).Root()
+		if root == nil {
+			span.SetStatus(codes.Error, "trace has no spans")
+			http.Error(w, fmt.Sprintf("Trace %s has no spans", traceID), http.StatusNotFound)
+			return
+		}
+		roots = append(roots, root)
+	}
+
+	// Aligning different operations would only produce a meaningless hotspot
+	if baseRoot, targetRoot := roots[0].Span.OperationName, roots[1].Span.OperationName; baseRoot != targetRoot {
+		span.SetStatus(codes.Error, "root spans differ")
+		http.Error(w, fmt.Sprintf("Traces are not comparable: base root span %q differs from target root span %q", baseRoot, targetRoot), http.StatusUnprocessableEntity)
+		return
+	}
+
+	response := buildTraceDiffResponse(baseID, targetID, roots[0], roots[1])
+
+	span.SetAttributes(
+		attribute.Int("diff.aligned_spans", len(response.Spans)),
+		attribute.Int("diff.added_spans", len(response.Added)),
+		attribute.Int("diff.missing_spans", len(response.Missing)),
+		attribute.Int64("diff.duration_delta_us", response.DurationDeltaUs),
+	)
+	if response.Hotspot != nil {
+		span.SetAttributes(attribute.String("diff.hotspot", response.Hotspot.SpanName))
+	}
+	span.SetStatus(codes.Ok, "traces compared")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// buildTraceDiffResponse aligns two span trees and assembles the comparison
+func buildTraceDiffResponse(baseID, targetID string, base, target *tracing.SpanNode) models.TraceDiffResponse {
+	diff := tracing.DiffSpanTrees(base, target)
+
+	spans := make([]models.SpanDiff, 0, len(diff.Pairs))
+	for _, pair := range diff.Pairs {
+		durationDelta := pair.Target.Span.Duration - pair.Base.Span.Duration
+		selfTimeDelta := pair.Target.SelfTime - pair.Base.SelfTime
+
+		mappingsLock.RLock()
+		functionName := mappings[pair.Base.Span.OperationName].FunctionName
+		mappingsLock.RUnlock()
+
+		spans = append(spans, models.SpanDiff{
+			Path:              pair.Path,
+			SpanName:          pair.Base.Span.OperationName,
+			BaseSpanID:        pair.Base.Span.SpanID,
+			TargetSpanID:      pair.Target.Span.SpanID,
+			BaseDurationUs:    pair.Base.Span.Duration,
+			TargetDurationUs:  pair.Target.Span.Duration,
+			DurationDeltaUs:   durationDelta,
+			DurationDelta:     formatDelta(durationDelta),
+			SelfTimeDeltaUs:   selfTimeDelta,
+			SelfTimeDelta:     formatDelta(selfTimeDelta),
+			FunctionName:      functionName,
+			ChangedAttributes: diffAttributes(pair.Base.Span, pair.Target.Span),
+		})
+	}
+
+	// Largest self-time change first
+	sort.SliceStable(spans, func(i, j int) bool {
+		return absMicros(spans[i].SelfTimeDeltaUs) > absMicros(spans[j].SelfTimeDeltaUs)
+	})
+
+	durationDelta := target.Span.Duration - base.Span.Duration
+	response := models.TraceDiffResponse{
+		BaseTraceID:      baseID,
+		TargetTraceID:    targetID,
+		BaseDurationUs:   base.Span.Duration,
+		TargetDurationUs: target.Span.Duration,
+		DurationDeltaUs:  durationDelta,
+		DurationDelta:    formatDelta(durationDelta),
+		Spans:            spans,
+		Added:            newDiffSpans(diff.Added),
+		Missing:          newDiffSpans(diff.Missing),
+	}
+
+	if len(spans) > 0 && spans[0].SelfTimeDeltaUs != 0 {
+		response.Hotspot = newDiffHotspot(spans[0])
+	}
+
+	return response
+}
+
+// newDiffHotspot attaches the mapped source code to the span diff
+func newDiffHotspot(spanDiff models.SpanDiff) *models.DiffHotspot {
+	hotspot := &models.DiffHotspot{SpanDiff: spanDiff}
+
+	mappingsLock.RLock()
+	mapping, found := mappings[spanDiff.SpanName]
+	mappingsLock.RUnlock()
+
+	if !found {
+		hotspot.SourceError = "no source code mapping"
+		return hotspot
+	}
+
+	hotspot.FilePath = mapping.FilePath
+	hotspot.StartLine = mapping.StartLine
+	hotspot.EndLine = mapping.EndLine
+	sourceCode, err := readSourceCode(mapping.FilePath, mapping.StartLine, mapping.EndLine)
+	if err != nil {
+		hotspot.SourceError = err.Error()
+	} else {
+		hotspot.SourceCode = sourceCode
+	}
+	return hotspot
+}
+
+func newDiffSpans(unmatched []tracing.UnmatchedSpan) []models.DiffSpan {
+	spans := make([]models.DiffSpan, 0, len(unmatched))
+	for _, u := range unmatched {
+		mappingsLock.RLock()
+		functionName := mappings[u.Node.Span.OperationName].FunctionName
+		mappingsLock.RUnlock()
+
+		spans = append(spans, models.DiffSpan{
+			Path:         u.Path,
+			SpanID:       u.Node.Span.SpanID,
+			SpanName:     u.Node.Span.OperationName,
+			DurationUs:   u.Node.Span.Duration,
+			Duration:     tracing.FormatDuration(u.Node.Span.Duration),
+			FunctionName: functionName,
+		})
+	}
+	return spans
+}
+
+// diffAttributes lists the attributes whose values differ between two spans, sorted by key
+func diffAttributes(base, target *tracing.TempoSpan) []models.AttributeChange {
+	baseAttrs := tracing.GetSpanAttributes(base)
+	targetAttrs := tracing.GetSpanAttributes(target)
+
+	changes := make([]models.AttributeChange, 0)
+	for key, value := range baseAttrs {
+		if targetValue, ok := targetAttrs[key]; !ok || targetValue != value {
+			changes = append(changes, models.AttributeChange{Key: key, Base: value, Target: targetValue})
+		}
+	}
+	for key, value := range targetAttrs {
+		if _, ok := baseAttrs[key]; !ok {
+			changes = append(changes, models.AttributeChange{Key: key, Target: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// formatDelta formats a signed duration delta in microseconds, e.g. "+5.00s"
+func formatDelta(deltaMicros int64) string {
+	if deltaMicros < 0 {
+		return "-" + tracing.FormatDuration(-deltaMicros)
+	}
+	return "+" + tracing.FormatDuration(deltaMicros)
+}
+
+func absMicros(micros int64) int64 {
+	if micros < 0 {
+		return -micros
+	}
+	return micros
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"tempo-otlp-trace-demo/faults"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FaultsRequest represents the faults to add or replace
+type FaultsRequest struct {
+	Faults []faults.Fault `json:"faults"`
+}
+
+// FaultsResponse represents the configured faults
+type FaultsResponse struct {
+	Faults []faults.Fault `json:"faults"`
+	Count  int            `json:"count" example:"1"`
+}
+
+// FaultUpdateResponse represents the result of a fault registry update
+type FaultUpdateResponse struct {
+	Status  string `json:"status" example:"success"`
+	Message string `json:"message" example:"Faults updated successfully"`
+	Count   int    `json:"count" example:"1"`
+}
+
+// GetFaults handles requests to list the configured faults
+// @Summary Get injected faults
+// @Description Returns the faults configured in the fault-injection registry, keyed by span name
+// @Tags Faults
+// @Produce json
+// @Success 200 {object} FaultsResponse
+// @Router /api/faults [get]
+func GetFaults(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "GET /api/faults",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/faults"),
+	)
+
+	list := faults.Default.List()
+	response := FaultsResponse{
+		Faults: list,
+		Count:  len(list),
+	}
+
+	span.SetAttributes(attribute.Int("faults.count", len(list)))
+	span.SetStatus(codes.Ok, "faults retrieved")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateFaults handles requests to add or replace faults
+// @Summary Add or replace injected faults
+// @Description Adds faults to the registry, replacing any fault for the same span name. Every span created by the demo handlers with that name gets the added latency (fixed or distribution), timeout, panic or error rate, activated with the given probability.
+// @Tags Faults
+// @Accept json
+// @Produce json
+// @Param request body FaultsRequest true "Faults to add or replace"
+// @Success 200 {object} FaultUpdateResponse
+// @Failure 400 {string} string "Invalid request"
+// @Router /api/faults [post]
+func UpdateFaults(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "POST /api/faults",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/faults"),
+	)
+
+	var req FaultsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid request")
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Faults) == 0 {
+		span.SetStatus(codes.Error, "no faults provided")
+		http.Error(w, "No faults provided", http.StatusBadRequest)
+		return
+	}
+
+	// Validate all faults before changing the registry
+	for i := range req.Faults {
+		if err := req.Faults[i].Validate(); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "invalid fault")
+			http.Error(w, fmt.Sprintf("Invalid fault: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, f := range req.Faults {
+		faults.Default.Set(f)
+	}
+
+	response := FaultUpdateResponse{
+		Status:  "success",
+		Message: "Faults updated successfully",
+		Count:   len(req.Faults),
+	}
+
+	span.SetAttributes(attribute.Int("faults.count", len(req.Faults)))
+	span.SetStatus(codes.Ok, "faults updated")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteFaults handles requests to remove faults
+// @Summary Remove injected faults
+// @Description Removes the fault for a span name, or all faults when span_name is omitted
+// @Tags Faults
+// @Produce json
+// @Param span_name query string false "Span name whose fault to remove (default: all)"
+// @Success 200 {object} FaultUpdateResponse
+// @Failure 404 {string} string "Fault not found"
+// @Router /api/faults [delete]
+func DeleteFaults(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "DELETE /api/faults",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/faults"),
+	)
+
+	response := FaultUpdateResponse{Status: "success"}
+
+	spanName := r.URL.Query().Get("span_name")
+	if spanName == "" {
+		response.Count = faults.Default.Clear()
+		response.Message = "All faults deleted successfully"
+	} else {
+		span.SetAttributes(attribute.String("span.name", spanName))
+		if !faults.Default.Delete(spanName) {
+			span.SetStatus(codes.Error, "fault not found")
+			http.Error(w, "Fault not found", http.StatusNotFound)
+			return
+		}
+		response.Count = 1
+		response.Message = fmt.Sprintf("Fault for '%s' deleted successfully", spanName)
+	}
+
+	span.SetStatus(codes.Ok, "faults deleted")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// failRequest marks the request span as failed by err, returned by one of its steps,
+// and answers with faults.StatusCode(err): 504 for injected timeouts, 500 otherwise
+func failRequest(ctx context.Context, w http.ResponseWriter, span trace.Span, err error) {
+	failRequestWithStatus(ctx, w, span, err, faults.StatusCode(err))
+}
+
+// failRequestWithStatus marks the request span as failed by err and answers with status
+func failRequestWithStatus(ctx context.Context, w http.ResponseWriter, span trace.Span, err error, status int) {
+	slog.ErrorContext(ctx, "Request failed", "error", err, "http.status_code", status)
+	span.SetAttributes(attribute.Int("http.status_code", status))
+	span.SetStatus(codes.Error, err.Error())
+	http.Error(w, err.Error(), status)
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"log/slog"
 	"net/http"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/faults"
 	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/random"
+	"tempo-otlp-trace-demo/services"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -19,13 +23,16 @@ var tracer = otel.Tracer("trace-demo-service")
 
 // CreateOrder handles order creation with comprehensive tracing
 // @Summary Create a new order
-// @Description Creates an order with comprehensive tracing. Generates 10-12 spans with 600-1500ms duration. If sleep=true, adds 5s delay to payment processing to simulate slow operation.
+// @Description Creates an order with comprehensive tracing. Inventory, payment and notification are separate services called over HTTP, so the trace has 18 spans across 4 services with 600-1500ms duration. If sleep=true, adds 5s delay to payment processing to simulate slow operation. With X-Concurrency the email and SMS are sent concurrently.
 // @Tags Orders
 // @Accept json
 // @Produce json
 // @Param request body models.OrderRequest true "Order creation request"
 // @Success 200 {object} models.OrderResponse "Order created successfully"
 // @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 500 {string} string "Injected fault"
+// @Failure 502 {string} string "Inventory, payment or notification service failed"
+// @Failure 504 {string} string "Injected timeout"
 // @Router /api/order/create [post]
 func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -42,6 +49,7 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	// Parse request
 	var req models.OrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(ctx, "Invalid request", "error", err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "invalid request")
 		http.Error(w, "Invalid request", http.StatusBadRequest)
@@ -56,26 +64,52 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	)
 
 	// Step 1: Validate order
-	validateOrder(ctx, req)
+	if err := validateOrder(ctx, req); err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
-	// Step 2: Check inventory
-	checkInventory(ctx, req.ProductID, req.Quantity)
+	// Step 2: Check inventory (inventory-service)
+	if err := services.Inventory.Call(ctx, http.MethodPost, "/inventory/check",
+		services.InventoryRequest{ProductID: req.ProductID, Quantity: req.Quantity}, nil); err != nil {
+		failRequestWithStatus(ctx, w, span, err, http.StatusBadGateway)
+		return
+	}
 
 	// Step 3: Calculate price
-	totalCost := calculatePrice(ctx, req.Price, req.Quantity)
+	totalCost, err := calculatePrice(ctx, req.Price, req.Quantity)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
-	// Step 4: Process payment (with nested spans)
+	// Step 4: Process payment (payment-service, with nested spans)
 	// If sleep=true, simulate slow payment processing (5 seconds delay)
-	processPayment(ctx, req.UserID, totalCost, req.Sleep)
+	if err := services.Payment.Call(ctx, http.MethodPost, "/payment/charge",
+		services.PaymentRequest{UserID: req.UserID, Amount: totalCost, Slow: req.Sleep}, nil); err != nil {
+		failRequestWithStatus(ctx, w, span, err, http.StatusBadGateway)
+		return
+	}
 
 	// Step 5: Create shipment
-	createShipment(ctx, req.UserID, req.ProductID)
+	if err := createShipment(ctx, req.UserID, req.ProductID); err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
-	// Step 6: Send notifications (with nested spans)
-	sendNotification(ctx, req.UserID, "order_created")
+	// Step 6: Send notifications (notification-service, with nested spans)
+	if err := services.Notification.Call(ctx, http.MethodPost, "/notification/send",
+		services.NotificationRequest{UserID: req.UserID, Type: "order_created"}, nil); err != nil {
+		failRequestWithStatus(ctx, w, span, err, http.StatusBadGateway)
+		return
+	}
 
 	// Step 7: Save to database
-	orderID := saveToDatabase(ctx, "orders", req)
+	orderID, err := saveToDatabase(ctx, "orders", req)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Return response
 	response := models.OrderResponse{
@@ -89,185 +123,84 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 		attribute.String("order.id", orderID),
 		attribute.Float64("order.total_cost", totalCost),
 	)
+	slog.InfoContext(ctx, "Order created", "order.id", orderID, "order.total_cost", totalCost)
 	span.SetStatus(codes.Ok, "order created")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func validateOrder(ctx context.Context, req models.OrderRequest) {
-	_, span := tracer.Start(ctx, "validateOrder")
+func validateOrder(ctx context.Context, req models.OrderRequest) error {
+	ctx, span := tracer.Start(ctx, "validateOrder")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "validateOrder"); err != nil {
+		return err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "validation"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate validation work
-	time.Sleep(time.Duration(50+rand.Intn(50)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(50+rng.Intn(50))*time.Millisecond)
+	slog.DebugContext(ctx, "Validation passed")
 	span.SetStatus(codes.Ok, "validation passed")
+	return nil
 }
 
-func checkInventory(ctx context.Context, productID string, quantity int) {
-	_, span := tracer.Start(ctx, "checkInventory")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("product.id", productID),
-		attribute.Int("requested.quantity", quantity),
-		attribute.String("db.system", "postgresql"),
-		attribute.String("db.statement", "SELECT stock FROM inventory WHERE product_id = $1"),
-	)
-
-	// Simulate database query
-	time.Sleep(time.Duration(100+rand.Intn(100)) * time.Millisecond)
-	span.SetAttributes(attribute.Int("available.quantity", 100))
-	span.SetStatus(codes.Ok, "inventory available")
-}
-
-func calculatePrice(ctx context.Context, price float64, quantity int) float64 {
-	_, span := tracer.Start(ctx, "calculatePrice")
+func calculatePrice(ctx context.Context, price float64, quantity int) (float64, error) {
+	ctx, span := tracer.Start(ctx, "calculatePrice")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "calculatePrice"); err != nil {
+		return 0, err
+	}
 
 	span.SetAttributes(
 		attribute.Float64("unit.price", price),
 		attribute.Int("quantity", quantity),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate calculation
-	time.Sleep(time.Duration(30+rand.Intn(50)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(30+rng.Intn(50))*time.Millisecond)
 
 	totalCost := price * float64(quantity)
 	span.SetAttributes(attribute.Float64("total.cost", totalCost))
+	slog.DebugContext(ctx, "Price calculated")
 	span.SetStatus(codes.Ok, "price calculated")
 
-	return totalCost
+	return totalCost, nil
 }
 
-func processPayment(ctx context.Context, userID string, amount float64, simulateSlow bool) {
-	ctx, span := tracer.Start(ctx, "processPayment")
+func createShipment(ctx context.Context, userID, productID string) error {
+	ctx, span := tracer.Start(ctx, "createShipment")
 	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("user.id", userID),
-		attribute.Float64("payment.amount", amount),
-		attribute.String("operation.type", "payment"),
-		attribute.Bool("simulate.slow", simulateSlow),
-	)
-
-	// If simulateSlow is true, add 5 seconds delay to simulate anomaly
-	if simulateSlow {
-		span.SetAttributes(attribute.String("slow.reason", "simulated_delay"))
-		time.Sleep(5 * time.Second)
+	if err := faults.Inject(ctx, span, "createShipment"); err != nil {
+		return err
 	}
 
-	// Nested: Call payment gateway
-	callPaymentGateway(ctx, amount)
-
-	// Nested: Record transaction
-	recordTransaction(ctx, userID, amount)
-
-	span.SetStatus(codes.Ok, "payment processed")
-}
-
-func callPaymentGateway(ctx context.Context, amount float64) {
-	_, span := tracer.Start(ctx, "callPaymentGateway")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("payment.gateway", "stripe"),
-		attribute.Float64("payment.amount", amount),
-		attribute.String("http.method", "POST"),
-		attribute.String("http.url", "https://api.stripe.com/v1/charges"),
-	)
-
-	// Simulate external API call
-	time.Sleep(time.Duration(150+rand.Intn(250)) * time.Millisecond)
-	span.SetAttributes(attribute.String("payment.transaction_id", fmt.Sprintf("txn_%d", rand.Int())))
-	span.SetStatus(codes.Ok, "payment gateway success")
-}
-
-func recordTransaction(ctx context.Context, userID string, amount float64) {
-	_, span := tracer.Start(ctx, "recordTransaction")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("user.id", userID),
-		attribute.Float64("transaction.amount", amount),
-		attribute.String("db.system", "postgresql"),
-		attribute.String("db.statement", "INSERT INTO transactions (user_id, amount) VALUES ($1, $2)"),
-	)
-
-	// Simulate database write
-	time.Sleep(time.Duration(20+rand.Intn(30)) * time.Millisecond)
-	span.SetStatus(codes.Ok, "transaction recorded")
-}
-
-func createShipment(ctx context.Context, userID, productID string) {
-	_, span := tracer.Start(ctx, "createShipment")
-	defer span.End()
-
 	span.SetAttributes(
 		attribute.String("user.id", userID),
 		attribute.String("product.id", productID),
 		attribute.String("operation.type", "shipment"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate shipment creation
-	time.Sleep(time.Duration(80+rand.Intn(70)) * time.Millisecond)
-	span.SetAttributes(attribute.String("shipment.id", fmt.Sprintf("ship_%d", rand.Int())))
+	clock.Sleep(ctx, time.Duration(80+rng.Intn(70))*time.Millisecond)
+	span.SetAttributes(attribute.String("shipment.id", fmt.Sprintf("ship_%d", rng.Int())))
+	slog.DebugContext(ctx, "Shipment created")
 	span.SetStatus(codes.Ok, "shipment created")
+	return nil
 }
 
-func sendNotification(ctx context.Context, userID, notificationType string) {
-	ctx, span := tracer.Start(ctx, "sendNotification")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("user.id", userID),
-		attribute.String("notification.type", notificationType),
-	)
-
-	// Nested: Send email
-	sendEmail(ctx, userID)
-
-	// Nested: Send SMS
-	sendSMS(ctx, userID)
-
-	span.SetStatus(codes.Ok, "notifications sent")
-}
-
-func sendEmail(ctx context.Context, userID string) {
-	_, span := tracer.Start(ctx, "sendEmail")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("user.id", userID),
-		attribute.String("email.provider", "sendgrid"),
-	)
-
-	// Simulate email sending
-	time.Sleep(time.Duration(30+rand.Intn(30)) * time.Millisecond)
-	span.SetStatus(codes.Ok, "email sent")
-}
-
-func sendSMS(ctx context.Context, userID string) {
-	_, span := tracer.Start(ctx, "sendSMS")
-	defer span.End()
-
-	span.SetAttributes(
-		attribute.String("user.id", userID),
-		attribute.String("sms.provider", "twilio"),
-	)
-
-	// Simulate SMS sending
-	time.Sleep(time.Duration(20+rand.Intn(20)) * time.Millisecond)
-	span.SetStatus(codes.Ok, "sms sent")
-}
-
-func saveToDatabase(ctx context.Context, table string, data interface{}) string {
-	_, span := tracer.Start(ctx, "saveToDatabase")
+func saveToDatabase(ctx context.Context, table string, data interface{}) (string, error) {
+	ctx, span := tracer.Start(ctx, "saveToDatabase")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "saveToDatabase"); err != nil {
+		return "", err
+	}
 
 	span.SetAttributes(
 		attribute.String("db.system", "postgresql"),
@@ -275,12 +208,14 @@ func saveToDatabase(ctx context.Context, table string, data interface{}) string
 		attribute.String("db.operation", "INSERT"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate database write
-	time.Sleep(time.Duration(100+rand.Intn(200)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(100+rng.Intn(200))*time.Millisecond)
 
-	id := fmt.Sprintf("%s_%d", table, rand.Int())
+	id := fmt.Sprintf("%s_%d", table, rng.Int())
 	span.SetAttributes(attribute.String("record.id", id))
+	slog.DebugContext(ctx, "Data saved")
 	span.SetStatus(codes.Ok, "data saved")
 
-	return id
+	return id, nil
 }
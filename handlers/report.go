@@ -3,10 +3,15 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
+	"log/slog"
 	"net/http"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/concurrency"
+	"tempo-otlp-trace-demo/faults"
 	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/random"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -16,17 +21,19 @@ import (
 
 // GenerateReport handles report generation (long-running operation)
 // @Summary Generate a report
-// @Description Generates a report with comprehensive tracing. LONG TRACE - Generates 10-12 spans with 1500-3500ms duration.
+// @Description Generates a report with comprehensive tracing. LONG TRACE - Generates 10-12 spans with 1500-3500ms duration. generatePDF sometimes records a gc.pause span event. With X-Concurrency the three data sources are fetched concurrently.
 // @Tags Reports
 // @Accept json
 // @Produce json
 // @Param request body models.ReportRequest true "Report generation request"
 // @Success 200 {object} models.ReportResponse "Report generated successfully"
 // @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 500 {string} string "Injected fault"
+// @Failure 504 {string} string "Injected timeout"
 // @Router /api/report/generate [post]
 func GenerateReport(w http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
 	ctx := r.Context()
+	startTime := clock.Now(ctx)
 	ctx, span := tracer.Start(ctx, "POST /api/report/generate",
 		trace.WithSpanKind(trace.SpanKindServer),
 	)
@@ -40,6 +47,7 @@ func GenerateReport(w http.ResponseWriter, r *http.Request) {
 	// Parse request
 	var req models.ReportRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(ctx, "Invalid request", "error", err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "invalid request")
 		http.Error(w, "Invalid request", http.StatusBadRequest)
@@ -53,25 +61,48 @@ func GenerateReport(w http.ResponseWriter, r *http.Request) {
 	)
 
 	// Step 1: Validate request
-	validateReportRequest(ctx, req)
+	if err := validateReportRequest(ctx, req); err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 2: Fetch data from multiple sources (with nested spans)
-	data := fetchDataFromMultipleSources(ctx, req)
+	data, err := fetchDataFromMultipleSources(ctx, req)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 3: Process data (with nested spans)
-	processedData := processReportData(ctx, data)
+	processedData, err := processReportData(ctx, data)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 4: Generate PDF (long operation)
-	pdfURL := generatePDF(ctx, processedData, req.ReportType)
+	pdfURL, err := generatePDF(ctx, processedData, req.ReportType)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 5: Upload to storage
-	storageURL := uploadToStorage(ctx, pdfURL)
+	storageURL, err := uploadToStorage(ctx, pdfURL)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 6: Notify user
-	notifyUser(ctx, "report_ready", storageURL)
+	if err := notifyUser(ctx, "report_ready", storageURL); err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
-	reportID := fmt.Sprintf("report_%d", rand.Int())
-	duration := time.Since(startTime)
+	rng := random.FromContext(ctx)
+	reportID := fmt.Sprintf("report_%d", rng.Int())
+	duration := clock.Since(ctx, startTime)
 
 	response := models.ReportResponse{
 		ReportID: reportID,
@@ -86,42 +117,59 @@ func GenerateReport(w http.ResponseWriter, r *http.Request) {
 		attribute.String("report.url", storageURL),
 		attribute.Int64("report.duration_ms", duration.Milliseconds()),
 	)
+	slog.InfoContext(ctx, "Report generated", "report.id", reportID, "report.duration_ms", duration.Milliseconds())
 	span.SetStatus(codes.Ok, "report generated")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func validateReportRequest(ctx context.Context, req models.ReportRequest) {
-	_, span := tracer.Start(ctx, "validateRequest")
+func validateReportRequest(ctx context.Context, req models.ReportRequest) error {
+	ctx, span := tracer.Start(ctx, "validateRequest")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "validateRequest"); err != nil {
+		return err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "validation"),
 		attribute.String("report.type", req.ReportType),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate validation
-	time.Sleep(time.Duration(30+rand.Intn(30)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(30+rng.Intn(30))*time.Millisecond)
+	slog.DebugContext(ctx, "Validation passed")
 	span.SetStatus(codes.Ok, "validation passed")
+	return nil
 }
 
-func fetchDataFromMultipleSources(ctx context.Context, req models.ReportRequest) map[string]interface{} {
+func fetchDataFromMultipleSources(ctx context.Context, req models.ReportRequest) (map[string]interface{}, error) {
 	ctx, span := tracer.Start(ctx, "fetchDataFromMultipleSources")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "fetchDataFromMultipleSources"); err != nil {
+		return nil, err
+	}
 
+	limit := concurrency.Limit(ctx)
 	span.SetAttributes(
 		attribute.String("operation.type", "data_fetching"),
+		attribute.Int("concurrency.limit", limit),
 	)
 
-	// Nested: Query main database
-	mainData := queryMainDB(ctx, req)
-
-	// Nested: Query analytics database
-	analyticsData := queryAnalyticsDB(ctx, req)
-
-	// Nested: Fetch from external API
-	externalData := fetchExternalAPI(ctx, req)
+	// Nested: Query the main and analytics databases and the external API,
+	// concurrently when the request allows it
+	var mainData, analyticsData, externalData map[string]interface{}
+	var mainErr, analyticsErr, externalErr error
+	concurrency.Run(ctx, limit,
+		func(ctx context.Context) { mainData, mainErr = queryMainDB(ctx, req) },
+		func(ctx context.Context) { analyticsData, analyticsErr = queryAnalyticsDB(ctx, req) },
+		func(ctx context.Context) { externalData, externalErr = fetchExternalAPI(ctx, req) },
+	)
+	if err := errors.Join(mainErr, analyticsErr, externalErr); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
 
 	data := map[string]interface{}{
 		"main":      mainData,
@@ -130,13 +178,17 @@ func fetchDataFromMultipleSources(ctx context.Context, req models.ReportRequest)
 	}
 
 	span.SetAttributes(attribute.Int("data.sources", 3))
+	slog.DebugContext(ctx, "Data fetched")
 	span.SetStatus(codes.Ok, "data fetched")
-	return data
+	return data, nil
 }
 
-func queryMainDB(ctx context.Context, req models.ReportRequest) map[string]interface{} {
-	_, span := tracer.Start(ctx, "queryMainDB")
+func queryMainDB(ctx context.Context, req models.ReportRequest) (map[string]interface{}, error) {
+	ctx, span := tracer.Start(ctx, "queryMainDB")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "queryMainDB"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("db.system", "postgresql"),
@@ -144,8 +196,9 @@ func queryMainDB(ctx context.Context, req models.ReportRequest) map[string]inter
 		attribute.String("db.statement", "SELECT * FROM transactions WHERE date BETWEEN $1 AND $2"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate long database query
-	time.Sleep(time.Duration(200+rand.Intn(200)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(200+rng.Intn(200))*time.Millisecond)
 
 	data := map[string]interface{}{
 		"records": 1000,
@@ -153,13 +206,17 @@ func queryMainDB(ctx context.Context, req models.ReportRequest) map[string]inter
 	}
 
 	span.SetAttributes(attribute.Int("query.records", 1000))
+	slog.DebugContext(ctx, "Main DB query complete")
 	span.SetStatus(codes.Ok, "main db query complete")
-	return data
+	return data, nil
 }
 
-func queryAnalyticsDB(ctx context.Context, req models.ReportRequest) map[string]interface{} {
-	_, span := tracer.Start(ctx, "queryAnalyticsDB")
+func queryAnalyticsDB(ctx context.Context, req models.ReportRequest) (map[string]interface{}, error) {
+	ctx, span := tracer.Start(ctx, "queryAnalyticsDB")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "queryAnalyticsDB"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("db.system", "clickhouse"),
@@ -167,8 +224,9 @@ func queryAnalyticsDB(ctx context.Context, req models.ReportRequest) map[string]
 		attribute.String("db.statement", "SELECT * FROM events WHERE timestamp BETWEEN $1 AND $2"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate analytics query
-	time.Sleep(time.Duration(200+rand.Intn(200)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(200+rng.Intn(200))*time.Millisecond)
 
 	data := map[string]interface{}{
 		"records": 5000,
@@ -176,13 +234,17 @@ func queryAnalyticsDB(ctx context.Context, req models.ReportRequest) map[string]
 	}
 
 	span.SetAttributes(attribute.Int("query.records", 5000))
+	slog.DebugContext(ctx, "Analytics DB query complete")
 	span.SetStatus(codes.Ok, "analytics db query complete")
-	return data
+	return data, nil
 }
 
-func fetchExternalAPI(ctx context.Context, req models.ReportRequest) map[string]interface{} {
-	_, span := tracer.Start(ctx, "fetchExternalAPI")
+func fetchExternalAPI(ctx context.Context, req models.ReportRequest) (map[string]interface{}, error) {
+	ctx, span := tracer.Start(ctx, "fetchExternalAPI")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "fetchExternalAPI"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("http.method", "GET"),
@@ -190,8 +252,9 @@ func fetchExternalAPI(ctx context.Context, req models.ReportRequest) map[string]
 		attribute.String("external.service", "data_provider"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate external API call
-	time.Sleep(time.Duration(100+rand.Intn(200)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(100+rng.Intn(200))*time.Millisecond)
 
 	data := map[string]interface{}{
 		"records": 500,
@@ -199,43 +262,60 @@ func fetchExternalAPI(ctx context.Context, req models.ReportRequest) map[string]
 	}
 
 	span.SetAttributes(attribute.Int("api.records", 500))
+	slog.DebugContext(ctx, "External API call complete")
 	span.SetStatus(codes.Ok, "external api call complete")
-	return data
+	return data, nil
 }
 
-func processReportData(ctx context.Context, data map[string]interface{}) map[string]interface{} {
+func processReportData(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
 	ctx, span := tracer.Start(ctx, "processData")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "processData"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "data_processing"),
 	)
 
 	// Nested: Aggregate data
-	aggregated := aggregateData(ctx, data)
+	aggregated, err := aggregateData(ctx, data)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
 
 	// Nested: Calculate metrics
-	metrics := calculateMetrics(ctx, aggregated)
+	metrics, err := calculateMetrics(ctx, aggregated)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
 
 	processed := map[string]interface{}{
 		"aggregated": aggregated,
 		"metrics":    metrics,
 	}
 
+	slog.DebugContext(ctx, "Data processed")
 	span.SetStatus(codes.Ok, "data processed")
-	return processed
+	return processed, nil
 }
 
-func aggregateData(ctx context.Context, data map[string]interface{}) map[string]interface{} {
-	_, span := tracer.Start(ctx, "aggregateData")
+func aggregateData(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	ctx, span := tracer.Start(ctx, "aggregateData")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "aggregateData"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "aggregation"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate data aggregation
-	time.Sleep(time.Duration(150+rand.Intn(250)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(150+rng.Intn(250))*time.Millisecond)
 
 	aggregated := map[string]interface{}{
 		"total_records": 6500,
@@ -243,20 +323,25 @@ func aggregateData(ctx context.Context, data map[string]interface{}) map[string]
 	}
 
 	span.SetAttributes(attribute.Int("aggregated.records", 6500))
+	slog.DebugContext(ctx, "Data aggregated")
 	span.SetStatus(codes.Ok, "data aggregated")
-	return aggregated
+	return aggregated, nil
 }
 
-func calculateMetrics(ctx context.Context, data map[string]interface{}) map[string]interface{} {
-	_, span := tracer.Start(ctx, "calculateMetrics")
+func calculateMetrics(ctx context.Context, data map[string]interface{}) (map[string]interface{}, error) {
+	ctx, span := tracer.Start(ctx, "calculateMetrics")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "calculateMetrics"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "calculation"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate metrics calculation
-	time.Sleep(time.Duration(150+rand.Intn(250)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(150+rng.Intn(250))*time.Millisecond)
 
 	metrics := map[string]interface{}{
 		"average": 125.5,
@@ -265,13 +350,17 @@ func calculateMetrics(ctx context.Context, data map[string]interface{}) map[stri
 	}
 
 	span.SetAttributes(attribute.Int("metrics.count", 3))
+	slog.DebugContext(ctx, "Metrics calculated")
 	span.SetStatus(codes.Ok, "metrics calculated")
-	return metrics
+	return metrics, nil
 }
 
-func generatePDF(ctx context.Context, data map[string]interface{}, reportType string) string {
-	_, span := tracer.Start(ctx, "generatePDF")
+func generatePDF(ctx context.Context, data map[string]interface{}, reportType string) (string, error) {
+	ctx, span := tracer.Start(ctx, "generatePDF")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "generatePDF"); err != nil {
+		return "", err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "pdf_generation"),
@@ -279,22 +368,40 @@ func generatePDF(ctx context.Context, data map[string]interface{}, reportType st
 		attribute.String("pdf.library", "wkhtmltopdf"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate PDF generation (long operation)
-	time.Sleep(time.Duration(400+rand.Intn(800)) * time.Millisecond)
+	render := time.Duration(400+rng.Intn(800)) * time.Millisecond
+	clock.Sleep(ctx, render/2)
+
+	// Rendering allocates heavily; sometimes a GC pause stalls it halfway
+	if rng.Float64() < 0.3 {
+		pause := time.Duration(20+rng.Intn(130)) * time.Millisecond
+		span.AddEvent("gc.pause", trace.WithAttributes(
+			attribute.Int64("gc.pause_ms", pause.Milliseconds()),
+			attribute.Int("gc.heap_mb", 256+rng.Intn(512)),
+		))
+		slog.DebugContext(ctx, "GC pause during PDF rendering", "gc.pause_ms", pause.Milliseconds())
+		clock.Sleep(ctx, pause)
+	}
+	clock.Sleep(ctx, render-render/2)
 
-	pdfPath := fmt.Sprintf("/tmp/report_%d.pdf", rand.Int())
+	pdfPath := fmt.Sprintf("/tmp/report_%d.pdf", rng.Int())
 	span.SetAttributes(
 		attribute.String("pdf.path", pdfPath),
 		attribute.Int("pdf.pages", 25),
 	)
+	slog.DebugContext(ctx, "PDF generated", "pdf.path", pdfPath)
 	span.SetStatus(codes.Ok, "pdf generated")
 
-	return pdfPath
+	return pdfPath, nil
 }
 
-func uploadToStorage(ctx context.Context, filePath string) string {
-	_, span := tracer.Start(ctx, "uploadToStorage")
+func uploadToStorage(ctx context.Context, filePath string) (string, error) {
+	ctx, span := tracer.Start(ctx, "uploadToStorage")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "uploadToStorage"); err != nil {
+		return "", err
+	}
 
 	span.SetAttributes(
 		attribute.String("storage.provider", "s3"),
@@ -302,26 +409,34 @@ func uploadToStorage(ctx context.Context, filePath string) string {
 		attribute.String("file.path", filePath),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate file upload
-	time.Sleep(time.Duration(200+rand.Intn(300)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(200+rng.Intn(300))*time.Millisecond)
 
-	url := fmt.Sprintf("https://s3.amazonaws.com/reports/report_%d.pdf", rand.Int())
+	url := fmt.Sprintf("https://s3.amazonaws.com/reports/report_%d.pdf", rng.Int())
 	span.SetAttributes(attribute.String("storage.url", url))
+	slog.DebugContext(ctx, "File uploaded", "storage.url", url)
 	span.SetStatus(codes.Ok, "file uploaded")
 
-	return url
+	return url, nil
 }
 
-func notifyUser(ctx context.Context, notificationType, message string) {
-	_, span := tracer.Start(ctx, "notifyUser")
+func notifyUser(ctx context.Context, notificationType, message string) error {
+	ctx, span := tracer.Start(ctx, "notifyUser")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "notifyUser"); err != nil {
+		return err
+	}
 
 	span.SetAttributes(
 		attribute.String("notification.type", notificationType),
 		attribute.String("notification.message", message),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate notification
-	time.Sleep(time.Duration(50+rand.Intn(50)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(50+rng.Intn(50))*time.Millisecond)
+	slog.DebugContext(ctx, "User notified")
 	span.SetStatus(codes.Ok, "user notified")
+	return nil
 }
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/scenario"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxScenarioSize limits the size of a scenario document
+const maxScenarioSize = 1 << 20
+
+// scenarioWriteGrace is how long after a scenario's run deadline its response may still be written
+const scenarioWriteGrace = 10 * time.Second
+
+// RunScenario handles requests to generate a trace from a declarative scenario
+// @Summary Run a trace scenario
+// @Description Generates a trace from a scenario written in YAML or JSON. Each span declares its name, kind, duration (a fixed value or a constant/uniform/normal/exponential distribution), attributes, error probability, repeat count and children, which run sequentially or in parallel. A scenario may run for at most 2 minutes on its critical path; the response is written when the run ends, past the server's 15s write timeout if needed. See the scenarios/ directory for examples.
+// @Tags Simulation
+// @Accept json
+// @Accept application/x-yaml
+// @Produce json
+// @Param request body scenario.Scenario true "Scenario to run"
+// @Success 200 {object} models.ScenarioRunResponse "Scenario completed"
+// @Failure 400 {string} string "Invalid scenario"
+// @Router /api/scenarios/run [post]
+func RunScenario(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "POST /api/scenarios/run",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/scenarios/run"),
+	)
+
+	if r.Method != http.MethodPost {
+		span.SetStatus(codes.Error, "method not allowed")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxScenarioSize))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid request")
+		http.Error(w, fmt.Sprintf("Failed to read scenario: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	s, err := scenario.Parse(body)
+	if err != nil {
+		slog.WarnContext(ctx, "Invalid scenario", "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid scenario")
+		http.Error(w, fmt.Sprintf("Invalid scenario: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("scenario.name", s.Name),
+		attribute.Int("scenario.planned_spans", s.Root.SpanCount()),
+	)
+
+	// A valid scenario may run for longer than the server's WriteTimeout;
+	// keep the connection writable until the run's deadline so the response still arrives
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(scenario.MaxRunDuration + scenarioWriteGrace)); err != nil {
+		slog.WarnContext(ctx, "Failed to extend the write deadline", "error", err)
+	}
+
+	startTime := clock.Now(ctx)
+	result := scenario.NewRunner(tracer).Run(ctx, s)
+	totalDuration := clock.Since(ctx, startTime)
+
+	traceID := span.SpanContext().TraceID().String()
+
+	response := models.ScenarioRunResponse{
+		TraceID:    traceID,
+		Scenario:   s.Name,
+		SpanCount:  result.SpanCount,
+		ErrorCount: result.ErrorCount,
+		Duration:   totalDuration.String(),
+		TimedOut:   result.TimedOut,
+		Message:    fmt.Sprintf("Scenario %s generated %d spans", s.Name, result.SpanCount),
+	}
+	if result.TimedOut {
+		response.Message += fmt.Sprintf(", cut short after %s", scenario.MaxRunDuration)
+	}
+
+	span.SetAttributes(
+		attribute.String("trace.id", traceID),
+		attribute.Int("trace.span_count", result.SpanCount),
+		attribute.Int("scenario.error_count", result.ErrorCount),
+		attribute.Int64("trace.duration_ms", totalDuration.Milliseconds()),
+	)
+	if result.TimedOut {
+		slog.WarnContext(ctx, "Scenario timed out", "scenario.name", s.Name, "trace.span_count", result.SpanCount, "scenario.max_run_duration", scenario.MaxRunDuration.String())
+		span.SetAttributes(attribute.Bool("scenario.timed_out", true))
+		span.SetStatus(codes.Error, "scenario timed out")
+	} else {
+		slog.InfoContext(ctx, "Scenario completed", "scenario.name", s.Name, "trace.span_count", result.SpanCount, "scenario.error_count", result.ErrorCount)
+		span.SetStatus(codes.Ok, "scenario completed")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
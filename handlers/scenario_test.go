@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"tempo-otlp-trace-demo/models"
+	"testing"
+	"time"
+)
+
+func TestRunScenarioOutlivesWriteTimeout(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(RunScenario))
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	scenario := `
+name: slow
+root:
+  name: root
+  duration: 200ms`
+	resp, err := http.Post(server.URL, "application/x-yaml", strings.NewReader(scenario))
+	if err != nil {
+		t.Fatalf("scenario running past the write timeout got no response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result models.ScenarioRunResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.SpanCount != 1 {
+		t.Errorf("status %d, response %+v", resp.StatusCode, result)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/faults"
 	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/random"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -24,6 +27,8 @@ import (
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Results per page (default: 10)"
 // @Success 200 {object} models.SearchResponse "Search completed successfully"
+// @Failure 500 {string} string "Injected fault"
+// @Failure 504 {string} string "Injected timeout"
 // @Router /api/search [get]
 func Search(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -64,19 +69,39 @@ func Search(w http.ResponseWriter, r *http.Request) {
 	)
 
 	// Step 1: Parse query
-	parsedQuery := parseQuery(ctx, query)
+	parsedQuery, err := parseQuery(ctx, query)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 2: Search index
-	results := searchIndex(ctx, parsedQuery, limit)
+	results, err := searchIndex(ctx, parsedQuery, limit)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 3: Rank results
-	rankedResults := rankResults(ctx, results)
+	rankedResults, err := rankResults(ctx, results)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 4: Fetch details (with nested batch query)
-	detailedResults := fetchDetails(ctx, rankedResults)
+	detailedResults, err := fetchDetails(ctx, rankedResults)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 5: Apply filters
-	filteredResults := applyFilters(ctx, detailedResults)
+	filteredResults, err := applyFilters(ctx, detailedResults)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Build response
 	response := models.SearchResponse{
@@ -88,34 +113,43 @@ func Search(w http.ResponseWriter, r *http.Request) {
 	span.SetAttributes(
 		attribute.Int("search.results_count", len(filteredResults)),
 	)
+	slog.InfoContext(ctx, "Search completed", "search.query", query, "search.results_count", len(filteredResults))
 	span.SetStatus(codes.Ok, "search completed")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func parseQuery(ctx context.Context, query string) string {
-	_, span := tracer.Start(ctx, "parseQuery")
+func parseQuery(ctx context.Context, query string) (string, error) {
+	ctx, span := tracer.Start(ctx, "parseQuery")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "parseQuery"); err != nil {
+		return "", err
+	}
 
 	span.SetAttributes(
 		attribute.String("search.query", query),
 		attribute.String("operation.type", "query_parsing"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate query parsing
-	time.Sleep(time.Duration(10+rand.Intn(20)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(10+rng.Intn(20))*time.Millisecond)
 
 	parsedQuery := fmt.Sprintf("parsed:%s", query)
 	span.SetAttributes(attribute.String("search.parsed_query", parsedQuery))
+	slog.DebugContext(ctx, "Query parsed")
 	span.SetStatus(codes.Ok, "query parsed")
 
-	return parsedQuery
+	return parsedQuery, nil
 }
 
-func searchIndex(ctx context.Context, query string, limit int) []map[string]interface{} {
-	_, span := tracer.Start(ctx, "searchIndex")
+func searchIndex(ctx context.Context, query string, limit int) ([]map[string]interface{}, error) {
+	ctx, span := tracer.Start(ctx, "searchIndex")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "searchIndex"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("search.query", query),
@@ -124,43 +158,53 @@ func searchIndex(ctx context.Context, query string, limit int) []map[string]inte
 		attribute.String("search.index", "products"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate index search
-	time.Sleep(time.Duration(80+rand.Intn(120)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(80+rng.Intn(120))*time.Millisecond)
 
 	// Generate mock results
 	results := make([]map[string]interface{}, limit)
 	for i := 0; i < limit; i++ {
 		results[i] = map[string]interface{}{
 			"id":    fmt.Sprintf("item_%d", i+1),
-			"score": rand.Float64() * 100,
+			"score": rng.Float64() * 100,
 		}
 	}
 
 	span.SetAttributes(attribute.Int("search.hits", len(results)))
+	slog.DebugContext(ctx, "Index searched")
 	span.SetStatus(codes.Ok, "index searched")
 
-	return results
+	return results, nil
 }
 
-func rankResults(ctx context.Context, results []map[string]interface{}) []map[string]interface{} {
-	_, span := tracer.Start(ctx, "rankResults")
+func rankResults(ctx context.Context, results []map[string]interface{}) ([]map[string]interface{}, error) {
+	ctx, span := tracer.Start(ctx, "rankResults")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "rankResults"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "ranking"),
 		attribute.Int("results.count", len(results)),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate ranking algorithm
-	time.Sleep(time.Duration(40+rand.Intn(60)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(40+rng.Intn(60))*time.Millisecond)
 
+	slog.DebugContext(ctx, "Results ranked")
 	span.SetStatus(codes.Ok, "results ranked")
-	return results
+	return results, nil
 }
 
-func fetchDetails(ctx context.Context, results []map[string]interface{}) []models.SearchResult {
+func fetchDetails(ctx context.Context, results []map[string]interface{}) ([]models.SearchResult, error) {
 	ctx, span := tracer.Start(ctx, "fetchDetails")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "fetchDetails"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "detail_fetching"),
@@ -168,15 +212,23 @@ func fetchDetails(ctx context.Context, results []map[string]interface{}) []model
 	)
 
 	// Nested: Batch query for details
-	detailedResults := batchQuery(ctx, results)
+	detailedResults, err := batchQuery(ctx, results)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
 
+	slog.DebugContext(ctx, "Details fetched")
 	span.SetStatus(codes.Ok, "details fetched")
-	return detailedResults
+	return detailedResults, nil
 }
 
-func batchQuery(ctx context.Context, results []map[string]interface{}) []models.SearchResult {
-	_, span := tracer.Start(ctx, "batchQuery")
+func batchQuery(ctx context.Context, results []map[string]interface{}) ([]models.SearchResult, error) {
+	ctx, span := tracer.Start(ctx, "batchQuery")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "batchQuery"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("db.system", "postgresql"),
@@ -184,8 +236,9 @@ func batchQuery(ctx context.Context, results []map[string]interface{}) []models.
 		attribute.Int("batch.size", len(results)),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate batch database query
-	time.Sleep(time.Duration(50+rand.Intn(80)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(50+rng.Intn(80))*time.Millisecond)
 
 	// Convert to SearchResult
 	searchResults := make([]models.SearchResult, len(results))
@@ -199,25 +252,31 @@ func batchQuery(ctx context.Context, results []map[string]interface{}) []models.
 	}
 
 	span.SetAttributes(attribute.Int("query.records", len(searchResults)))
+	slog.DebugContext(ctx, "Batch query complete")
 	span.SetStatus(codes.Ok, "batch query complete")
 
-	return searchResults
+	return searchResults, nil
 }
 
-func applyFilters(ctx context.Context, results []models.SearchResult) []models.SearchResult {
-	_, span := tracer.Start(ctx, "applyFilters")
+func applyFilters(ctx context.Context, results []models.SearchResult) ([]models.SearchResult, error) {
+	ctx, span := tracer.Start(ctx, "applyFilters")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "applyFilters"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "filtering"),
 		attribute.Int("results.count", len(results)),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate filter application
-	time.Sleep(time.Duration(20+rand.Intn(30)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(20+rng.Intn(30))*time.Millisecond)
 
 	span.SetAttributes(attribute.Int("filtered.count", len(results)))
+	slog.DebugContext(ctx, "Filters applied")
 	span.SetStatus(codes.Ok, "filters applied")
 
-	return results
+	return results, nil
 }
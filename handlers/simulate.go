@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/faults"
 	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/random"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -25,6 +28,8 @@ import (
 // @Param duration query int false "Base duration in milliseconds (default: 100, max: 1000)"
 // @Param variance query number false "Duration variance factor (default: 0.5, max: 1.0)"
 // @Success 200 {object} models.SimulateResponse "Simulation completed successfully"
+// @Failure 500 {string} string "Injected fault"
+// @Failure 504 {string} string "Injected timeout"
 // @Router /api/simulate [get]
 func Simulate(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -65,13 +70,16 @@ func Simulate(w http.ResponseWriter, r *http.Request) {
 		attribute.Float64("simulate.variance", variance),
 	)
 
-	startTime := time.Now()
+	startTime := clock.Now(ctx)
 	spanCount := 0
 
 	// Generate trace tree recursively
-	spanCount = generateTraceTree(ctx, 1, depth, breadth, duration, variance, &spanCount)
+	if err := generateTraceTree(ctx, 1, depth, breadth, duration, variance, &spanCount); err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
-	totalDuration := time.Since(startTime)
+	totalDuration := clock.Since(ctx, startTime)
 
 	// Get trace ID from span context
 	traceID := span.SpanContext().TraceID().String()
@@ -88,52 +96,67 @@ func Simulate(w http.ResponseWriter, r *http.Request) {
 		attribute.Int("trace.span_count", spanCount),
 		attribute.Int64("trace.duration_ms", totalDuration.Milliseconds()),
 	)
+	slog.InfoContext(ctx, "Simulation completed", "trace.span_count", spanCount)
 	span.SetStatus(codes.Ok, "simulation completed")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func generateTraceTree(ctx context.Context, currentDepth, maxDepth, breadth, baseDuration int, variance float64, spanCount *int) int {
+func generateTraceTree(ctx context.Context, currentDepth, maxDepth, breadth, baseDuration int, variance float64, spanCount *int) error {
 	if currentDepth > maxDepth {
-		return *spanCount
+		return nil
 	}
 
 	// Create spans at current level
 	for i := 0; i < breadth; i++ {
-		spanName := fmt.Sprintf("level-%d-span-%d", currentDepth, i+1)
-		ctx, span := tracer.Start(ctx, spanName)
-
-		span.SetAttributes(
-			attribute.Int("span.depth", currentDepth),
-			attribute.Int("span.breadth_index", i+1),
-			attribute.String("span.type", "simulated"),
-		)
-
-		*spanCount++
-
-		// Calculate duration with variance
-		minDuration := int(float64(baseDuration) * (1.0 - variance))
-		maxDuration := int(float64(baseDuration) * (1.0 + variance))
-		if minDuration < 1 {
-			minDuration = 1
+		if err := generateSpan(ctx, currentDepth, i+1, maxDepth, breadth, baseDuration, variance, spanCount); err != nil {
+			return err
 		}
-		actualDuration := minDuration + rand.Intn(maxDuration-minDuration+1)
+	}
 
-		// Simulate work
-		time.Sleep(time.Duration(actualDuration) * time.Millisecond)
+	return nil
+}
 
-		// Recursively create child spans
-		if currentDepth < maxDepth {
-			generateTraceTree(ctx, currentDepth+1, maxDepth, breadth, baseDuration, variance, spanCount)
-		}
+func generateSpan(ctx context.Context, currentDepth, index, maxDepth, breadth, baseDuration int, variance float64, spanCount *int) error {
+	spanName := fmt.Sprintf("level-%d-span-%d", currentDepth, index)
+	ctx, span := tracer.Start(ctx, spanName)
+	defer span.End()
+	if err := faults.Inject(ctx, span, spanName); err != nil {
+		return err
+	}
+
+	span.SetAttributes(
+		attribute.Int("span.depth", currentDepth),
+		attribute.Int("span.breadth_index", index),
+		attribute.String("span.type", "simulated"),
+	)
 
-		span.SetAttributes(attribute.Int("span.duration_ms", actualDuration))
-		span.SetStatus(codes.Ok, "span completed")
-		span.End()
+	*spanCount++
+
+	// Calculate duration with variance
+	minDuration := int(float64(baseDuration) * (1.0 - variance))
+	maxDuration := int(float64(baseDuration) * (1.0 + variance))
+	if minDuration < 1 {
+		minDuration = 1
+	}
+	rng := random.FromContext(ctx)
+	actualDuration := minDuration + rng.Intn(maxDuration-minDuration+1)
+
+	// Simulate work
+	clock.Sleep(ctx, time.Duration(actualDuration)*time.Millisecond)
+
+	// Recursively create child spans
+	if currentDepth < maxDepth {
+		if err := generateTraceTree(ctx, currentDepth+1, maxDepth, breadth, baseDuration, variance, spanCount); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
 	}
 
-	return *spanCount
+	span.SetAttributes(attribute.Int("span.duration_ms", actualDuration))
+	span.SetStatus(codes.Ok, "span completed")
+	return nil
 }
 
 func getIntParam(r *http.Request, key string, defaultValue int) int {
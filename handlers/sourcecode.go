@@ -4,12 +4,14 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/tracing"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -30,7 +32,7 @@ type MappingFile struct {
 // init loads the source code mappings on startup
 func init() {
 	if err := LoadMappings(); err != nil {
-		fmt.Printf("Warning: Failed to load source code mappings: %v\n", err)
+		slog.Warn("Failed to load source code mappings", "error", err)
 		mappings = make(map[string]models.SourceCodeMapping)
 	}
 }
@@ -178,6 +180,119 @@ func GetSourceCode(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetSpanSourceCode handles requests to retrieve source code for a span stored in Tempo
+// @Summary Get source code for a span in a trace
+// @Description Queries Tempo for the trace, finds the span and returns its mapped source code together with duration, attributes and child spans
+// @Tags Source Code
+// @Produce json
+// @Param span_id query string true "Span ID"
+// @Param trace_id query string true "Trace ID"
+// @Success 200 {object} models.SpanSourceCodeResponse
+// @Failure 400 {string} string "Missing parameter"
+// @Failure 404 {string} string "Trace, span or mapping not found"
+// @Failure 502 {string} string "Failed to query Tempo"
+// @Router /api/source-code [get]
+func GetSpanSourceCode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "GET /api/source-code",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/source-code"),
+	)
+
+	spanID := r.URL.Query().Get("span_id")
+	traceID := r.URL.Query().Get("trace_id")
+	if spanID == "" || traceID == "" {
+		span.SetStatus(codes.Error, "missing parameter")
+		http.Error(w, "Missing required parameters: span_id and trace_id", http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("query.trace_id", traceID),
+		attribute.String("query.span_id", spanID),
+	)
+
+	// Query the trace from Tempo
+	tempoTrace, err := tracing.QueryTraceByID(ctx, traceID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to query tempo")
+		http.Error(w, fmt.Sprintf("Failed to query trace: %v", err), queryTraceStatus(err))
+		return
+	}
+
+	targetSpan := tracing.FindSpanByID(tempoTrace, spanID)
+	if targetSpan == nil {
+		span.SetStatus(codes.Error, "span not found")
+		http.Error(w, fmt.Sprintf("Span %s not found in trace %s", spanID, traceID), http.StatusNotFound)
+		return
+	}
+
+	span.SetAttributes(attribute.String("span.name", targetSpan.OperationName))
+
+	// Look up source code mapping
+	mappingsLock.RLock()
+	mapping, found := mappings[targetSpan.OperationName]
+	mappingsLock.RUnlock()
+
+	if !found {
+		span.SetStatus(codes.Error, "mapping not found")
+		http.Error(w, fmt.Sprintf("No source code mapping found for span: %s", targetSpan.OperationName), http.StatusNotFound)
+		return
+	}
+
+	sourceCode, err := readSourceCode(mapping.FilePath, mapping.StartLine, mapping.EndLine)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to read source code")
+		http.Error(w, fmt.Sprintf("Failed to read source code: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Collect direct children with their mapped functions
+	children := tracing.FindChildSpans(tempoTrace, targetSpan.SpanID)
+	childSpans := make([]models.ChildSpanInfo, 0, len(children))
+	mappingsLock.RLock()
+	for _, child := range children {
+		childSpans = append(childSpans, models.ChildSpanInfo{
+			SpanID:       child.SpanID,
+			SpanName:     child.OperationName,
+			Duration:     tracing.FormatDuration(child.Duration),
+			FunctionName: mappings[child.OperationName].FunctionName,
+		})
+	}
+	mappingsLock.RUnlock()
+
+	response := models.SpanSourceCodeResponse{
+		SpanID:       targetSpan.SpanID,
+		SpanName:     targetSpan.OperationName,
+		TraceID:      traceID,
+		Duration:     tracing.FormatDuration(targetSpan.Duration),
+		FilePath:     mapping.FilePath,
+		FunctionName: mapping.FunctionName,
+		StartLine:    mapping.StartLine,
+		EndLine:      mapping.EndLine,
+		SourceCode:   sourceCode,
+		Attributes:   tracing.GetSpanAttributes(targetSpan),
+		ChildSpans:   childSpans,
+	}
+
+	span.SetAttributes(
+		attribute.String("source.file_path", mapping.FilePath),
+		attribute.String("source.function_name", mapping.FunctionName),
+		attribute.Int("span.child_count", len(childSpans)),
+	)
+	span.SetStatus(codes.Ok, "source code retrieved")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // readSourceCode reads specific lines from a source file
 func readSourceCode(filePath string, startLine, endLine int) (string, error) {
 	// Get the project root directory
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestMappingsPointAtFunctions checks source_code_mappings.json against the code: a stale range
+// after moving code shows the wrong snippet in /api/source-code, the bundles and the analyzer.
+// Regenerate it with go run ./scripts/update-source-mappings.go.
+func TestMappingsPointAtFunctions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", mappingsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mappingFile MappingFile
+	if err := json.Unmarshal(data, &mappingFile); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mapping := range mappingFile.Mappings {
+		source, err := os.ReadFile(filepath.Join("..", mapping.FilePath))
+		if err != nil {
+			t.Errorf("%s: %v", mapping.SpanName, err)
+			continue
+		}
+		lines := strings.Split(string(source), "\n")
+		if mapping.StartLine < 1 || mapping.EndLine > len(lines) || mapping.StartLine > mapping.EndLine {
+			t.Errorf("%s: lines %d-%d are outside %s", mapping.SpanName, mapping.StartLine, mapping.EndLine, mapping.FilePath)
+			continue
+		}
+
+		funcLine := regexp.MustCompile(`^func (\([^)]*\) )?` + regexp.QuoteMeta(mapping.FunctionName) + `[\[(]`)
+		if line := lines[mapping.StartLine-1]; !funcLine.MatchString(line) {
+			t.Errorf("%s: %s:%d is %q, want func %s", mapping.SpanName, mapping.FilePath, mapping.StartLine, line, mapping.FunctionName)
+		}
+		if line := lines[mapping.EndLine-1]; line != "}" {
+			t.Errorf("%s: %s:%d is %q, want the closing brace of %s", mapping.SpanName, mapping.FilePath, mapping.EndLine, line, mapping.FunctionName)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"tempo-otlp-trace-demo/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SearchTraces handles TraceQL search requests proxied to Tempo
+// @Summary Search traces in Tempo
+// @Description Proxies Tempo's /api/search with TraceQL, tag filters, duration bounds, limits and time ranges. Example: q={ name = "POST /api/order/create" && duration > 2s }&since=1h
+// @Tags Trace Analysis
+// @Produce json
+// @Param q query string false "TraceQL query"
+// @Param tags query string false "Tag filters in logfmt, e.g. http.method=POST"
+// @Param min_duration query string false "Minimum trace duration, e.g. 2s"
+// @Param max_duration query string false "Maximum trace duration, e.g. 10s"
+// @Param limit query int false "Maximum number of traces (default: 20, max: 1000)"
+// @Param spss query int false "Spans per span set"
+// @Param since query string false "Relative time range ending now, e.g. 1h (overrides start/end)"
+// @Param start query string false "Start of time range (unix seconds or RFC3339)"
+// @Param end query string false "End of time range (unix seconds or RFC3339)"
+// @Success 200 {object} tracing.SearchResponse
+// @Failure 400 {string} string "Invalid parameter"
+// @Failure 502 {string} string "Failed to query Tempo"
+// @Router /api/traces/search [get]
+func SearchTraces(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx, span := tracer.Start(ctx, "GET /api/traces/search",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", "/api/traces/search"),
+	)
+
+	params, err := parseSearchParams(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid parameter")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("search.query", params.Query),
+		attribute.Int("search.limit", params.Limit),
+	)
+
+	result, err := tracing.SearchTraces(ctx, params)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to query tempo")
+		http.Error(w, fmt.Sprintf("Failed to query Tempo: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	span.SetAttributes(attribute.Int("search.results_count", len(result.Traces)))
+	span.SetStatus(codes.Ok, "traces searched")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func parseSearchParams(r *http.Request) (tracing.SearchParams, error) {
+	query := r.URL.Query()
+	params := tracing.SearchParams{
+		Query:           query.Get("q"),
+		MinDuration:     query.Get("min_duration"),
+		MaxDuration:     query.Get("max_duration"),
+		Limit:           getIntParam(r, "limit", 20),
+		SpansPerSpanSet: getIntParam(r, "spss", 0),
+	}
+
+	if params.Limit < 1 {
+		params.Limit = 1
+	}
+	if params.Limit > 1000 {
+		params.Limit = 1000
+	}
+
+	for _, d := range []string{params.MinDuration, params.MaxDuration} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			return params, fmt.Errorf("invalid duration %q: %w", d, err)
+		}
+	}
+
+	if tags := query.Get("tags"); tags != "" {
+		parsed, err := tracing.ParseLogfmt(tags)
+		if err != nil {
+			return params, fmt.Errorf("invalid tags: %w", err)
+		}
+		params.Tags = parsed
+	}
+
+	if since := query.Get("since"); since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil || d <= 0 {
+			return params, fmt.Errorf("invalid since %q: expected a positive duration like 1h", since)
+		}
+		params.End = time.Now()
+		params.Start = params.End.Add(-d)
+		return params, nil
+	}
+
+	var err error
+	if params.Start, err = parseSearchTime(query.Get("start")); err != nil {
+		return params, fmt.Errorf("invalid start: %w", err)
+	}
+	if params.End, err = parseSearchTime(query.Get("end")); err != nil {
+		return params, fmt.Errorf("invalid end: %w", err)
+	}
+	if !params.Start.IsZero() && params.End.IsZero() {
+		params.End = time.Now()
+	}
+
+	return params, nil
+}
+
+// parseSearchTime parses unix seconds or an RFC3339 timestamp; an empty value yields the zero time
+func parseSearchTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -3,9 +3,12 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"math/rand"
+	"log/slog"
 	"net/http"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/faults"
 	"tempo-otlp-trace-demo/models"
+	"tempo-otlp-trace-demo/random"
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -15,11 +18,13 @@ import (
 
 // GetUserProfile handles user profile retrieval
 // @Summary Get user profile
-// @Description Retrieves user profile information. Generates 4-5 spans with 110-310ms duration.
+// @Description Retrieves user profile information. Generates 4-5 spans with 110-310ms duration. loadPreferences records cache.hit or cache.miss/cache.fill span events.
 // @Tags Users
 // @Produce json
 // @Param user_id query string false "User ID (default: user_12345)"
 // @Success 200 {object} models.UserProfileResponse "User profile retrieved successfully"
+// @Failure 500 {string} string "Injected fault"
+// @Failure 504 {string} string "Injected timeout"
 // @Router /api/user/profile [get]
 func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -42,17 +47,33 @@ func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	span.SetAttributes(attribute.String("user.id", userID))
 
 	// Step 1: Authenticate
-	authenticate(ctx, userID)
+	if err := authenticate(ctx, userID); err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 2: Query database
-	userData := queryDatabase(ctx, "users", userID)
+	userData, err := queryDatabase(ctx, "users", userID)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 3: Load preferences
-	preferences := loadPreferences(ctx, userID)
+	preferences, err := loadPreferences(ctx, userID)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
 	// Step 4: Format response
-	response := formatResponse(ctx, userData, preferences)
+	response, err := formatResponse(ctx, userData, preferences)
+	if err != nil {
+		failRequest(ctx, w, span, err)
+		return
+	}
 
+	slog.InfoContext(ctx, "Profile retrieved", "user.id", userID)
 	span.SetStatus(codes.Ok, "profile retrieved")
 	span.SetAttributes(attribute.Int("http.status_code", 200))
 
@@ -60,23 +81,32 @@ func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func authenticate(ctx context.Context, userID string) {
-	_, span := tracer.Start(ctx, "authenticate")
+func authenticate(ctx context.Context, userID string) error {
+	ctx, span := tracer.Start(ctx, "authenticate")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "authenticate"); err != nil {
+		return err
+	}
 
 	span.SetAttributes(
 		attribute.String("user.id", userID),
 		attribute.String("auth.method", "jwt"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate authentication
-	time.Sleep(time.Duration(20+rand.Intn(30)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(20+rng.Intn(30))*time.Millisecond)
+	slog.DebugContext(ctx, "Authenticated")
 	span.SetStatus(codes.Ok, "authenticated")
+	return nil
 }
 
-func queryDatabase(ctx context.Context, table, id string) map[string]interface{} {
-	_, span := tracer.Start(ctx, "queryDatabase")
+func queryDatabase(ctx context.Context, table, id string) (map[string]interface{}, error) {
+	ctx, span := tracer.Start(ctx, "queryDatabase")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "queryDatabase"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("db.system", "postgresql"),
@@ -86,8 +116,9 @@ func queryDatabase(ctx context.Context, table, id string) map[string]interface{}
 		attribute.String("record.id", id),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate database query
-	time.Sleep(time.Duration(50+rand.Intn(100)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(50+rng.Intn(100))*time.Millisecond)
 
 	data := map[string]interface{}{
 		"id":    id,
@@ -95,13 +126,17 @@ func queryDatabase(ctx context.Context, table, id string) map[string]interface{}
 		"email": "john@example.com",
 	}
 
+	slog.DebugContext(ctx, "Query successful")
 	span.SetStatus(codes.Ok, "query successful")
-	return data
+	return data, nil
 }
 
-func loadPreferences(ctx context.Context, userID string) map[string]string {
-	_, span := tracer.Start(ctx, "loadPreferences")
+func loadPreferences(ctx context.Context, userID string) (map[string]string, error) {
+	ctx, span := tracer.Start(ctx, "loadPreferences")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "loadPreferences"); err != nil {
+		return nil, err
+	}
 
 	span.SetAttributes(
 		attribute.String("user.id", userID),
@@ -109,8 +144,28 @@ func loadPreferences(ctx context.Context, userID string) map[string]string {
 		attribute.String("db.operation", "GET"),
 	)
 
-	// Simulate cache/database query
-	time.Sleep(time.Duration(30+rand.Intn(50)) * time.Millisecond)
+	rng := random.FromContext(ctx)
+	// Simulate cache lookup
+	clock.Sleep(ctx, time.Duration(5+rng.Intn(10))*time.Millisecond)
+
+	cacheKey := "prefs:" + userID
+	hit := rng.Float64() >= 0.25
+	span.SetAttributes(attribute.Bool("cache.hit", hit))
+	if hit {
+		span.AddEvent("cache.hit", trace.WithAttributes(attribute.String("cache.key", cacheKey)))
+	} else {
+		// Cache miss: fall back to the database and fill the cache
+		slog.DebugContext(ctx, "Preferences cache miss", "cache.key", cacheKey)
+		span.AddEvent("cache.miss", trace.WithAttributes(
+			attribute.String("cache.key", cacheKey),
+			attribute.String("cache.fallback", "postgresql"),
+		))
+		clock.Sleep(ctx, time.Duration(30+rng.Intn(50))*time.Millisecond)
+		span.AddEvent("cache.fill", trace.WithAttributes(
+			attribute.String("cache.key", cacheKey),
+			attribute.Int("cache.ttl_seconds", 300),
+		))
+	}
 
 	preferences := map[string]string{
 		"theme":    "dark",
@@ -119,20 +174,25 @@ func loadPreferences(ctx context.Context, userID string) map[string]string {
 	}
 
 	span.SetAttributes(attribute.Int("preferences.count", len(preferences)))
+	slog.DebugContext(ctx, "Preferences loaded")
 	span.SetStatus(codes.Ok, "preferences loaded")
-	return preferences
+	return preferences, nil
 }
 
-func formatResponse(ctx context.Context, userData map[string]interface{}, preferences map[string]string) models.UserProfileResponse {
-	_, span := tracer.Start(ctx, "formatResponse")
+func formatResponse(ctx context.Context, userData map[string]interface{}, preferences map[string]string) (models.UserProfileResponse, error) {
+	ctx, span := tracer.Start(ctx, "formatResponse")
 	defer span.End()
+	if err := faults.Inject(ctx, span, "formatResponse"); err != nil {
+		return models.UserProfileResponse{}, err
+	}
 
 	span.SetAttributes(
 		attribute.String("operation.type", "formatting"),
 	)
 
+	rng := random.FromContext(ctx)
 	// Simulate formatting work
-	time.Sleep(time.Duration(10+rand.Intn(20)) * time.Millisecond)
+	clock.Sleep(ctx, time.Duration(10+rng.Intn(20))*time.Millisecond)
 
 	response := models.UserProfileResponse{
 		UserID:      userData["id"].(string),
@@ -141,6 +201,7 @@ func formatResponse(ctx context.Context, userData map[string]interface{}, prefer
 		Preferences: preferences,
 	}
 
+	slog.DebugContext(ctx, "Response formatted")
 	span.SetStatus(codes.Ok, "response formatted")
-	return response
+	return response, nil
 }
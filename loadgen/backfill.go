@@ -0,0 +1,61 @@
+package loadgen
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// backfillStep is how far the virtual timeline advances between scheduling decisions
+const backfillStep = time.Second
+
+// Backfill generates the traffic of the past window [start, start+Duration) on a virtual timeline.
+// Every request carries its own timestamp in X-Synthetic-Time, so the service emits traces dated
+// in the window without waiting. Requests are sent as fast as Concurrency allows and none are dropped;
+// anomalies are offsets from start, like in a live run.
+func (r *Runner) Backfill(ctx context.Context, start time.Time) *Report {
+	stats := newCollector(r.cfg)
+	sem := make(chan struct{}, r.cfg.Concurrency)
+	var wg sync.WaitGroup
+
+	total := r.cfg.Duration.Duration
+	wallStart := time.Now()
+	nextDay := 24 * time.Hour
+	credit := 0.0
+	sent := 0
+
+	elapsed := time.Duration(0)
+schedule:
+	for ; elapsed < total; elapsed += backfillStep {
+		credit += r.cfg.Profile.RateAt(start.Add(elapsed), elapsed, total) * backfillStep.Seconds()
+		for ; credit >= 1; credit-- {
+			offset := elapsed + time.Duration(r.rng.Int63n(int64(backfillStep)))
+			c := r.next(offset)
+			c.at = start.Add(offset)
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				break schedule
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				stats.record(c, r.send(ctx, c))
+			}()
+			sent++
+		}
+
+		if elapsed+backfillStep >= nextDay {
+			slog.Info("Backfill progress", "until", start.Add(nextDay).UTC().Format("2006-01-02 15:04"), "requests", sent)
+			nextDay += 24 * time.Hour
+		}
+	}
+
+	wg.Wait()
+	slog.Info("Backfill finished", "span", elapsed.String(), "took", time.Since(wallStart).Round(time.Millisecond).String())
+	return stats.report(start, elapsed, elapsed)
+}
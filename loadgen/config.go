@@ -0,0 +1,236 @@
+package loadgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"tempo-otlp-trace-demo/faults"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Limits that keep a single load run bounded
+const (
+	MaxRate        = 10000
+	MaxConcurrency = 1000
+	MaxRunDuration = 24 * time.Hour
+
+	MaxBackfillDuration = 90 * 24 * time.Hour
+)
+
+// Config declares a load run: where to send traffic, how fast, and which anomalies to inject
+type Config struct {
+	Target      string     `json:"target,omitempty" yaml:"target"` // Base URL of the service; empty drives the handlers in-process
+	Duration    Duration   `json:"duration" yaml:"duration"`
+	Concurrency int        `json:"concurrency,omitempty" yaml:"concurrency"` // Maximum requests in flight (default: 10)
+	Timeout     Duration   `json:"timeout,omitempty" yaml:"timeout"`         // Per-request timeout (default: 30s)
+	Synthetic   bool       `json:"synthetic,omitempty" yaml:"synthetic"`     // Generate traces on a virtual clock (X-Synthetic-Time) instead of sleeping
+	Seed        int64      `json:"seed,omitempty" yaml:"seed"`               // Seed for the endpoint sequence, trace IDs and X-Seed request seeds (default: random)
+	Profile     Profile    `json:"profile" yaml:"profile"`
+	Mix         []Endpoint `json:"mix,omitempty" yaml:"mix"` // Weighted endpoint mix (default: DefaultMix)
+	Anomalies   []Anomaly  `json:"anomalies,omitempty" yaml:"anomalies"`
+}
+
+// Endpoint is one request in the weighted mix
+type Endpoint struct {
+	Name    string            `json:"name" yaml:"name"`
+	Method  string            `json:"method,omitempty" yaml:"method"` // Default: GET, or POST when Body is set
+	Path    string            `json:"path" yaml:"path"`               // Path and query, e.g. /api/search?q=laptop
+	Body    string            `json:"body,omitempty" yaml:"body"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers"`
+	Weight  float64           `json:"weight,omitempty" yaml:"weight"` // Relative weight (default: 1)
+}
+
+// Anomaly injects faults into the requests sent during a known window of the run,
+// so the resulting traces can be used as labelled data
+type Anomaly struct {
+	Name      string   `json:"name" yaml:"name"`
+	Start     Duration `json:"start" yaml:"start"`                   // Offset from the start of the run
+	Duration  Duration `json:"duration" yaml:"duration"`             // Length of the window
+	Endpoints []string `json:"endpoints,omitempty" yaml:"endpoints"` // Endpoint names affected (default: all)
+	Fault     string   `json:"fault" yaml:"fault"`                   // X-Inject-Fault header value, e.g. processPayment:latency=2s
+}
+
+// Active reports whether the anomaly applies to a request for endpoint sent at elapsed
+func (a *Anomaly) Active(elapsed time.Duration, endpoint string) bool {
+	if elapsed < a.Start.Duration || elapsed >= a.Start.Duration+a.Duration.Duration {
+		return false
+	}
+	if len(a.Endpoints) == 0 {
+		return true
+	}
+	for _, name := range a.Endpoints {
+		if name == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// Duration is a time.Duration written as a duration string ("30s") in JSON and YAML
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON accepts a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid duration %s: expected a string such as \"30s\"", data)
+	}
+	return d.set(value)
+}
+
+// UnmarshalYAML accepts a duration string
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.set(node.Value)
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) set(value string) error {
+	parsed, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %v", value, err)
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// DefaultMix exercises every demo endpoint, weighted towards the cheap ones
+func DefaultMix() []Endpoint {
+	return []Endpoint{
+		{Name: "create-order", Method: "POST", Path: "/api/order/create", Weight: 4,
+			Body: `{"user_id":"user-1","product_id":"prod-1","quantity":2,"price":29.99}`},
+		{Name: "user-profile", Path: "/api/user/profile?user_id=user-1", Weight: 4},
+		{Name: "search", Path: "/api/search?q=laptop&page=1&limit=10", Weight: 3},
+		{Name: "generate-report", Method: "POST", Path: "/api/report/generate", Weight: 1,
+			Body: `{"report_type":"sales","start_date":"2024-01-01","end_date":"2024-01-31"}`},
+		{Name: "batch-process", Method: "POST", Path: "/api/batch/process", Weight: 1,
+			Body: `{"items":["item-1","item-2","item-3"]}`},
+		{Name: "simulate", Path: "/api/simulate?depth=3&breadth=2&duration=20", Weight: 1},
+	}
+}
+
+// BackfillMix is DefaultMix without the synthetic simulate endpoint, so the history
+// only contains the shapes of real traffic
+func BackfillMix() []Endpoint {
+	mix := DefaultMix()
+	return mix[:len(mix)-1]
+}
+
+// LoadConfig reads a YAML or JSON config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read load config: %w", err)
+	}
+
+	var cfg Config
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid load config JSON: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid load config YAML: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks the config of a live run and fills in defaults
+func (c *Config) Validate() error {
+	return c.validate(MaxRunDuration)
+}
+
+// ValidateBackfill checks the config of a backfill, whose duration is the length of the past window,
+// and fills in defaults. Backfilled traces are always generated on a virtual clock.
+func (c *Config) ValidateBackfill() error {
+	c.Synthetic = true
+	if len(c.Mix) == 0 {
+		c.Mix = BackfillMix()
+	}
+	return c.validate(MaxBackfillDuration)
+}
+
+func (c *Config) validate(maxDuration time.Duration) error {
+	if c.Duration.Duration <= 0 || c.Duration.Duration > maxDuration {
+		return fmt.Errorf("duration must be between 0 and %s", maxDuration)
+	}
+	if c.Concurrency == 0 {
+		c.Concurrency = 10
+	}
+	if c.Concurrency < 0 || c.Concurrency > MaxConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d", MaxConcurrency)
+	}
+	if c.Timeout.Duration == 0 {
+		c.Timeout.Duration = 30 * time.Second
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	c.Target = strings.TrimRight(c.Target, "/")
+
+	if err := c.Profile.Validate(); err != nil {
+		return fmt.Errorf("profile: %w", err)
+	}
+
+	if len(c.Mix) == 0 {
+		c.Mix = DefaultMix()
+	}
+	names := make(map[string]bool, len(c.Mix))
+	for i := range c.Mix {
+		ep := &c.Mix[i]
+		if ep.Path == "" || !strings.HasPrefix(ep.Path, "/") {
+			return fmt.Errorf("mix[%d]: path must start with /", i)
+		}
+		if ep.Name == "" {
+			ep.Name = ep.Path
+		}
+		if names[ep.Name] {
+			return fmt.Errorf("mix[%d]: duplicate endpoint name %q", i, ep.Name)
+		}
+		names[ep.Name] = true
+		if ep.Method == "" {
+			ep.Method = "GET"
+			if ep.Body != "" {
+				ep.Method = "POST"
+			}
+		}
+		ep.Method = strings.ToUpper(ep.Method)
+		if ep.Weight == 0 {
+			ep.Weight = 1
+		}
+		if ep.Weight < 0 {
+			return fmt.Errorf("%s: weight must not be negative", ep.Name)
+		}
+	}
+
+	for i := range c.Anomalies {
+		a := &c.Anomalies[i]
+		if a.Name == "" {
+			a.Name = fmt.Sprintf("anomaly-%d", i+1)
+		}
+		if a.Fault == "" {
+			return fmt.Errorf("%s: fault must be set", a.Name)
+		}
+		if _, err := faults.ParseHeader(a.Fault); err != nil {
+			return fmt.Errorf("%s: %w", a.Name, err)
+		}
+		if a.Start.Duration < 0 || a.Duration.Duration <= 0 {
+			return fmt.Errorf("%s: start must not be negative and duration must be positive", a.Name)
+		}
+		for _, name := range a.Endpoints {
+			if !names[name] {
+				return fmt.Errorf("%s: unknown endpoint %q", a.Name, name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package loadgen
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Profile names accepted in Profile.Type
+const (
+	ProfileConstant = "constant"
+	ProfileRamp     = "ramp"
+	ProfileSine     = "sine"
+	ProfileBurst    = "burst"
+	ProfileDiurnal  = "diurnal"
+)
+
+// Profile declares the request rate, in requests per second, over the run:
+//
+//	constant: {type: constant, rate: 20}
+//	ramp:     {type: ramp, from: 5, to: 50}                        // linear over the whole run
+//	sine:     {type: sine, rate: 20, amplitude: 15, period: 1m}    // rate ± amplitude
+//	burst:    {type: burst, rate: 10, burst_rate: 100, burst_every: 30s, burst_length: 5s}
+//	diurnal:  {type: diurnal, rate: 10, amplitude: 8, peak_hour: 14} // rate ± amplitude, peaking daily at 14:00 UTC
+type Profile struct {
+	Type        string   `json:"type" yaml:"type"`
+	Rate        float64  `json:"rate,omitempty" yaml:"rate"` // constant rate, sine or diurnal mean, or burst baseline
+	From        float64  `json:"from,omitempty" yaml:"from"`
+	To          float64  `json:"to,omitempty" yaml:"to"`
+	Amplitude   float64  `json:"amplitude,omitempty" yaml:"amplitude"`
+	Period      Duration `json:"period,omitempty" yaml:"period"`
+	BurstRate   float64  `json:"burst_rate,omitempty" yaml:"burst_rate"`
+	BurstEvery  Duration `json:"burst_every,omitempty" yaml:"burst_every"`
+	BurstLength Duration `json:"burst_length,omitempty" yaml:"burst_length"`
+	PeakHour    float64  `json:"peak_hour,omitempty" yaml:"peak_hour"` // diurnal: UTC hour of the daily peak
+}
+
+// Validate checks the profile parameters
+func (p *Profile) Validate() error {
+	if p.Type == "" {
+		p.Type = ProfileConstant
+	}
+
+	rates := []float64{p.Rate, p.From, p.To, p.Amplitude, p.BurstRate}
+	for _, rate := range rates {
+		if rate < 0 || rate > MaxRate {
+			return fmt.Errorf("rates must be between 0 and %d", MaxRate)
+		}
+	}
+
+	switch p.Type {
+	case ProfileConstant:
+		if p.Rate <= 0 {
+			return fmt.Errorf("constant profile requires rate")
+		}
+	case ProfileRamp:
+		if p.From == 0 && p.To == 0 {
+			return fmt.Errorf("ramp profile requires from or to")
+		}
+	case ProfileSine:
+		if p.Rate <= 0 || p.Period.Duration <= 0 {
+			return fmt.Errorf("sine profile requires rate and period")
+		}
+	case ProfileBurst:
+		if p.BurstRate <= 0 || p.BurstEvery.Duration <= 0 || p.BurstLength.Duration <= 0 {
+			return fmt.Errorf("burst profile requires burst_rate, burst_every and burst_length")
+		}
+		if p.BurstLength.Duration > p.BurstEvery.Duration {
+			return fmt.Errorf("burst_length must not exceed burst_every")
+		}
+	case ProfileDiurnal:
+		if p.Rate <= 0 {
+			return fmt.Errorf("diurnal profile requires rate")
+		}
+		if p.PeakHour < 0 || p.PeakHour >= 24 {
+			return fmt.Errorf("peak_hour must be between 0 and 24")
+		}
+	default:
+		return fmt.Errorf("unknown profile type %q", p.Type)
+	}
+	return nil
+}
+
+// RateAt returns the target rate, in requests per second, at time at, elapsed into a run of length total
+func (p *Profile) RateAt(at time.Time, elapsed, total time.Duration) float64 {
+	var rate float64
+	switch p.Type {
+	case ProfileRamp:
+		progress := 1.0
+		if total > 0 {
+			progress = math.Min(float64(elapsed)/float64(total), 1)
+		}
+		rate = p.From + (p.To-p.From)*progress
+	case ProfileSine:
+		phase := 2 * math.Pi * float64(elapsed) / float64(p.Period.Duration)
+		rate = p.Rate + p.Amplitude*math.Sin(phase)
+	case ProfileBurst:
+		rate = p.Rate
+		if elapsed%p.BurstEvery.Duration < p.BurstLength.Duration {
+			rate = p.BurstRate
+		}
+	case ProfileDiurnal:
+		utc := at.UTC()
+		hour := float64(utc.Hour()) + float64(utc.Minute())/60 + float64(utc.Second())/3600
+		rate = p.Rate + p.Amplitude*math.Cos(2*math.Pi*(hour-p.PeakHour)/24)
+	default:
+		rate = p.Rate
+	}
+	return math.Max(rate, 0)
+}
+
+// String describes the profile for reports
+func (p *Profile) String() string {
+	switch p.Type {
+	case ProfileRamp:
+		return fmt.Sprintf("ramp %g→%g rps", p.From, p.To)
+	case ProfileSine:
+		return fmt.Sprintf("sine %g±%g rps every %s", p.Rate, p.Amplitude, p.Period)
+	case ProfileBurst:
+		return fmt.Sprintf("burst %g rps, %g rps for %s every %s", p.Rate, p.BurstRate, p.BurstLength, p.BurstEvery)
+	case ProfileDiurnal:
+		return fmt.Sprintf("diurnal %g±%g rps peaking at %g:00 UTC", p.Rate, p.Amplitude, p.PeakHour)
+	default:
+		return fmt.Sprintf("constant %g rps", p.Rate)
+	}
+}
@@ -0,0 +1,230 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// MaxAnomalyTraceIDs caps the trace IDs kept per anomaly in the report
+const MaxAnomalyTraceIDs = 10000
+
+// Report summarises a load run
+type Report struct {
+	Target      string           `json:"target"`
+	Profile     string           `json:"profile"`
+	Seed        int64            `json:"seed"`
+	StartTime   time.Time        `json:"start_time"`
+	Duration    string           `json:"duration"`
+	Requests    int              `json:"requests"`
+	Errors      int              `json:"errors"`
+	Dropped     int              `json:"dropped"`      // Requests not sent because Concurrency were in flight
+	AchievedRPS float64          `json:"achieved_rps"` // Requests sent per second while scheduling
+	Endpoints   []EndpointReport `json:"endpoints"`
+	Anomalies   []AnomalyReport  `json:"anomalies,omitempty"`
+}
+
+// EndpointReport summarises the requests sent to one endpoint
+type EndpointReport struct {
+	Name     string  `json:"name"`
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"` // Transport errors and HTTP status >= 400
+	Dropped  int     `json:"dropped"`
+	P50Ms    float64 `json:"p50_ms"`
+	P90Ms    float64 `json:"p90_ms"`
+	P99Ms    float64 `json:"p99_ms"`
+	MaxMs    float64 `json:"max_ms"`
+}
+
+// AnomalyReport records when an anomaly was active and which traces it affected
+type AnomalyReport struct {
+	Name      string    `json:"name"`
+	Fault     string    `json:"fault"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Requests  int       `json:"requests"`
+	Errors    int       `json:"errors"`
+	TraceIDs  []string  `json:"trace_ids"`
+	Truncated bool      `json:"truncated,omitempty"` // More than MaxAnomalyTraceIDs traces were affected
+}
+
+// endpointStats accumulates the results for one endpoint
+type endpointStats struct {
+	requests  int
+	errors    int
+	dropped   int
+	latencies []time.Duration
+}
+
+// anomalyStats accumulates the requests sent while an anomaly was active
+type anomalyStats struct {
+	requests int
+	errors   int
+	traceIDs []string
+}
+
+// collector gathers request results from concurrent workers
+type collector struct {
+	cfg *Config
+
+	mu        sync.Mutex
+	endpoints map[string]*endpointStats
+	anomalies map[*Anomaly]*anomalyStats
+}
+
+func newCollector(cfg *Config) *collector {
+	c := &collector{
+		cfg:       cfg,
+		endpoints: make(map[string]*endpointStats, len(cfg.Mix)),
+		anomalies: make(map[*Anomaly]*anomalyStats, len(cfg.Anomalies)),
+	}
+	for _, ep := range cfg.Mix {
+		c.endpoints[ep.Name] = &endpointStats{}
+	}
+	for i := range cfg.Anomalies {
+		c.anomalies[&cfg.Anomalies[i]] = &anomalyStats{}
+	}
+	return c
+}
+
+func (c *collector) dropped(call *call) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endpoints[call.endpoint.Name].dropped++
+}
+
+func (c *collector) record(call *call, res result) {
+	failed := res.err != nil || res.status >= 400
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.endpoints[call.endpoint.Name]
+	stats.requests++
+	stats.latencies = append(stats.latencies, res.latency)
+	if failed {
+		stats.errors++
+	}
+
+	for _, a := range call.anomalies {
+		as := c.anomalies[a]
+		as.requests++
+		if failed {
+			as.errors++
+		}
+		if len(as.traceIDs) < MaxAnomalyTraceIDs {
+			as.traceIDs = append(as.traceIDs, call.traceID)
+		}
+	}
+}
+
+// report builds the summary; the achieved rate is measured over the scheduling window,
+// excluding the time spent waiting for the last requests to complete
+func (c *collector) report(start time.Time, scheduled, elapsed time.Duration) *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	target := c.cfg.Target
+	if target == "" {
+		target = "in-process"
+	}
+	report := &Report{
+		Target:    target,
+		Profile:   c.cfg.Profile.String(),
+		Seed:      c.cfg.Seed,
+		StartTime: start,
+		Duration:  elapsed.Round(time.Millisecond).String(),
+		Endpoints: make([]EndpointReport, 0, len(c.cfg.Mix)),
+	}
+
+	for _, ep := range c.cfg.Mix {
+		stats := c.endpoints[ep.Name]
+		sort.Slice(stats.latencies, func(i, j int) bool {
+			return stats.latencies[i] < stats.latencies[j]
+		})
+		report.Endpoints = append(report.Endpoints, EndpointReport{
+			Name:     ep.Name,
+			Requests: stats.requests,
+			Errors:   stats.errors,
+			Dropped:  stats.dropped,
+			P50Ms:    percentileMs(stats.latencies, 0.50),
+			P90Ms:    percentileMs(stats.latencies, 0.90),
+			P99Ms:    percentileMs(stats.latencies, 0.99),
+			MaxMs:    percentileMs(stats.latencies, 1),
+		})
+		report.Requests += stats.requests
+		report.Errors += stats.errors
+		report.Dropped += stats.dropped
+	}
+	if scheduled > 0 {
+		report.AchievedRPS = float64(report.Requests) / scheduled.Seconds()
+	}
+
+	for i := range c.cfg.Anomalies {
+		a := &c.cfg.Anomalies[i]
+		stats := c.anomalies[a]
+		report.Anomalies = append(report.Anomalies, AnomalyReport{
+			Name:      a.Name,
+			Fault:     a.Fault,
+			Start:     start.Add(a.Start.Duration),
+			End:       start.Add(a.Start.Duration + a.Duration.Duration),
+			Requests:  stats.requests,
+			Errors:    stats.errors,
+			TraceIDs:  append([]string{}, stats.traceIDs...),
+			Truncated: stats.requests > MaxAnomalyTraceIDs,
+		})
+	}
+	return report
+}
+
+// percentileMs returns the p-th percentile of sorted latencies in milliseconds (nearest rank)
+func percentileMs(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(float64(len(sorted))*p+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return float64(sorted[index].Microseconds()) / 1000
+}
+
+// WriteText writes a human-readable summary of the run
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Target:    %s\n", r.Target)
+	fmt.Fprintf(w, "Profile:   %s (seed %d)\n", r.Profile, r.Seed)
+	fmt.Fprintf(w, "Duration:  %s\n", r.Duration)
+	fmt.Fprintf(w, "Requests:  %d sent, %d errors, %d dropped, %.1f rps\n\n", r.Requests, r.Errors, r.Dropped, r.AchievedRPS)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ENDPOINT\tREQUESTS\tERRORS\tDROPPED\tP50 ms\tP90 ms\tP99 ms\tMAX ms\t")
+	for _, ep := range r.Endpoints {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			ep.Name, ep.Requests, ep.Errors, ep.Dropped, ep.P50Ms, ep.P90Ms, ep.P99Ms, ep.MaxMs)
+	}
+	tw.Flush()
+
+	for _, a := range r.Anomalies {
+		fmt.Fprintf(w, "\nAnomaly %s [%s]\n", a.Name, a.Fault)
+		fmt.Fprintf(w, "  window:   %s - %s\n", a.Start.Format(time.RFC3339Nano), a.End.Format(time.RFC3339Nano))
+		fmt.Fprintf(w, "  requests: %d (%d errors)\n", a.Requests, a.Errors)
+		if len(a.TraceIDs) > 0 {
+			shown := a.TraceIDs
+			if len(shown) > 5 {
+				shown = shown[:5]
+			}
+			more := ""
+			if len(a.TraceIDs) > len(shown) {
+				more = fmt.Sprintf(" ... (%d more, see JSON report)", len(a.TraceIDs)-len(shown))
+			}
+			fmt.Fprintf(w, "  traces:   %s%s\n", strings.Join(shown, ", "), more)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/faults"
+	"tempo-otlp-trace-demo/random"
+	"time"
+)
+
+// tick is how often the scheduler releases the requests due at the current rate
+const tick = 10 * time.Millisecond
+
+// Doer sends a request; *http.Client satisfies it
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// HandlerDoer serves requests in-process with a handler instead of going over the network
+type HandlerDoer struct {
+	Handler http.Handler
+}
+
+// Do serves the request with the handler and returns the recorded response
+func (d HandlerDoer) Do(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	d.Handler.ServeHTTP(recorder, req)
+	return recorder.Result(), nil
+}
+
+// call is a request chosen by the scheduler
+type call struct {
+	endpoint  *Endpoint
+	elapsed   time.Duration
+	at        time.Time // Virtual timestamp of a backfilled request
+	traceID   string
+	spanID    string
+	seed      int64
+	anomalies []*Anomaly
+}
+
+// Runner drives an open-loop load run: requests are released at the profile's rate
+// regardless of how fast earlier ones complete, and dropped when Concurrency are already in flight.
+type Runner struct {
+	cfg  *Config
+	doer Doer
+	rng  *rand.Rand // only used by the scheduler goroutine
+
+	totalWeight float64
+}
+
+// NewRunner creates a runner for a validated config.
+// Requests go to cfg.Target with doer, or to the in-process handler when doer is a HandlerDoer.
+// The endpoint sequence, trace IDs and request seeds are derived from cfg.Seed.
+func NewRunner(cfg *Config, doer Doer) *Runner {
+	var totalWeight float64
+	for _, ep := range cfg.Mix {
+		totalWeight += ep.Weight
+	}
+	return &Runner{
+		cfg:         cfg,
+		doer:        doer,
+		rng:         rand.New(rand.NewSource(cfg.Seed)),
+		totalWeight: totalWeight,
+	}
+}
+
+// Run sends traffic until the configured duration elapses or ctx is cancelled,
+// waits for the requests in flight and returns the summary
+func (r *Runner) Run(ctx context.Context) *Report {
+	stats := newCollector(r.cfg)
+	sem := make(chan struct{}, r.cfg.Concurrency)
+	var wg sync.WaitGroup
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	start := time.Now()
+	last := start
+	credit := 0.0
+
+schedule:
+	for {
+		select {
+		case <-ctx.Done():
+			break schedule
+		case now := <-ticker.C:
+			elapsed := now.Sub(start)
+			if elapsed >= r.cfg.Duration.Duration {
+				break schedule
+			}
+
+			credit += r.cfg.Profile.RateAt(now, elapsed, r.cfg.Duration.Duration) * now.Sub(last).Seconds()
+			last = now
+			for ; credit >= 1; credit-- {
+				c := r.next(elapsed)
+				select {
+				case sem <- struct{}{}:
+				default:
+					stats.dropped(c)
+					continue
+				}
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-sem }()
+					stats.record(c, r.send(ctx, c))
+				}()
+			}
+		}
+	}
+
+	scheduled := time.Since(start)
+	wg.Wait()
+	return stats.report(start, scheduled, time.Since(start))
+}
+
+// next picks the endpoint and trace context of the next request
+func (r *Runner) next(elapsed time.Duration) *call {
+	c := &call{
+		endpoint: r.pick(),
+		elapsed:  elapsed,
+		traceID:  r.randomID(16),
+		spanID:   r.randomID(8),
+		seed:     r.rng.Int63(),
+	}
+	for i := range r.cfg.Anomalies {
+		if r.cfg.Anomalies[i].Active(elapsed, c.endpoint.Name) {
+			c.anomalies = append(c.anomalies, &r.cfg.Anomalies[i])
+		}
+	}
+	return c
+}
+
+// pick chooses an endpoint from the mix according to the weights
+func (r *Runner) pick() *Endpoint {
+	target := r.rng.Float64() * r.totalWeight
+	for i := range r.cfg.Mix {
+		target -= r.cfg.Mix[i].Weight
+		if target < 0 {
+			return &r.cfg.Mix[i]
+		}
+	}
+	return &r.cfg.Mix[len(r.cfg.Mix)-1]
+}
+
+// randomID returns a non-zero hex ID of n bytes
+func (r *Runner) randomID(n int) string {
+	id := make([]byte, n)
+	for {
+		r.rng.Read(id)
+		for _, b := range id {
+			if b != 0 {
+				return hex.EncodeToString(id)
+			}
+		}
+	}
+}
+
+// result is the outcome of a single request
+type result struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// send performs the request with a traceparent header, so its trace ID is known up front,
+// an X-Seed header, so the service generates the same trace for the same run seed,
+// and the faults of the active anomalies in the X-Inject-Fault header
+func (r *Runner) send(ctx context.Context, c *call) result {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout.Duration)
+	defer cancel()
+
+	target := r.cfg.Target
+	if target == "" {
+		target = "http://in-process"
+	}
+
+	var body io.Reader
+	if c.endpoint.Body != "" {
+		body = strings.NewReader(c.endpoint.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.endpoint.Method, target+c.endpoint.Path, body)
+	if err != nil {
+		return result{err: err}
+	}
+	if c.endpoint.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range c.endpoint.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-01", c.traceID, c.spanID))
+	req.Header.Set(random.Header, strconv.FormatInt(c.seed, 10))
+	if !c.at.IsZero() {
+		req.Header.Set(clock.Header, c.at.UTC().Format(time.RFC3339Nano))
+	} else if r.cfg.Synthetic {
+		req.Header.Set(clock.Header, "true")
+	}
+
+	if len(c.anomalies) > 0 {
+		injected := make([]string, 0, len(c.anomalies))
+		for _, a := range c.anomalies {
+			injected = append(injected, a.Fault)
+		}
+		req.Header.Set(faults.Header, strings.Join(injected, ";"))
+	}
+
+	start := time.Now()
+	resp, err := r.doer.Do(req)
+	if err != nil {
+		return result{latency: time.Since(start), err: err}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return result{status: resp.StatusCode, latency: time.Since(start)}
+}
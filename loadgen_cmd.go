@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"tempo-otlp-trace-demo/anomalies"
+	"tempo-otlp-trace-demo/loadgen"
+	"tempo-otlp-trace-demo/logging"
+	"tempo-otlp-trace-demo/services"
+	"tempo-otlp-trace-demo/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// runLoadgen implements the "loadgen" subcommand:
+//
+//	trace-demo-app loadgen [-config loadgen.yaml] [-target http://localhost:8080] [-duration 1m] [-rate 20] ...
+//
+// Without a config the run uses a constant rate and the default endpoint mix;
+// ramp, sine, burst and diurnal profiles, custom mixes and anomalies are declared in the config.
+// Without a target the demo handlers are driven in-process and export their traces directly.
+func runLoadgen(args []string) error {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	configPath := fs.String("config", "", "YAML or JSON load config (see loadgen.example.yaml)")
+	target := fs.String("target", "", "Base URL of the service (default: drive the handlers in-process)")
+	duration := fs.Duration("duration", 30*time.Second, "Length of the run")
+	rate := fs.Float64("rate", 10, "Requests per second (constant rate, sine mean or burst baseline)")
+	concurrency := fs.Int("concurrency", 10, "Maximum requests in flight")
+	synthetic := fs.Bool("synthetic", false, "Generate traces on a virtual clock instead of sleeping")
+	seed := fs.Int64("seed", 0, "Seed for the endpoint sequence and trace IDs (default: random)")
+	reportPath := fs.String("report", "", "Write the JSON report to this file (- for stdout)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	cfg := &loadgen.Config{}
+	if *configPath != "" {
+		loaded, err := loadgen.LoadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		cfg = loaded
+	}
+
+	// Flags override the config file when given explicitly, and provide the defaults without one
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	override := func(name string) bool {
+		return set[name] || *configPath == ""
+	}
+	if override("target") {
+		cfg.Target = *target
+	}
+	if override("duration") {
+		cfg.Duration.Duration = *duration
+	}
+	if override("rate") {
+		cfg.Profile.Rate = *rate
+	}
+	if override("concurrency") {
+		cfg.Concurrency = *concurrency
+	}
+	if set["synthetic"] {
+		cfg.Synthetic = *synthetic
+	}
+	if set["seed"] {
+		cfg.Seed = *seed
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid load config: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	doer, cleanup, err := newLoadDoer(ctx, cfg.Target)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	slog.Info("Load run started", "profile", cfg.Profile.String(), "duration", cfg.Duration.String(), "target", targetName(cfg.Target))
+	report := loadgen.NewRunner(cfg, doer).Run(ctx)
+	report.WriteText(os.Stdout)
+
+	return writeLoadReport(report, *reportPath)
+}
+
+// newLoadDoer returns the doer for a load run: an HTTP client for a target, or the demo handlers
+// in-process, with the tracer and the downstream services exporting their spans and the anomaly
+// ledger export set up. Either way logs go to stderr, leaving stdout to the report.
+// cleanup flushes the spans and logs and closes the export.
+func newLoadDoer(ctx context.Context, target string, opts ...tracing.Option) (loadgen.Doer, func(), error) {
+	shutdownLogs, err := logging.Init(ctx, os.Stderr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize logging: %w", err)
+	}
+	closeLogs := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownLogs(ctx); err != nil {
+			slog.Error("Error shutting down log export", "error", err)
+		}
+	}
+	if target != "" {
+		return &http.Client{}, closeLogs, nil
+	}
+
+	tp, err := tracing.InitTracer(ctx, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize tracer: %w", err)
+	}
+	cluster, err := services.Start(ctx, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start services: %w", err)
+	}
+	if err := anomalies.ExportFromEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		anomalies.Default.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := cluster.Shutdown(ctx); err != nil {
+			slog.Error("Error shutting down services", "error", err)
+		}
+		if err := tp.Shutdown(ctx); err != nil {
+			slog.Error("Error shutting down tracer provider", "error", err)
+		}
+		closeLogs()
+	}
+	return loadgen.HandlerDoer{Handler: newHandler(otel.Tracer("trace-demo-service"))}, cleanup, nil
+}
+
+// writeLoadReport writes the JSON report to path, or to stdout for "-"
+func writeLoadReport(report *loadgen.Report, path string) error {
+	if path == "" {
+		return nil
+	}
+	out := os.Stdout
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func targetName(target string) string {
+	if target == "" {
+		return "in-process handlers"
+	}
+	return target
+}
@@ -0,0 +1,227 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/tracing"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LevelEnvVar sets the minimum level logged: debug, info (default), warn or error.
+// Handlers log every step at debug and one line per request at info.
+const LevelEnvVar = "LOG_LEVEL"
+
+// ExporterEnvVar set to otlp also exports the logs over OTLP, as configured by the OTEL_EXPORTER_OTLP_* variables
+const ExporterEnvVar = "OTEL_LOGS_EXPORTER"
+
+// Init makes slog, the standard log package and the OpenTelemetry SDK write JSON lines to w,
+// with the trace_id and span_id of the context they are logged with.
+// When OTEL_LOGS_EXPORTER=otlp the logs are exported over OTLP as well.
+// The returned function flushes and stops the export.
+func Init(ctx context.Context, w io.Writer) (func(context.Context) error, error) {
+	var level slog.Level
+	if value := os.Getenv(LevelEnvVar); value != "" {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", LevelEnvVar, value, err)
+		}
+	}
+
+	jsonHandler := traceHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})}
+	var handler slog.Handler = jsonHandler
+	shutdown := func(context.Context) error { return nil }
+
+	disabled, err := tracing.SDKDisabled()
+	if err != nil {
+		return nil, err
+	}
+	exporter := strings.ToLower(os.Getenv(ExporterEnvVar))
+	if disabled {
+		// Logs are still written to w, only their export is turned off
+		exporter = "none"
+	}
+
+	switch exporter {
+	case "", "none":
+	case "otlp":
+		lp, err := newLoggerProvider(ctx)
+		if err != nil {
+			return nil, err
+		}
+		handler = fanoutHandler{handler, levelHandler{otelslog.NewHandler("trace-demo-service", otelslog.WithLoggerProvider(lp)), level}}
+		shutdown = lp.Shutdown
+	default:
+		return nil, fmt.Errorf("unsupported %s %q: expected otlp or none", ExporterEnvVar, exporter)
+	}
+
+	slog.SetDefault(slog.New(clockHandler{handler}))
+	// The SDK's own messages, such as export errors, are logged as well, but never exported
+	otel.SetLogger(logr.FromSlogHandler(jsonHandler))
+	return shutdown, nil
+}
+
+// newLoggerProvider creates a logger provider exporting over OTLP with the main service's resource
+func newLoggerProvider(ctx context.Context) (*sdklog.LoggerProvider, error) {
+	exporter, err := newOTLPLogExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tracing.NewResource(ctx, tracing.ServiceName())
+	if err != nil {
+		return nil, err
+	}
+
+	return sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+	), nil
+}
+
+// newOTLPLogExporter creates the OTLP exporter configured by the OTEL_EXPORTER_OTLP_* variables.
+// The SDK has no JSON encoding for logs, so http/json sends protobuf to the same endpoint.
+func newOTLPLogExporter(ctx context.Context) (sdklog.Exporter, error) {
+	cfg, err := tracing.OTLPConfigFromEnv(tracing.SignalLogs)
+	if err != nil {
+		return nil, err
+	}
+
+	var exporter sdklog.Exporter
+	switch cfg.Protocol {
+	case tracing.ProtocolGRPC:
+		opts := tracing.OTLPGRPCOptions[otlploggrpc.Option]{
+			WithEndpoint:       otlploggrpc.WithEndpoint,
+			WithHeaders:        otlploggrpc.WithHeaders,
+			WithTimeout:        otlploggrpc.WithTimeout,
+			WithInsecure:       otlploggrpc.WithInsecure,
+			WithTLSCredentials: otlploggrpc.WithTLSCredentials,
+			WithCompressor:     otlploggrpc.WithCompressor,
+		}.Options(cfg)
+		exporter, err = otlploggrpc.New(ctx, opts...)
+	case tracing.ProtocolHTTPProtobuf, tracing.ProtocolHTTPJSON:
+		opts := tracing.OTLPHTTPOptions[otlploghttp.Option]{
+			WithEndpointURL:     otlploghttp.WithEndpointURL,
+			WithHeaders:         otlploghttp.WithHeaders,
+			WithTimeout:         otlploghttp.WithTimeout,
+			WithTLSClientConfig: otlploghttp.WithTLSClientConfig,
+			Gzip:                otlploghttp.WithCompression(otlploghttp.GzipCompression),
+		}.Options(cfg)
+		exporter, err = otlploghttp.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// clockHandler timestamps records logged during requests on a virtual clock with the clock's time,
+// so they line up with the request's spans
+type clockHandler struct {
+	slog.Handler
+}
+
+func (h clockHandler) Handle(ctx context.Context, r slog.Record) error {
+	if c, ok := clock.FromContext(ctx); ok {
+		r.Time = c.Now()
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h clockHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return clockHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h clockHandler) WithGroup(name string) slog.Handler {
+	return clockHandler{h.Handler.WithGroup(name)}
+}
+
+// traceHandler adds the trace_id and span_id of the span in the record's context
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
+
+// levelHandler drops records below level; the OTLP bridge leaves that to the logger provider
+type levelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.Handler.Enabled(ctx, level)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{h.Handler.WithAttrs(attrs), h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{h.Handler.WithGroup(name), h.level}
+}
+
+// fanoutHandler sends each record to all of its handlers that are enabled for it
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, r.Level) {
+			errs = append(errs, handler.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
@@ -2,14 +2,23 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
+	"tempo-otlp-trace-demo/anomalies"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/concurrency"
 	docs "tempo-otlp-trace-demo/docs"
-	"tempo-otlp-trace-demo/handlers
+	"tempo-otlp-trace-demo/faults"
+	"tempo-otlp-trace-demo/handlers"
+	"tempo-otlp-trace-demo/logging"
+	"tempo-otlp-trace-demo/random"
+	"tempo-otlp-trace-demo/services"
 	"tempo-otlp-trace-demo/tracing"
 	"time"
 
@@ -56,27 +65,139 @@ func applySwaggerEnvOverrides() {
 }
 
 func main() {
-	log.Println("Starting Tempo OTLP Trace Demo Service...")
+	if len(os.Args) > 1 && os.Args[1] == "loadgen" {
+		if err := runLoadgen(os.Args[2:]); err != nil {
+			fatal("Load generator failed", "error", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		if err := runBackfill(os.Args[2:]); err != nil {
+			fatal("Backfill failed", "error", err)
+		}
+		return
+	}
+
+	// Log JSON lines correlated with the traces, exported over OTLP if OTEL_LOGS_EXPORTER=otlp
+	ctx := context.Background()
+	shutdownLogs, err := logging.Init(ctx, os.Stdout)
+	if err != nil {
+		fatal("Failed to initialize logging", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownLogs(ctx); err != nil {
+			slog.Error("Error shutting down log export", "error", err)
+		}
+	}()
+
+	slog.Info("Starting Tempo OTLP Trace Demo Service")
 
 	applySwaggerEnvOverrides()
 
 	// Initialize tracer
-	ctx := context.Background()
 	tp, err := tracing.InitTracer(ctx)
 	if err != nil {
-		log.Fatalf("Failed to initialize tracer: %v", err)
+		fatal("Failed to initialize tracer", "error", err)
 	}
 	defer func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := tp.Shutdown(ctx); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+			slog.Error("Error shutting down tracer provider", "error", err)
+		}
+	}()
+
+	// Record RED metrics, pushed over OTLP and served on /metrics
+	mp, metricsHandler, err := tracing.InitMeter(ctx)
+	if err != nil {
+		fatal("Failed to initialize metrics", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := mp.Shutdown(ctx); err != nil {
+			slog.Error("Error shutting down meter provider", "error", err)
+		}
+	}()
+
+	// Start the downstream services of the order flow, each with its own tracer provider
+	cluster, err := services.Start(ctx)
+	if err != nil {
+		fatal("Failed to start services", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := cluster.Shutdown(ctx); err != nil {
+			slog.Error("Error shutting down services", "error", err)
+		}
+	}()
+
+	if _, _, err := random.GlobalSeed(); err != nil {
+		fatal("Invalid seed", "error", err)
+	}
+	if _, _, err := clock.ParseStart(os.Getenv(clock.EnvVar)); err != nil {
+		fatal("Invalid synthetic time", "env", clock.EnvVar, "error", err)
+	}
+
+	// Export injected anomalies to ANOMALY_LEDGER_FILE, if set
+	if err := anomalies.ExportFromEnv(); err != nil {
+		fatal("Failed to export anomalies", "error", err)
+	}
+	defer anomalies.Default.Close()
+
+	// Setup HTTP handler
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler)
+	mux.Handle("/", newHandler(otel.Tracer("trace-demo-service")))
+
+	// Setup HTTP server
+	port := getEnv("PORT", "8080")
+	server := &http.Server{
+		Addr:         ":" + port,
+		Handler:      mux,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	// Start server in a goroutine
+	go func() {
+		slog.Info("Server listening", "port", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("Server failed to start", "error", err)
 		}
 	}()
 
-	// Get tracer for middleware
-	tracer := otel.Tracer("trace-demo-service")
+	// Wait for interrupt signal to gracefully shutdown the server
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("Shutting down server")
 
+	// Graceful shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		fatal("Server forced to shutdown", "error", err)
+	}
+
+	slog.Info("Server stopped")
+}
+
+// fatal logs an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// newHandler registers the demo routes and wraps them with the tracing and fault injection middleware.
+// It is shared by the HTTP server and the in-process load generator.
+func newHandler(tracer trace.Tracer) http.Handler {
 	// Setup HTTP routes
 	mux := http.NewServeMux()
 
@@ -87,9 +208,19 @@ func main() {
 	mux.HandleFunc("/api/search", handlers.Search)
 	mux.HandleFunc("/api/batch/process", handlers.ProcessBatch)
 	mux.HandleFunc("/api/simulate", handlers.Simulate)
+	mux.HandleFunc("/api/scenarios/run", handlers.RunScenario)
 
 	// Source code analysis endpoints
-	mux.HandleFunc("/api/source-code", handlers.GetSourceCode)
+	mux.HandleFunc("/api/source-code", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.GetSpanSourceCode(w, r)
+		case http.MethodPost:
+			handlers.GetSourceCode(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
 	mux.HandleFunc("/api/span-names", handlers.GetSpanNames)
 	mux.HandleFunc("/api/mappings", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -105,6 +236,37 @@ func main() {
 	})
 	mux.HandleFunc("/api/mappings/reload", handlers.ReloadMappings)
 
+	// Fault injection endpoints
+	mux.HandleFunc("/api/faults", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.GetFaults(w, r)
+		case http.MethodPost:
+			handlers.UpdateFaults(w, r)
+		case http.MethodDelete:
+			handlers.DeleteFaults(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/api/anomalies", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handlers.GetAnomalies(w, r)
+		case http.MethodDelete:
+			handlers.DeleteAnomalies(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Trace analysis endpoints
+	mux.HandleFunc("/api/traces/search", handlers.SearchTraces)
+	mux.HandleFunc("/api/traces/critical-path", handlers.GetCriticalPath)
+	mux.HandleFunc("/api/traces/analysis-bundle", handlers.GetAnalysisBundle)
+	mux.HandleFunc("/api/traces/diff", handlers.GetTraceDiff)
+	mux.HandleFunc("/api/analyze", handlers.Analyze)
+
 	// Swagger UI endpoint
 	mux.HandleFunc("/swagger/", httpSwagger.Handler(
 		httpSwagger.URL("doc.json"),
@@ -170,6 +332,11 @@ func main() {
         <div class="description">Custom simulation (configurable spans and duration)</div>
     </div>
     
+    <div class="endpoint">
+        <span class="method">POST</span> <span class="path">/api/scenarios/run</span>
+        <div class="description">Generate a trace from a declarative YAML/JSON scenario (see scenarios/)</div>
+    </div>
+    
     <div class="endpoint">
         <span class="method">GET</span> <span class="path">/health</span>
         <div class="description">Health check</div>
@@ -177,6 +344,11 @@ func main() {
     
     <h2>Source Code Analysis Endpoints:</h2>
     
+    <div class="endpoint">
+        <span class="method">GET</span> <span class="path">/api/source-code?span_id=xxx&trace_id=yyy</span>
+        <div class="description">Get source code, duration, attributes and child spans for a span stored in Tempo</div>
+    </div>
+    
     <div class="endpoint">
         <span class="method">POST</span> <span class="path">/api/source-code</span>
         <div class="description">Get source code for a specific span (JSON body: {"spanName": "xxx"})</div>
@@ -207,6 +379,60 @@ func main() {
         <div class="description">Reload mappings from file</div>
     </div>
     
+    <h2>Fault Injection Endpoints:</h2>
+    
+    <div class="endpoint">
+        <span class="method">GET</span> <span class="path">/api/faults</span>
+        <div class="description">List faults injected into spans by name</div>
+    </div>
+    
+    <div class="endpoint">
+        <span class="method">POST</span> <span class="path">/api/faults</span>
+        <div class="description">Add faults: latency (fixed or distribution), error rate, timeout, panic, probability (JSON body: {"faults": [{"span_name": "checkInventory", "latency": "2s"}]})</div>
+    </div>
+    
+    <div class="endpoint">
+        <span class="method">DELETE</span> <span class="path">/api/faults?span_name=xxx</span>
+        <div class="description">Remove a fault, or all faults without span_name. Per-request faults: X-Inject-Fault: processPayment:latency=2s;checkInventory:error_rate=1</div>
+    </div>
+    
+    <div class="endpoint">
+        <span class="method">GET</span> <span class="path">/api/anomalies?trace_id=xxx&amp;type=latency&amp;format=jsonl</span>
+        <div class="description">Ground-truth ledger of injected anomalies (trace ID, span, type, magnitude, timestamp) as JSON or JSONL</div>
+    </div>
+    
+    <div class="endpoint">
+        <span class="method">DELETE</span> <span class="path">/api/anomalies</span>
+        <div class="description">Clear the anomaly ledger</div>
+    </div>
+    
+    <h2>Trace Analysis Endpoints:</h2>
+    
+    <div class="endpoint">
+        <span class="method">GET</span> <span class="path">/api/traces/search?q={ duration > 2s }&since=1h&limit=20</span>
+        <div class="description">Search traces in Tempo with TraceQL, tag filters, duration bounds and time ranges</div>
+    </div>
+    
+    <div class="endpoint">
+        <span class="method">GET</span> <span class="path">/api/traces/critical-path?trace_id=xxx</span>
+        <div class="description">Critical path and per-span self-time of a stored trace</div>
+    </div>
+    
+    <div class="endpoint">
+        <span class="method">GET</span> <span class="path">/api/traces/analysis-bundle?trace_id=xxx&top=5&format=markdown</span>
+        <div class="description">LLM-ready bundle of the slowest spans with source code, attributes, parent chain and child timings</div>
+    </div>
+    
+    <div class="endpoint">
+        <span class="method">GET</span> <span class="path">/api/traces/diff?base=xxx&target=yyy</span>
+        <div class="description">Compare two traces: aligned span duration deltas, changed attributes, added/missing spans and the hotspot's source code</div>
+    </div>
+    
+    <div class="endpoint">
+        <span class="method">POST</span> <span class="path">/api/analyze</span>
+        <div class="description">Diagnose a trace with the rules or openai analyzer (JSON body: {"trace_id": "xxx", "analyzer": "rules"})</div>
+    </div>
+    
     <h2>API Documentation:</h2>
     
     <div class="endpoint">
@@ -218,53 +444,89 @@ func main() {
 `))
 	})
 
-	// Wrap mux with tracing middleware
-	handler := tracingMiddleware(tracer, mux)
+	// Wrap mux with tracing, seeding, virtual time and fault injection middleware
+	return tracingMiddleware(tracer, seedMiddleware(virtualTimeMiddleware(concurrencyMiddleware(faultInjectionMiddleware(mux)))))
+}
 
-	// Setup HTTP server
-	port := getEnv("PORT", "8080")
-	server := &http.Server{
-		Addr:         ":" + port,
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
+// tracingMiddleware adds tracing context propagation
+func tracingMiddleware(tracer trace.Tracer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Extract trace context from incoming request
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		r = r.WithContext(ctx)
 
-	// Start server in a goroutine
-	go func() {
-		log.Printf("Server listening on port %s", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		// Call next handler
+		next.ServeHTTP(w, r)
+	})
+}
+
+// seedMiddleware seeds every random draw of the request from X-Seed or the seed query parameter,
+// drawing a fresh seed (derived from DEMO_SEED, if set) otherwise. The seed is echoed in the X-Seed response header
+// and recorded as demo.seed, so replaying a request with it regenerates the same trace.
+func seedMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seed, ok, err := random.SeedFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !ok {
+			seed = random.NewSeed()
 		}
-	}()
 
-	// Wait for interrupt signal to gracefully shutdown the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+		w.Header().Set(random.Header, strconv.FormatInt(seed, 10))
+		next.ServeHTTP(w, r.WithContext(random.WithSeed(r.Context(), seed)))
+	})
+}
 
-	log.Println("Shutting down server...")
+// virtualTimeMiddleware runs the request on a virtual clock when X-Synthetic-Time, the synthetic_time
+// query parameter or DEMO_SYNTHETIC_TIME asks for it: simulated work advances the clock instead of
+// sleeping and spans get the clock's timestamps, so traces are generated without waiting
+func virtualTimeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, ok, err := clock.StartFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ok {
+			r = r.WithContext(clock.WithVirtual(r.Context(), start))
+		}
 
-	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+		next.ServeHTTP(w, r)
+	})
+}
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
-	}
+// concurrencyMiddleware sets how many branches the fan-out stages (fetchDataFromMultipleSources,
+// processItems, sendNotification) run at once, from X-Concurrency, the concurrency query parameter
+// or DEMO_CONCURRENCY; without it they run one after another
+func concurrencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, ok, err := concurrency.LimitFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ok {
+			r = r.WithContext(concurrency.WithLimit(r.Context(), limit))
+		}
 
-	log.Println("Server stopped")
+		next.ServeHTTP(w, r)
+	})
 }
 
-// tracingMiddleware adds tracing context propagation
-func tracingMiddleware(tracer trace.Tracer, next http.Handler) http.Handler {
+// faultInjectionMiddleware applies the faults of the X-Inject-Fault header to the request
+func faultInjectionMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract trace context from incoming request
-		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
-		r = r.WithContext(ctx)
+		if value := r.Header.Get(faults.Header); value != "" {
+			ctx, err := faults.WithRequestHeader(r.Context(), value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s header: %v", faults.Header, err), http.StatusBadRequest)
+				return
+			}
+			r = r.WithContext(ctx)
+		}
 
-		// Call next handler
 		next.ServeHTTP(w, r)
 	})
 }
@@ -67,7 +67,8 @@ type SearchResult struct {
 
 // BatchRequest represents a batch processing request
 type BatchRequest struct {
-	Items []string `json:"items"`
+	Items        []string `json:"items"`
+	Traceparents []string `json:"traceparents,omitempty"` // W3C traceparent of the trace that enqueued each item, in item order
 }
 
 // BatchResponse represents batch processing response
@@ -95,6 +96,17 @@ type SimulateResponse struct {
 	Message   string `json:"message"`
 }
 
+// ScenarioRunResponse represents the result of running a scenario
+type ScenarioRunResponse struct {
+	TraceID    string `json:"trace_id" example:"xyz789"`
+	Scenario   string `json:"scenario" example:"checkout-slow-payment"`
+	SpanCount  int    `json:"span_count" example:"12"`
+	ErrorCount int    `json:"error_count" example:"1"`
+	Duration   string `json:"duration" example:"1.234s"`
+	TimedOut   bool   `json:"timed_out,omitempty" example:"false"`
+	Message    string `json:"message" example:"Scenario checkout-slow-payment generated 12 spans"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
@@ -104,12 +116,12 @@ type ErrorResponse struct {
 
 // SourceCodeMapping represents the mapping between span operation name and source code location
 type SourceCodeMapping struct {
-	SpanName     string `json:"span_name" example:"POST /api/order/create"`     // e.g., "POST /api/order/create"
-	FilePath     string `json:"file_path" example:"handlers/order.go"`          // e.g., "handlers/order.go"
-	FunctionName string `json:"function_name" example:"CreateOrder"`            // e.g., "CreateOrder"
-	StartLine    int    `json:"start_line" example:"21"`                        // Starting line number
-	EndLine      int    `json:"end_line" example:"85"`                          // Ending line number
-	Description  string `json:"description" example:"Handles order creation"`   // Optional description
+	SpanName     string `json:"span_name" example:"POST /api/order/create"`   // e.g., "POST /api/order/create"
+	FilePath     string `json:"file_path" example:"handlers/order.go"`        // e.g., "handlers/order.go"
+	FunctionName string `json:"function_name" example:"CreateOrder"`          // e.g., "CreateOrder"
+	StartLine    int    `json:"start_line" example:"21"`                      // Starting line number
+	EndLine      int    `json:"end_line" example:"85"`                        // Ending line number
+	Description  string `json:"description" example:"Handles order creation"` // Optional description
 }
 
 // SourceCodeResponse represents the response containing source code and metadata
@@ -122,6 +134,214 @@ type SourceCodeResponse struct {
 	SourceCode   string `json:"source_code" example:"func CreateOrder(w http.ResponseWriter, r *http.Request) {...}"`
 }
 
+// SpanSourceCodeResponse represents source code for a span looked up in Tempo, with its runtime details
+type SpanSourceCodeResponse struct {
+	SpanID       string            `json:"span_id" example:"abc123"`
+	SpanName     string            `json:"span_name" example:"POST /api/order/create"`
+	TraceID      string            `json:"trace_id" example:"xyz789"`
+	Duration     string            `json:"duration" example:"1.23s"`
+	FilePath     string            `json:"file_path" example:"handlers/order.go"`
+	FunctionName string            `json:"function_name" example:"CreateOrder"`
+	StartLine    int               `json:"start_line" example:"21"`
+	EndLine      int               `json:"end_line" example:"85"`
+	SourceCode   string            `json:"source_code" example:"func CreateOrder(w http.ResponseWriter, r *http.Request) {...}"`
+	Attributes   map[string]string `json:"attributes"`
+	ChildSpans   []ChildSpanInfo   `json:"child_spans"`
+}
+
+// ChildSpanInfo represents a direct child of a span
+type ChildSpanInfo struct {
+	SpanID       string `json:"span_id" example:"def456"`
+	SpanName     string `json:"span_name" example:"validateOrder"`
+	Duration     string `json:"duration" example:"52.3ms"`
+	FunctionName string `json:"function_name,omitempty" example:"validateOrder"`
+}
+
+// SpanTiming represents the timing breakdown of a single span
+type SpanTiming struct {
+	SpanID         string `json:"span_id" example:"def456"`
+	SpanName       string `json:"span_name" example:"processPayment"`
+	ParentSpanID   string `json:"parent_span_id,omitempty" example:"abc123"`
+	Depth          int    `json:"depth" example:"1"`
+	DurationUs     int64  `json:"duration_us" example:"5350000"`
+	SelfTimeUs     int64  `json:"self_time_us" example:"5000000"`
+	Duration       string `json:"duration" example:"5.35s"`
+	SelfTime       string `json:"self_time" example:"5.00s"`
+	OnCriticalPath bool   `json:"on_critical_path" example:"true"`
+	CriticalPathUs int64  `json:"critical_path_us" example:"5000000"`
+}
+
+// CriticalPathStep represents a span's contribution to the critical path
+type CriticalPathStep struct {
+	SpanID       string `json:"span_id" example:"def456"`
+	SpanName     string `json:"span_name" example:"processPayment"`
+	OffsetUs     int64  `json:"offset_us" example:"250000"`
+	DurationUs   int64  `json:"duration_us" example:"5000000"`
+	Duration     string `json:"duration" example:"5.00s"`
+	FunctionName string `json:"function_name,omitempty" example:"processPayment"`
+}
+
+// CriticalPathResponse represents the critical path analysis of a trace
+type CriticalPathResponse struct {
+	TraceID         string             `json:"trace_id" example:"xyz789"`
+	RootSpanID      string             `json:"root_span_id" example:"abc123"`
+	RootSpanName    string             `json:"root_span_name" example:"POST /api/order/create"`
+	TotalDurationUs int64              `json:"total_duration_us" example:"5900000"`
+	TotalDuration   string             `json:"total_duration" example:"5.90s"`
+	CriticalPath    []CriticalPathStep `json:"critical_path"`
+	Spans           []SpanTiming       `json:"spans"`
+}
+
+// SpanRef represents a short reference to a related span
+type SpanRef struct {
+	SpanID       string `json:"span_id" example:"def456"`
+	SpanName     string `json:"span_name" example:"callPaymentGateway"`
+	DurationUs   int64  `json:"duration_us" example:"320000"`
+	Duration     string `json:"duration" example:"320.00ms"`
+	FunctionName string `json:"function_name,omitempty" example:"callPaymentGateway"`
+}
+
+// BundleSpan represents one of the slowest spans of a trace with its full analysis context
+type BundleSpan struct {
+	Rank           int               `json:"rank" example:"1"`
+	SpanID         string            `json:"span_id" example:"abc123"`
+	SpanName       string            `json:"span_name" example:"processPayment"`
+	DurationUs     int64             `json:"duration_us" example:"5350000"`
+	SelfTimeUs     int64             `json:"self_time_us" example:"5000000"`
+	Duration       string            `json:"duration" example:"5.35s"`
+	SelfTime       string            `json:"self_time" example:"5.00s"`
+	OnCriticalPath bool              `json:"on_critical_path" example:"true"`
+	FilePath       string            `json:"file_path,omitempty" example:"handlers/order.go"`
+	FunctionName   string            `json:"function_name,omitempty" example:"processPayment"`
+	StartLine      int               `json:"start_line,omitempty" example:"142"`
+	EndLine        int               `json:"end_line,omitempty" example:"167"`
+	SourceCode     string            `json:"source_code,omitempty" example:"func processPayment(...) {...}"`
+	SourceError    string            `json:"source_error,omitempty" example:"no source code mapping"`
+	Attributes     map[string]string `json:"attributes"`
+	ParentChain    []SpanRef         `json:"parent_chain"`
+	Children       []SpanRef         `json:"children"`
+	Events         []SpanEvent       `json:"events,omitempty"`
+	Exceptions     []SpanException   `json:"exceptions,omitempty"`
+	Links          []SpanLink        `json:"links,omitempty"`
+}
+
+// SpanEvent represents a span event such as a retry, cache miss or GC pause
+type SpanEvent struct {
+	Name       string            `json:"name" example:"retry"`
+	OffsetUs   int64             `json:"offset_us" example:"320000"` // Time since the span started
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// SpanException represents an exception recorded on a span
+type SpanException struct {
+	Type       string `json:"type" example:"*faults.InjectedError"`
+	Message    string `json:"message" example:"payment provider timeout"`
+	StackTrace string `json:"stack_trace,omitempty" example:"goroutine 42 [running]:..."`
+}
+
+// SpanLink represents a link from a span to a span in the same or another trace
+type SpanLink struct {
+	TraceID    string            `json:"trace_id" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+	SpanID     string            `json:"span_id" example:"00f067aa0ba902b7"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// AnalysisBundle represents a self-contained document describing the slowest spans of a trace
+type AnalysisBundle struct {
+	TraceID         string             `json:"trace_id" example:"xyz789"`
+	RootSpanName    string             `json:"root_span_name" example:"POST /api/order/create"`
+	TotalDurationUs int64              `json:"total_duration_us" example:"5900000"`
+	TotalDuration   string             `json:"total_duration" example:"5.90s"`
+	SpanCount       int                `json:"span_count" example:"12"`
+	CriticalPath    []CriticalPathStep `json:"critical_path"`
+	Spans           []BundleSpan       `json:"spans"`
+}
+
+// AnalyzeRequest represents a request to diagnose a trace.
+// Either TraceID or Bundle must be set; a provided Bundle is analyzed as-is without querying Tempo.
+type AnalyzeRequest struct {
+	TraceID  string          `json:"trace_id,omitempty" example:"xyz789"`
+	Top      int             `json:"top,omitempty" example:"5"`
+	Analyzer string          `json:"analyzer,omitempty" example:"rules"`
+	Bundle   *AnalysisBundle `json:"bundle,omitempty"`
+}
+
+// EvidenceSpan represents a span supporting a diagnosis
+type EvidenceSpan struct {
+	SpanID   string `json:"span_id" example:"abc123"`
+	SpanName string `json:"span_name" example:"processPayment"`
+	SelfTime string `json:"self_time,omitempty" example:"5.00s"`
+	Reason   string `json:"reason" example:"slow.reason=simulated_delay"`
+}
+
+// Diagnosis represents the structured result of analyzing a trace
+type Diagnosis struct {
+	TraceID        string         `json:"trace_id" example:"xyz789"`
+	Analyzer       string         `json:"analyzer" example:"rules"`
+	SuspectedCause string         `json:"suspected_cause" example:"processPayment contains an injected 5s delay"`
+	EvidenceSpans  []EvidenceSpan `json:"evidence_spans"`
+	SuggestedFix   string         `json:"suggested_fix" example:"Remove the simulated delay from processPayment"`
+	Confidence     float64        `json:"confidence" example:"0.95"`
+}
+
+// AttributeChange represents an attribute whose value differs between two aligned spans.
+// An empty Base or Target means the attribute is absent on that side.
+type AttributeChange struct {
+	Key    string `json:"key" example:"simulate.slow"`
+	Base   string `json:"base" example:"false"`
+	Target string `json:"target" example:"true"`
+}
+
+// SpanDiff represents a pair of aligned spans from two traces
+type SpanDiff struct {
+	Path              string            `json:"path" example:"POST /api/order/create/processPayment[0]"`
+	SpanName          string            `json:"span_name" example:"processPayment"`
+	BaseSpanID        string            `json:"base_span_id" example:"def456"`
+	TargetSpanID      string            `json:"target_span_id" example:"fed654"`
+	BaseDurationUs    int64             `json:"base_duration_us" example:"350000"`
+	TargetDurationUs  int64             `json:"target_duration_us" example:"5350000"`
+	DurationDeltaUs   int64             `json:"duration_delta_us" example:"5000000"`
+	DurationDelta     string            `json:"duration_delta" example:"+5.00s"`
+	SelfTimeDeltaUs   int64             `json:"self_time_delta_us" example:"5000000"`
+	SelfTimeDelta     string            `json:"self_time_delta" example:"+5.00s"`
+	FunctionName      string            `json:"function_name,omitempty" example:"processPayment"`
+	ChangedAttributes []AttributeChange `json:"changed_attributes"`
+}
+
+// DiffSpan represents a span present in only one of two compared traces
+type DiffSpan struct {
+	Path         string `json:"path" example:"POST /api/order/create/retryPayment[0]"`
+	SpanID       string `json:"span_id" example:"aaa111"`
+	SpanName     string `json:"span_name" example:"retryPayment"`
+	DurationUs   int64  `json:"duration_us" example:"120000"`
+	Duration     string `json:"duration" example:"120.00ms"`
+	FunctionName string `json:"function_name,omitempty" example:"retryPayment"`
+}
+
+// DiffHotspot represents the aligned span whose self-time changed the most, with its mapped source
+type DiffHotspot struct {
+	SpanDiff
+	FilePath    string `json:"file_path,omitempty" example:"handlers/order.go"`
+	StartLine   int    `json:"start_line,omitempty" example:"147"`
+	EndLine     int    `json:"end_line,omitempty" example:"171"`
+	SourceCode  string `json:"source_code,omitempty" example:"func processPayment(...) {...}"`
+	SourceError string `json:"source_error,omitempty" example:"no source code mapping"`
+}
+
+// TraceDiffResponse represents the comparison of a base trace with a target trace
+type TraceDiffResponse struct {
+	BaseTraceID      string       `json:"base_trace_id" example:"xyz789"`
+	TargetTraceID    string       `json:"target_trace_id" example:"uvw456"`
+	BaseDurationUs   int64        `json:"base_duration_us" example:"900000"`
+	TargetDurationUs int64        `json:"target_duration_us" example:"5900000"`
+	DurationDeltaUs  int64        `json:"duration_delta_us" example:"5000000"`
+	DurationDelta    string       `json:"duration_delta" example:"+5.00s"`
+	Hotspot          *DiffHotspot `json:"hotspot,omitempty"`
+	Spans            []SpanDiff   `json:"spans"`
+	Added            []DiffSpan   `json:"added"`
+	Missing          []DiffSpan   `json:"missing"`
+}
+
 // MappingRequest represents a request to add/update source code mapping
 type MappingRequest struct {
 	Mappings []SourceCodeMapping `json:"mappings"`
@@ -0,0 +1,201 @@
+package random
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header and QueryParam carry the seed of a request
+const (
+	Header     = "X-Seed"
+	QueryParam = "seed"
+)
+
+// EnvVar names the global seed used for requests that do not carry their own
+const EnvVar = "DEMO_SEED"
+
+// Rand is a math/rand source that is safe for concurrent use
+type Rand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// New creates a Rand seeded with seed
+func New(seed int64) *Rand {
+	return &Rand{r: rand.New(rand.NewSource(seed))}
+}
+
+// Intn returns a pseudo-random number in [0, n)
+func (r *Rand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Intn(n)
+}
+
+// Int returns a non-negative pseudo-random int
+func (r *Rand) Int() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Int()
+}
+
+// Int63 returns a non-negative pseudo-random 63-bit integer
+func (r *Rand) Int63() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Int63()
+}
+
+// Float64 returns a pseudo-random number in [0.0, 1.0)
+func (r *Rand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Float64()
+}
+
+// NormFloat64 returns a normally distributed number with mean 0 and standard deviation 1
+func (r *Rand) NormFloat64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.NormFloat64()
+}
+
+// ExpFloat64 returns an exponentially distributed number with rate 1
+func (r *Rand) ExpFloat64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.ExpFloat64()
+}
+
+// reseed restarts r's sequence from seed
+func (r *Rand) reseed(seed int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.r.Seed(seed)
+}
+
+// Fork returns a new Rand seeded from r, for work whose draws must not depend on
+// how it interleaves with other goroutines
+func (r *Rand) Fork() *Rand {
+	return New(r.Int63())
+}
+
+// RemoteSpanContext returns a sampled remote span context with IDs drawn from r,
+// standing in for a span of a trace outside the demo, e.g. the producer of a queued message
+func (r *Rand) RemoteSpanContext() trace.SpanContext {
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	r.mu.Lock()
+	r.r.Read(traceID[:])
+	r.r.Read(spanID[:])
+	r.mu.Unlock()
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+}
+
+// global backs requests without a seed and draws fresh seeds; DEMO_SEED reseeds it
+var global = New(time.Now().UnixNano())
+
+type seedKey struct{}
+
+type seeded struct {
+	seed    int64
+	hasSeed bool
+	rand    *Rand
+}
+
+// WithSeed returns a context whose random draws are all derived from seed
+func WithSeed(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, seedKey{}, &seeded{seed: seed, hasSeed: true, rand: New(seed)})
+}
+
+// WithRand returns a context whose random draws come from r, keeping the request's seed.
+// Use it with a Fork for work that runs concurrently with its siblings.
+func WithRand(ctx context.Context, r *Rand) context.Context {
+	s := &seeded{rand: r}
+	if parent, ok := ctx.Value(seedKey{}).(*seeded); ok {
+		s.seed, s.hasSeed = parent.seed, parent.hasSeed
+	}
+	return context.WithValue(ctx, seedKey{}, s)
+}
+
+// FromContext returns the Rand of the request's seed, or a shared unseeded Rand
+func FromContext(ctx context.Context) *Rand {
+	if s, ok := ctx.Value(seedKey{}).(*seeded); ok {
+		return s.rand
+	}
+	return global
+}
+
+// SeedFromContext returns the seed carried by ctx
+func SeedFromContext(ctx context.Context) (int64, bool) {
+	if s, ok := ctx.Value(seedKey{}).(*seeded); ok && s.hasSeed {
+		return s.seed, true
+	}
+	return 0, false
+}
+
+// NewSeed draws a fresh seed for a request that did not ask for one.
+// With DEMO_SEED set the seeds are derived from it, so every request still gets its own seed
+// but a restarted process hands out the same sequence of seeds.
+func NewSeed() int64 {
+	GlobalSeed()
+	return global.Int63()
+}
+
+var (
+	globalSeedOnce sync.Once
+	globalSeed     int64
+	globalSeedSet  bool
+	globalSeedErr  error
+)
+
+// GlobalSeed returns the seed set by DEMO_SEED, seeding the source NewSeed draws from with it
+func GlobalSeed() (int64, bool, error) {
+	globalSeedOnce.Do(func() {
+		value := strings.TrimSpace(os.Getenv(EnvVar))
+		if value == "" {
+			return
+		}
+		globalSeed, globalSeedErr = strconv.ParseInt(value, 10, 64)
+		if globalSeedErr != nil {
+			globalSeedErr = fmt.Errorf("invalid %s %q: must be an integer", EnvVar, value)
+			return
+		}
+		globalSeedSet = true
+		global.reseed(globalSeed)
+	})
+	return globalSeed, globalSeedSet, globalSeedErr
+}
+
+// SeedFromRequest returns the seed of a request: the X-Seed header or the seed query parameter,
+// in that order. Requests without one get a seed from NewSeed.
+func SeedFromRequest(r *http.Request) (int64, bool, error) {
+	value := r.Header.Get(Header)
+	if value == "" {
+		value = r.URL.Query().Get(QueryParam)
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+
+	seed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid seed %q: must be an integer", value)
+	}
+	return seed, true, nil
+}
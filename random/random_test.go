@@ -0,0 +1,78 @@
+package random
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// resetGlobalSeed makes the next GlobalSeed call read DEMO_SEED again
+func resetGlobalSeed() {
+	globalSeedOnce = sync.Once{}
+	globalSeed, globalSeedSet, globalSeedErr = 0, false, nil
+}
+
+func TestNewSeedDerivesFromGlobalSeed(t *testing.T) {
+	t.Setenv(EnvVar, "42")
+	t.Cleanup(resetGlobalSeed)
+
+	draw := func() []int64 {
+		resetGlobalSeed()
+		return []int64{NewSeed(), NewSeed(), NewSeed()}
+	}
+
+	first := draw()
+	if first[0] == 42 || first[0] == first[1] || first[1] == first[2] {
+		t.Errorf("seeds = %v, want distinct seeds derived from %s", first, EnvVar)
+	}
+	second := draw()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("seeds after restart = %v, want the same sequence %v", second, first)
+		}
+	}
+}
+
+func TestSeedFromRequest(t *testing.T) {
+	t.Setenv(EnvVar, "42")
+	t.Cleanup(resetGlobalSeed)
+	resetGlobalSeed()
+
+	tests := []struct {
+		target  string
+		header  string
+		want    int64
+		wantOK  bool
+		wantErr bool
+	}{
+		{target: "/", header: "7", want: 7, wantOK: true},
+		{target: "/?seed=8", want: 8, wantOK: true},
+		{target: "/?seed=8", header: "7", want: 7, wantOK: true},
+		{target: "/"}, // DEMO_SEED only derives fresh seeds, it is not the request's seed
+		{target: "/?seed=abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		if tt.header != "" {
+			r.Header.Set(Header, tt.header)
+		}
+		seed, ok, err := SeedFromRequest(r)
+		if (err != nil) != tt.wantErr || ok != tt.wantOK || seed != tt.want {
+			t.Errorf("%s (X-Seed %q) = %d, %v, %v; want %d, %v, error %v",
+				tt.target, tt.header, seed, ok, err, tt.want, tt.wantOK, tt.wantErr)
+		}
+	}
+}
+
+func TestSameSeedSameDraws(t *testing.T) {
+	a, b := New(42), New(42)
+	for i := 0; i < 100; i++ {
+		if x, y := a.Int63(), b.Int63(); x != y {
+			t.Fatalf("draw %d: %d != %d", i, x, y)
+		}
+	}
+	if a.Fork().Int63() != b.Fork().Int63() {
+		t.Error("forks of equally seeded sources differ")
+	}
+}
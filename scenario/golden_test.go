@@ -0,0 +1,86 @@
+package scenario
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/random"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// runSeeded runs the scenario file with seed on a virtual clock and renders the spans it emitted,
+// leaving out the IDs the SDK generates itself
+func runSeeded(t *testing.T, file string, seed int64) string {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	ctx := clock.WithVirtual(context.Background(), time.Unix(1700000000, 0))
+	ctx = random.WithSeed(ctx, seed)
+	NewRunner(provider.Tracer("golden")).Run(ctx, s)
+
+	var b strings.Builder
+	for _, span := range recorder.Ended() {
+		fmt.Fprintf(&b, "%s kind=%s status=%s", span.Name(), span.SpanKind(), span.Status().Code)
+		for _, attr := range span.Attributes() {
+			if attr.Key == "span.work_ms" || attr.Key == "scenario.repeat_index" {
+				fmt.Fprintf(&b, " %s=%s", attr.Key, attr.Value.Emit())
+			}
+		}
+		for _, event := range span.Events() {
+			fmt.Fprintf(&b, " event=%s", event.Name)
+		}
+		for _, link := range span.Links() {
+			if link.SpanContext.IsRemote() {
+				fmt.Fprintf(&b, " link=%s", link.SpanContext.TraceID())
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestSeededRunMatchesGolden(t *testing.T) {
+	got := runSeeded(t, "../scenarios/batch-fan-in.yaml", 42)
+	if again := runSeeded(t, "../scenarios/batch-fan-in.yaml", 42); again != got {
+		t.Fatalf("two runs with the same seed differ:\n%s\n---\n%s", got, again)
+	}
+
+	golden := filepath.Join("testdata", "batch-fan-in.seed42.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("run with seed 42 does not match %s:\n%s\nwant:\n%s", golden, got, want)
+	}
+
+	if other := runSeeded(t, "../scenarios/batch-fan-in.yaml", 43); other == got {
+		t.Error("runs with different seeds are identical")
+	}
+}
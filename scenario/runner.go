@@ -0,0 +1,230 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"tempo-otlp-trace-demo/anomalies"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/concurrency"
+	"tempo-otlp-trace-demo/random"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var spanKinds = map[string]trace.SpanKind{
+	"":         trace.SpanKindInternal,
+	"internal": trace.SpanKindInternal,
+	"server":   trace.SpanKindServer,
+	"client":   trace.SpanKindClient,
+	"producer": trace.SpanKindProducer,
+	"consumer": trace.SpanKindConsumer,
+}
+
+// Result summarizes a scenario run
+type Result struct {
+	SpanCount  int
+	ErrorCount int
+	TimedOut   bool // The run hit MaxRunDuration and its remaining work was cut short
+}
+
+// Runner executes scenarios, emitting their spans with the given tracer
+type Runner struct {
+	tracer trace.Tracer
+
+	spanCount  atomic.Int64
+	errorCount atomic.Int64
+
+	mu      sync.Mutex
+	started map[string]trace.SpanContext // latest span started for each name, for links
+}
+
+// NewRunner creates a runner that emits spans with the given tracer
+func NewRunner(tracer trace.Tracer) *Runner {
+	return &Runner{
+		tracer:  tracer,
+		started: make(map[string]trace.SpanContext),
+	}
+}
+
+// Run executes a validated scenario as children of the span in ctx.
+// Durations and errors are drawn from the request's seed: every span gets its own source,
+// forked in declaration order, so parallel children draw the same values on every run.
+// The run ends after MaxRunDuration at the latest: once the deadline passes, the remaining
+// spans are still emitted but no longer wait for their work.
+func (r *Runner) Run(ctx context.Context, s *Scenario) Result {
+	ctx, cancel := context.WithTimeout(ctx, MaxRunDuration)
+	defer cancel()
+
+	r.runChildren(ctx, []SpanSpec{s.Root}, 1, random.FromContext(ctx).Fork())
+
+	return Result{
+		SpanCount:  int(r.spanCount.Load()),
+		ErrorCount: int(r.errorCount.Load()),
+		TimedOut:   errors.Is(ctx.Err(), context.DeadlineExceeded),
+	}
+}
+
+// runSpan emits one copy of a span spec; repeatIndex is -1 for specs that are not repeated
+func (r *Runner) runSpan(ctx context.Context, spec *SpanSpec, repeatIndex int, rng *random.Rand) {
+	ctx, span := r.tracer.Start(ctx, spec.Name,
+		trace.WithSpanKind(spanKinds[strings.ToLower(spec.Kind)]),
+		trace.WithLinks(r.links(spec, rng)...),
+	)
+	defer span.End()
+	r.spanCount.Add(1)
+
+	r.mu.Lock()
+	r.started[spec.Name] = span.SpanContext()
+	r.mu.Unlock()
+
+	span.SetAttributes(attribute.String("span.type", "scenario"))
+	span.SetAttributes(attributesFromMap(spec.Attributes)...)
+	if repeatIndex >= 0 {
+		span.SetAttributes(attribute.Int("scenario.repeat_index", repeatIndex))
+	}
+
+	// Simulate the span's own work, emitting its events along the way
+	work := spec.Duration.Sample(rng)
+	r.work(ctx, span, spec.Events, work, rng)
+	span.SetAttributes(attribute.Int64("span.work_ms", work.Milliseconds()))
+
+	limit := 1
+	if spec.Parallel {
+		limit = spec.Concurrency
+	}
+	r.runChildren(ctx, spec.Children, limit, rng)
+
+	if spec.ErrorProbability > 0 && rng.Float64() < spec.ErrorProbability {
+		message := spec.ErrorMessage
+		if message == "" {
+			message = fmt.Sprintf("%s failed", spec.Name)
+		}
+		r.errorCount.Add(1)
+		anomalies.Record(ctx, span, anomalies.Anomaly{
+			SpanName:  spec.Name,
+			Type:      anomalies.TypeError,
+			Source:    "scenario",
+			Magnitude: spec.ErrorProbability,
+			Unit:      anomalies.UnitProbability,
+			Message:   message,
+		})
+		span.RecordError(errors.New(message), trace.WithStackTrace(true))
+		span.SetStatus(codes.Error, message)
+		return
+	}
+
+	span.SetStatus(codes.Ok, "span completed")
+}
+
+// links resolves the span's links: earlier scenario spans that have not started yet are skipped
+func (r *Runner) links(spec *SpanSpec, rng *random.Rand) []trace.Link {
+	links := make([]trace.Link, 0, len(spec.Links))
+	for _, l := range spec.Links {
+		attrs := attributesFromMap(l.Attributes)
+		switch {
+		case l.Span != "":
+			r.mu.Lock()
+			sc, ok := r.started[l.Span]
+			r.mu.Unlock()
+			if ok {
+				links = append(links, trace.Link{SpanContext: sc, Attributes: attrs})
+			}
+		case l.Count > 0:
+			for i := 0; i < l.Count; i++ {
+				links = append(links, trace.Link{SpanContext: rng.RemoteSpanContext(), Attributes: attrs})
+			}
+		default:
+			links = append(links, trace.Link{SpanContext: l.spanContext, Attributes: attrs})
+		}
+	}
+	return links
+}
+
+// work sleeps for the span's own work, emitting each event that fires at its offset into it
+func (r *Runner) work(ctx context.Context, span trace.Span, events []EventSpec, work time.Duration, rng *random.Rand) {
+	type firing struct {
+		event *EventSpec
+		at    time.Duration
+	}
+	firings := make([]firing, 0, len(events))
+	for i := range events {
+		event := &events[i]
+		at := min(event.At.Sample(rng), work)
+		if event.Probability > 0 && rng.Float64() >= event.Probability {
+			continue
+		}
+		firings = append(firings, firing{event: event, at: at})
+	}
+	sort.SliceStable(firings, func(i, j int) bool {
+		return firings[i].at < firings[j].at
+	})
+
+	elapsed := time.Duration(0)
+	for _, f := range firings {
+		clock.Sleep(ctx, f.at-elapsed)
+		elapsed = f.at
+		span.AddEvent(f.event.Name, trace.WithAttributes(attributesFromMap(f.event.Attributes)...))
+	}
+	clock.Sleep(ctx, work-elapsed)
+}
+
+// runChildren runs the children on up to limit workers: one after another when limit is 1,
+// all at once when it is 0. The parent resumes when the last of them ends.
+func (r *Runner) runChildren(ctx context.Context, children []SpanSpec, limit int, rng *random.Rand) {
+	var tasks []func(context.Context)
+	for i := range children {
+		child := &children[i]
+		for n := 0; n < child.repeat(); n++ {
+			repeatIndex := -1
+			if child.Repeat > 1 {
+				repeatIndex = n
+			}
+
+			childRng := rng.Fork()
+			tasks = append(tasks, func(ctx context.Context) {
+				r.runSpan(ctx, child, repeatIndex, childRng)
+			})
+		}
+	}
+	concurrency.Run(ctx, limit, tasks...)
+}
+
+// attributesFromMap converts scenario attributes to span attributes, keeping their types
+func attributesFromMap(values map[string]interface{}) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(values))
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			attrs = append(attrs, attribute.String(key, v))
+		case bool:
+			attrs = append(attrs, attribute.Bool(key, v))
+		case int:
+			attrs = append(attrs, attribute.Int(key, v))
+		case int64:
+			attrs = append(attrs, attribute.Int64(key, v))
+		case float64:
+			if v == float64(int64(v)) {
+				attrs = append(attrs, attribute.Int64(key, int64(v)))
+			} else {
+				attrs = append(attrs, attribute.Float64(key, v))
+			}
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			attrs = append(attrs, attribute.StringSlice(key, items))
+		default:
+			attrs = append(attrs, attribute.String(key, fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,472 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"tempo-otlp-trace-demo/concurrency"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
+)
+
+// Limits that keep a single scenario run bounded
+const (
+	MaxSpans        = 2000
+	MaxRepeat       = 100
+	MaxSpanDuration = 30 * time.Second
+	MaxLinks        = 128
+	MaxRunDuration  = 2 * time.Minute // Longest critical path a scenario may declare, and the deadline of a run
+)
+
+// Scenario declares the shape of a trace to generate
+type Scenario struct {
+	Name        string   `json:"name" yaml:"name" example:"checkout-slow-payment"`
+	Description string   `json:"description,omitempty" yaml:"description" example:"Checkout where the payment provider is slow"`
+	Root        SpanSpec `json:"root" yaml:"root"`
+}
+
+// SpanSpec declares a span, its own work and its children.
+// The span first spends Duration on its own work, then runs its children
+// one after another, or concurrently when Parallel is set, on up to Concurrency workers.
+type SpanSpec struct {
+	Name             string                 `json:"name" yaml:"name" example:"processPayment"`
+	Kind             string                 `json:"kind,omitempty" yaml:"kind" example:"client"` // internal (default), server, client, producer, consumer
+	Duration         DurationSpec           `json:"duration,omitempty" yaml:"duration"`
+	Attributes       map[string]interface{} `json:"attributes,omitempty" yaml:"attributes"`
+	ErrorProbability float64                `json:"error_probability,omitempty" yaml:"error_probability" example:"0.1"`
+	ErrorMessage     string                 `json:"error_message,omitempty" yaml:"error_message" example:"card declined"`
+	Parallel         bool                   `json:"parallel,omitempty" yaml:"parallel" example:"false"`
+	Concurrency      int                    `json:"concurrency,omitempty" yaml:"concurrency" example:"4"` // Max parallel children running at once (default: all)
+	Repeat           int                    `json:"repeat,omitempty" yaml:"repeat" example:"1"`           // Number of sibling copies of this span (default: 1)
+	Children         []SpanSpec             `json:"children,omitempty" yaml:"children"`
+	Events           []EventSpec            `json:"events,omitempty" yaml:"events"`
+	Links            []LinkSpec             `json:"links,omitempty" yaml:"links"`
+}
+
+// EventSpec declares a span event emitted during the span's own work, At into it
+// (clamped to the work's duration), with the given probability (default: always)
+type EventSpec struct {
+	Name        string                 `json:"name" yaml:"name" example:"cache.miss"`
+	At          DurationSpec           `json:"at,omitempty" yaml:"at"`
+	Probability float64                `json:"probability,omitempty" yaml:"probability" example:"0.3"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty" yaml:"attributes"`
+}
+
+// LinkSpec declares span links. Exactly one target is set:
+//
+//	span:     name of an earlier span of the scenario, linking to its latest copy
+//	trace_id: a span of another trace, with span_id
+//	count:    that many stand-in upstream traces drawn from the seed, e.g. for batch fan-in
+type LinkSpec struct {
+	Span       string                 `json:"span,omitempty" yaml:"span" example:"publishOrderCreated"`
+	TraceID    string                 `json:"trace_id,omitempty" yaml:"trace_id" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+	SpanID     string                 `json:"span_id,omitempty" yaml:"span_id" example:"00f067aa0ba902b7"`
+	Count      int                    `json:"count,omitempty" yaml:"count" example:"5"`
+	Attributes map[string]interface{} `json:"attributes,omitempty" yaml:"attributes"`
+
+	spanContext trace.SpanContext
+}
+
+// DurationSpec declares how long a span's own work takes.
+// It is written either as a duration ("120ms"), a number of milliseconds,
+// or an object selecting a distribution:
+//
+//	constant:    {distribution: constant, value: 120ms}
+//	uniform:     {distribution: uniform, min: 50ms, max: 200ms}
+//	normal:      {distribution: normal, mean: 120ms, stddev: 30ms}
+//	exponential: {distribution: exponential, mean: 80ms}
+type DurationSpec struct {
+	Distribution string `json:"distribution,omitempty" yaml:"distribution" example:"uniform"`
+	Value        string `json:"value,omitempty" yaml:"value" example:"120ms"`
+	Min          string `json:"min,omitempty" yaml:"min" example:"50ms"`
+	Max          string `json:"max,omitempty" yaml:"max" example:"200ms"`
+	Mean         string `json:"mean,omitempty" yaml:"mean" example:"120ms"`
+	StdDev       string `json:"stddev,omitempty" yaml:"stddev" example:"30ms"`
+
+	value, min, max, mean, stddev time.Duration
+}
+
+// Distribution names accepted in DurationSpec
+const (
+	DistributionConstant    = "constant"
+	DistributionUniform     = "uniform"
+	DistributionNormal      = "normal"
+	DistributionExponential = "exponential"
+)
+
+// durationSpecFields avoids recursing into the custom unmarshalers
+type durationSpecFields DurationSpec
+
+// UnmarshalJSON accepts a duration string, a number of milliseconds or a distribution object
+func (d *DurationSpec) UnmarshalJSON(data []byte) error {
+	var fields durationSpecFields
+	if err := json.Unmarshal(data, &fields); err == nil {
+		*d = DurationSpec(fields)
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return d.setScalar(value)
+}
+
+// UnmarshalYAML accepts a duration string, a number of milliseconds or a distribution mapping
+func (d *DurationSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		var fields durationSpecFields
+		if err := node.Decode(&fields); err != nil {
+			return err
+		}
+		*d = DurationSpec(fields)
+		return nil
+	}
+
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	return d.setScalar(value)
+}
+
+func (d *DurationSpec) setScalar(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		d.Value = v
+	case int:
+		d.Value = strconv.Itoa(v) + "ms"
+	case float64:
+		d.Value = strconv.FormatFloat(v, 'f', -1, 64) + "ms"
+	case nil:
+	default:
+		return fmt.Errorf("invalid duration %v", value)
+	}
+	d.Distribution = DistributionConstant
+	return nil
+}
+
+// Parse decodes a scenario from YAML or JSON and validates it
+func Parse(data []byte) (*Scenario, error) {
+	var s Scenario
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &s); err != nil {
+			return nil, fmt.Errorf("invalid scenario JSON: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid scenario YAML: %w", err)
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks the scenario and resolves its durations
+func (s *Scenario) Validate() error {
+	if s.Root.Name == "" {
+		return fmt.Errorf("scenario root span must have a name")
+	}
+	if s.Name == "" {
+		s.Name = s.Root.Name
+	}
+
+	if err := s.Root.validate("root"); err != nil {
+		return err
+	}
+	if err := s.Root.validateLinks(s.Root.names(make(map[string]bool))); err != nil {
+		return err
+	}
+
+	if count := s.Root.SpanCount(); count > MaxSpans {
+		return fmt.Errorf("scenario generates %d spans, more than the limit of %d", count, MaxSpans)
+	}
+	if runTime := s.Root.RunTime(); runTime > MaxRunDuration {
+		return fmt.Errorf("scenario runs for up to %s on its critical path, more than the limit of %s", runTime, MaxRunDuration)
+	}
+	return nil
+}
+
+func (spec *SpanSpec) validate(path string) error {
+	if spec.Name == "" {
+		return fmt.Errorf("%s: span must have a name", path)
+	}
+	path = path + "/" + spec.Name
+
+	if _, ok := spanKinds[strings.ToLower(spec.Kind)]; !ok {
+		return fmt.Errorf("%s: unknown span kind %q", path, spec.Kind)
+	}
+	if spec.ErrorProbability < 0 || spec.ErrorProbability > 1 {
+		return fmt.Errorf("%s: error_probability must be between 0 and 1", path)
+	}
+	if spec.Repeat < 0 || spec.Repeat > MaxRepeat {
+		return fmt.Errorf("%s: repeat must be between 0 and %d", path, MaxRepeat)
+	}
+	if spec.Concurrency < 0 || spec.Concurrency > concurrency.MaxLimit {
+		return fmt.Errorf("%s: concurrency must be between 0 and %d", path, concurrency.MaxLimit)
+	}
+	if spec.Concurrency > 0 && !spec.Parallel {
+		return fmt.Errorf("%s: concurrency requires parallel children", path)
+	}
+	if err := spec.Duration.Resolve(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for i := range spec.Events {
+		if err := spec.Events[i].validate(); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for i := range spec.Links {
+		if err := spec.Links[i].validate(); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for i := range spec.Children {
+		if err := spec.Children[i].validate(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *EventSpec) validate() error {
+	if e.Name == "" {
+		return fmt.Errorf("event must have a name")
+	}
+	if e.Probability < 0 || e.Probability > 1 {
+		return fmt.Errorf("event %s: probability must be between 0 and 1", e.Name)
+	}
+	if err := e.At.Resolve(); err != nil {
+		return fmt.Errorf("event %s: %w", e.Name, err)
+	}
+	return nil
+}
+
+func (l *LinkSpec) validate() error {
+	targets := 0
+	for _, set := range []bool{l.Span != "", l.TraceID != "", l.Count != 0} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("link must set exactly one of span, trace_id or count")
+	}
+	if l.Count < 0 || l.Count > MaxLinks {
+		return fmt.Errorf("link count must be between 1 and %d", MaxLinks)
+	}
+
+	if l.TraceID != "" {
+		traceID, err := trace.TraceIDFromHex(l.TraceID)
+		if err != nil {
+			return fmt.Errorf("invalid link trace_id %q", l.TraceID)
+		}
+		spanID, err := trace.SpanIDFromHex(l.SpanID)
+		if err != nil {
+			return fmt.Errorf("invalid link span_id %q", l.SpanID)
+		}
+		l.spanContext = trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+			Remote:     true,
+		})
+	}
+	return nil
+}
+
+// names collects the span names of the spec and its descendants
+func (spec *SpanSpec) names(names map[string]bool) map[string]bool {
+	names[spec.Name] = true
+	for i := range spec.Children {
+		spec.Children[i].names(names)
+	}
+	return names
+}
+
+// validateLinks checks that links to scenario spans name a span of the scenario
+func (spec *SpanSpec) validateLinks(names map[string]bool) error {
+	for _, link := range spec.Links {
+		if link.Span != "" && !names[link.Span] {
+			return fmt.Errorf("%s: link to unknown span %q", spec.Name, link.Span)
+		}
+	}
+	for i := range spec.Children {
+		if err := spec.Children[i].validateLinks(names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SpanCount returns the number of spans the spec generates, including repeats and children
+func (spec *SpanSpec) SpanCount() int {
+	perCopy := 1
+	for i := range spec.Children {
+		perCopy += spec.Children[i].SpanCount()
+		if perCopy > MaxSpans {
+			break
+		}
+	}
+	return spec.repeat() * perCopy
+}
+
+// RunTime returns how long the spec runs, including repeats, when every duration takes its
+// upper bound (see DurationSpec.UpperBound). Parallel children are assigned to workers
+// the same way the runner assigns them.
+func (spec *SpanSpec) RunTime() time.Duration {
+	return time.Duration(spec.repeat()) * spec.copyRunTime()
+}
+
+// copyRunTime returns how long one copy of the spec runs: its own work, then its children
+func (spec *SpanSpec) copyRunTime() time.Duration {
+	var tasks []time.Duration
+	for i := range spec.Children {
+		child := &spec.Children[i]
+		copyRunTime := child.copyRunTime()
+		for n := 0; n < child.repeat(); n++ {
+			tasks = append(tasks, copyRunTime)
+		}
+	}
+
+	limit := 1
+	if spec.Parallel {
+		limit = spec.Concurrency
+	}
+	if limit < 1 || limit > len(tasks) {
+		limit = len(tasks)
+	}
+
+	// Worker w runs tasks w, w+limit, w+2*limit, ... one after another
+	var children time.Duration
+	for w := 0; w < limit; w++ {
+		var worker time.Duration
+		for i := w; i < len(tasks); i += limit {
+			worker += tasks[i]
+		}
+		children = max(children, worker)
+	}
+
+	return spec.Duration.UpperBound() + children
+}
+
+func (spec *SpanSpec) repeat() int {
+	if spec.Repeat < 1 {
+		return 1
+	}
+	return spec.Repeat
+}
+
+// Resolve validates the spec and parses its duration strings according to its distribution.
+// It must be called before Sample.
+func (d *DurationSpec) Resolve() error {
+	if d.Distribution == "" {
+		d.Distribution = DistributionConstant
+	}
+
+	var err error
+	parse := func(name, value string, required bool) time.Duration {
+		if err != nil {
+			return 0
+		}
+		if value == "" {
+			if required {
+				err = fmt.Errorf("%s distribution requires %s", d.Distribution, name)
+			}
+			return 0
+		}
+		parsed, parseErr := parseDuration(value)
+		if parseErr != nil {
+			err = fmt.Errorf("invalid %s %q: %v", name, value, parseErr)
+			return 0
+		}
+		if parsed < 0 || parsed > MaxSpanDuration {
+			err = fmt.Errorf("%s %q must be between 0 and %s", name, value, MaxSpanDuration)
+		}
+		return parsed
+	}
+
+	switch d.Distribution {
+	case DistributionConstant:
+		d.value = parse("value", d.Value, false)
+	case DistributionUniform:
+		d.min = parse("min", d.Min, true)
+		d.max = parse("max", d.Max, true)
+		if err == nil && d.max < d.min {
+			err = fmt.Errorf("uniform distribution max must not be less than min")
+		}
+	case DistributionNormal:
+		d.mean = parse("mean", d.Mean, true)
+		d.stddev = parse("stddev", d.StdDev, true)
+	case DistributionExponential:
+		d.mean = parse("mean", d.Mean, true)
+	default:
+		return fmt.Errorf("unknown distribution %q", d.Distribution)
+	}
+	return err
+}
+
+// Source is the randomness Sample draws from; *rand.Rand and *random.Rand satisfy it
+type Source interface {
+	Float64() float64
+	NormFloat64() float64
+	ExpFloat64() float64
+}
+
+// Sample draws a duration from the distribution, clamped to [0, MaxSpanDuration]
+func (d *DurationSpec) Sample(rng Source) time.Duration {
+	var sampled time.Duration
+	switch d.Distribution {
+	case DistributionUniform:
+		sampled = d.min + time.Duration(rng.Float64()*float64(d.max-d.min))
+	case DistributionNormal:
+		sampled = d.mean + time.Duration(rng.NormFloat64()*float64(d.stddev))
+	case DistributionExponential:
+		sampled = time.Duration(rng.ExpFloat64() * float64(d.mean))
+	default:
+		sampled = d.value
+	}
+
+	if sampled < 0 {
+		return 0
+	}
+	if sampled > MaxSpanDuration {
+		return MaxSpanDuration
+	}
+	return sampled
+}
+
+// UpperBound returns the longest duration the resolved distribution practically draws:
+// the constant value, the uniform max, the normal mean plus three standard deviations
+// or three times the exponential mean, clamped to MaxSpanDuration.
+// Draws beyond it are rare and are cut short by the run deadline.
+func (d *DurationSpec) UpperBound() time.Duration {
+	var bound time.Duration
+	switch d.Distribution {
+	case DistributionUniform:
+		bound = d.max
+	case DistributionNormal:
+		bound = d.mean + 3*d.stddev
+	case DistributionExponential:
+		bound = 3 * d.mean
+	default:
+		bound = d.value
+	}
+	return min(max(bound, 0), MaxSpanDuration)
+}
+
+// parseDuration parses a duration string, treating a plain number as milliseconds
+func parseDuration(value string) (time.Duration, error) {
+	if ms, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(ms * float64(time.Millisecond)), nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,106 @@
+package scenario
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExampleScenariosAreValid(t *testing.T) {
+	files, err := filepath.Glob("../scenarios/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example scenarios found: %v", err)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Parse(data); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestRunTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario string
+		want     time.Duration
+	}{
+		{
+			name: "sequential",
+			scenario: `
+root:
+  name: root
+  duration: 10ms
+  children:
+    - name: a
+      duration: 20ms
+      repeat: 3
+    - name: b
+      duration: {distribution: uniform, min: 10ms, max: 50ms}`,
+			want: 10*time.Millisecond + 3*20*time.Millisecond + 50*time.Millisecond,
+		},
+		{
+			name: "parallel",
+			scenario: `
+root:
+  name: root
+  parallel: true
+  children:
+    - name: a
+      duration: 100ms
+    - name: b
+      duration: {distribution: normal, mean: 50ms, stddev: 10ms}`,
+			want: 100 * time.Millisecond,
+		},
+		{
+			name: "bounded concurrency",
+			scenario: `
+root:
+  name: root
+  parallel: true
+  concurrency: 2
+  children:
+    - name: item
+      duration: {distribution: exponential, mean: 10ms}
+      repeat: 5`,
+			want: 3 * 30 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(tt.scenario))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := s.Root.RunTime(); got != tt.want {
+				t.Errorf("RunTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsLongRunTime(t *testing.T) {
+	// Every span is within MaxSpanDuration, but 10 x 20 x 1s runs for 200s sequentially
+	scenario := `
+root:
+  name: root
+  children:
+    - name: batch
+      repeat: 10
+      children:
+        - name: item
+          duration: 1s
+          repeat: 20`
+
+	_, err := Parse([]byte(scenario))
+	if err == nil || !strings.Contains(err.Error(), "critical path") {
+		t.Fatalf("err = %v, want critical path limit error", err)
+	}
+}
@@ -16,102 +16,72 @@ echo "Source Code Analysis - Example Workflow"
 echo "=========================================="
 echo ""
 
-# Step 1: Generate a trace
+# Step 1: Generate a trace with a known trace ID
+# The service extracts W3C TraceContext, so sending our own traceparent
+# makes the order spans part of a trace whose ID we already know.
 echo -e "${BLUE}Step 1: Generating a test trace...${NC}"
+TRACE_ID=$(od -An -N16 -tx1 /dev/urandom | tr -d ' \n')
+PARENT_SPAN_ID=$(od -An -N8 -tx1 /dev/urandom | tr -d ' \n')
 ORDER_RESPONSE=$(curl -s -X POST "${BASE_URL}/api/order/create" \
     -H "Content-Type: application/json" \
+    -H "traceparent: 00-${TRACE_ID}-${PARENT_SPAN_ID}-01" \
     -d '{
         "user_id": "demo_user_123",
         "product_id": "demo_product_456",
         "quantity": 3,
-        "price": 149.99
+        "price": 149.99,
+        "sleep": true
     }')
 
 ORDER_ID=$(echo "$ORDER_RESPONSE" | jq -r '.order_id')
 echo -e "${GREEN}✓ Order created: $ORDER_ID${NC}"
+echo -e "${GREEN}✓ Trace ID: $TRACE_ID${NC}"
 echo ""
 
 # Step 2: Wait for trace to be available
 echo -e "${BLUE}Step 2: Waiting for trace to be available in Tempo...${NC}"
-echo -e "${YELLOW}(In a real scenario, you would get trace_id and span_id from Grafana)${NC}"
-sleep 3
+for i in $(seq 1 15); do
+    STATUS=$(curl -s -o /dev/null -w "%{http_code}" "${BASE_URL}/api/traces/critical-path?trace_id=${TRACE_ID}")
+    if [ "$STATUS" = "200" ]; then
+        break
+    fi
+    sleep 2
+done
+if [ "$STATUS" != "200" ]; then
+    echo -e "${YELLOW}Trace not found in Tempo yet (status $STATUS), try again later with:${NC}"
+    echo -e "${YELLOW}curl \"${BASE_URL}/api/traces/analysis-bundle?trace_id=${TRACE_ID}&format=markdown\"${NC}"
+    exit 1
+fi
+echo -e "${GREEN}✓ Trace is available${NC}"
 echo ""
 
-# Step 3: Show how to query mappings
-echo -e "${BLUE}Step 3: Checking available source code mappings...${NC}"
-MAPPINGS=$(curl -s "${BASE_URL}/api/mappings")
-MAPPING_COUNT=$(echo "$MAPPINGS" | jq '.mappings | length')
-echo -e "${GREEN}✓ Found $MAPPING_COUNT source code mappings${NC}"
-echo ""
-echo "Sample mappings:"
-echo "$MAPPINGS" | jq '.mappings[0:3] | .[] | {span_name, function_name, file_path}'
-echo ""
-
-# Step 4: Explain the manual steps
-echo -e "${BLUE}Step 4: Manual steps to analyze the trace:${NC}"
-echo ""
-echo "1. Open Grafana: http://localhost:3000"
-echo "2. Go to Explore → Tempo"
-echo "3. Search for traces with service.name=\"trace-demo-service\""
-echo "4. Find the trace for your order (look for operation: POST /api/order/create)"
-echo "5. Click on the trace to view details"
-echo "6. Copy the Trace ID and Span ID from the UI"
-echo ""
-
-# Step 5: Show example API call
-echo -e "${BLUE}Step 5: Example API call (with placeholder IDs):${NC}"
-echo ""
-echo "Once you have the trace_id and span_id, run:"
-echo ""
-echo -e "${YELLOW}curl \"${BASE_URL}/api/source-code?span_id=YOUR_SPAN_ID&trace_id=YOUR_TRACE_ID\" | jq .${NC}"
+# Step 3: Show the critical path
+echo -e "${BLUE}Step 3: Critical path and slowest spans by self-time...${NC}"
+curl -s "${BASE_URL}/api/traces/critical-path?trace_id=${TRACE_ID}" | \
+    jq '{total_duration, slowest: [.spans[0:3][] | {span_name, self_time, on_critical_path}]}'
 echo ""
 
-# Step 6: Show example response structure
-echo -e "${BLUE}Step 6: Expected response structure:${NC}"
+# Step 4: Build the LLM analysis bundle
+BUNDLE_FILE="trace-${TRACE_ID}.md"
+echo -e "${BLUE}Step 4: Building LLM analysis bundle...${NC}"
+curl -s "${BASE_URL}/api/traces/analysis-bundle?trace_id=${TRACE_ID}&top=5&format=markdown" > "$BUNDLE_FILE"
+echo -e "${GREEN}✓ Bundle saved to $BUNDLE_FILE${NC}"
 echo ""
-cat << 'EOF'
-{
-  "span_id": "abc123...",
-  "span_name": "POST /api/order/create",
-  "trace_id": "xyz789...",
-  "duration": "1.23s",
-  "file_path": "handlers/order.go",
-  "function_name": "CreateOrder",
-  "start_line": 21,
-  "end_line": 85,
-  "source_code": "func CreateOrder(...) { ... }",
-  "attributes": {
-    "http.method": "POST",
-    "user.id": "demo_user_123"
-  },
-  "child_spans": [
-    {
-      "span_id": "def456",
-      "span_name": "validateOrder",
-      "duration": "52.3ms",
-      "function_name": "validateOrder"
-    },
-    {
-      "span_id": "ghi789",
-      "span_name": "processPayment",
-      "duration": "850.2ms",
-      "function_name": "processPayment"
-    }
-  ]
-}
-EOF
+head -20 "$BUNDLE_FILE"
+echo "..."
 echo ""
 
-# Step 7: LLM Analysis prompt
-echo -e "${BLUE}Step 7: Using the data with LLM:${NC}"
+# Step 5: LLM Analysis prompt
+echo -e "${BLUE}Step 5: Using the bundle with LLM:${NC}"
 echo ""
-echo "Copy the JSON response and use this prompt with your LLM:"
+echo "Paste the contents of $BUNDLE_FILE after this prompt:"
 echo ""
 cat << 'EOF'
 ---
-我有一個 API 的效能問題。以下是從 OpenTelemetry trace 獲取的資訊：
+我有一個 API 的效能問題。以下是從 OpenTelemetry trace 整理出的分析資料，
+包含 critical path、self-time 最長的 spans 及其原始碼：
 
-[貼上 JSON 回應]
+[貼上 bundle 內容]
 
 請分析：
 1. 主要的效能瓶頸在哪裡？
@@ -122,13 +92,13 @@ cat << 'EOF'
 EOF
 echo ""
 
-# Step 8: Additional tips
-echo -e "${BLUE}Step 8: Additional tips:${NC}"
+# Step 6: Additional tips
+echo -e "${BLUE}Step 6: Additional tips:${NC}"
 echo ""
-echo "• To analyze child spans, use their span_id with the same trace_id"
+echo "• Use format=json to feed the bundle to scripts instead of humans"
+echo "• Increase top=N to include more spans in the bundle"
+echo "• Drill into a single span with /api/source-code?span_id=...&trace_id=${TRACE_ID}"
 echo "• Compare multiple traces to identify patterns"
-echo "• Use the duration field to prioritize optimization efforts"
-echo "• Check the attributes for additional context (database queries, external APIs, etc.)"
 echo ""
 
 echo "=========================================="
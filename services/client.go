@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/concurrency"
+	"tempo-otlp-trace-demo/faults"
+	"tempo-otlp-trace-demo/random"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Client calls a downstream service over HTTP
+type Client struct {
+	tracer  trace.Tracer
+	service string
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient returns a client for the service at baseURL whose client spans come from tracer
+func NewClient(tracer trace.Tracer, service, baseURL string) *Client {
+	return &Client{
+		tracer:  tracer,
+		service: service,
+		baseURL: baseURL,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Call sends body as JSON to path and decodes the JSON response into out, under a client span.
+// Besides the trace context, the request carries what the service needs to continue the caller's
+// trace the same way: a seed drawn from the caller's source, the caller's virtual time, its
+// fan-out limit and its X-Inject-Fault header. The caller's virtual clock then moves to the time the service finished.
+func (c *Client) Call(ctx context.Context, method, path string, body, out interface{}) error {
+	if c == nil {
+		return fmt.Errorf("downstream service not started")
+	}
+
+	ctx, span := c.tracer.Start(ctx, method+" "+path,
+		trace.WithSpanKind(trace.SpanKindClient),
+	)
+	defer span.End()
+
+	url := c.baseURL + path
+	span.SetAttributes(
+		attribute.String("http.method", method),
+		attribute.String("http.url", url),
+		attribute.String("peer.service", c.service),
+	)
+
+	err := c.do(ctx, span, method, url, body, out)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.SetStatus(codes.Ok, "call succeeded")
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, span trace.Span, method, url string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	req.Header.Set(random.Header, strconv.FormatInt(random.FromContext(ctx).Int63(), 10))
+	virtual, isVirtual := clock.FromContext(ctx)
+	if isVirtual {
+		req.Header.Set(clock.Header, virtual.Now().Format(time.RFC3339Nano))
+	}
+	if limit, ok := concurrency.FromContext(ctx); ok {
+		req.Header.Set(concurrency.Header, strconv.Itoa(limit))
+	}
+	if value := faults.RequestHeader(ctx); value != "" {
+		req.Header.Set(faults.Header, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s unavailable: %w", c.service, err)
+	}
+	defer resp.Body.Close()
+
+	if isVirtual {
+		if end, err := time.Parse(time.RFC3339Nano, resp.Header.Get(clock.Header)); err == nil {
+			virtual.AdvanceTo(end)
+		}
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s returned %s", c.service, resp.Status)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", c.service, err)
+		}
+	}
+	return nil
+}
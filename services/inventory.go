@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/faults"
+	"tempo-otlp-trace-demo/random"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InventoryRequest asks the inventory service to check the stock of a product
+type InventoryRequest struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// InventoryResponse reports the available stock
+type InventoryResponse struct {
+	ProductID string `json:"product_id"`
+	Available int    `json:"available"`
+}
+
+func inventoryRoutes(tracer trace.Tracer) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /inventory/check", func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "POST /inventory/check",
+			trace.WithSpanKind(trace.SpanKindServer),
+		)
+		defer span.End()
+
+		span.SetAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", "/inventory/check"),
+		)
+
+		var req InventoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(ctx, "Invalid request", "error", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "invalid request")
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		available, err := checkInventory(ctx, tracer, req.ProductID, req.Quantity)
+		if err != nil {
+			slog.ErrorContext(ctx, "Inventory check failed", "error", err)
+			span.SetStatus(codes.Error, err.Error())
+			writeError(ctx, w, err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Inventory checked", "product.id", req.ProductID, "available.quantity", available)
+		span.SetStatus(codes.Ok, "inventory checked")
+		writeJSON(ctx, w, InventoryResponse{ProductID: req.ProductID, Available: available})
+	})
+	return mux
+}
+
+func checkInventory(ctx context.Context, tracer trace.Tracer, productID string, quantity int) (int, error) {
+	ctx, span := tracer.Start(ctx, "checkInventory")
+	defer span.End()
+	if err := faults.Inject(ctx, span, "checkInventory"); err != nil {
+		return 0, err
+	}
+
+	span.SetAttributes(
+		attribute.String("product.id", productID),
+		attribute.Int("requested.quantity", quantity),
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.statement", "SELECT stock FROM inventory WHERE product_id = $1"),
+	)
+
+	rng := random.FromContext(ctx)
+	// Simulate database query
+	clock.Sleep(ctx, time.Duration(100+rng.Intn(100))*time.Millisecond)
+	span.SetAttributes(attribute.Int("available.quantity", 100))
+	slog.DebugContext(ctx, "Inventory available")
+	span.SetStatus(codes.Ok, "inventory available")
+	return 100, nil
+}
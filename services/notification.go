@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/concurrency"
+	"tempo-otlp-trace-demo/faults"
+	"tempo-otlp-trace-demo/random"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NotificationRequest asks the notification service to notify a user by email and SMS
+type NotificationRequest struct {
+	UserID string `json:"user_id"`
+	Type   string `json:"type"`
+}
+
+// NotificationResponse lists the channels the notification went out on
+type NotificationResponse struct {
+	Channels []string `json:"channels"`
+}
+
+func notificationRoutes(tracer trace.Tracer) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /notification/send", func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "POST /notification/send",
+			trace.WithSpanKind(trace.SpanKindServer),
+		)
+		defer span.End()
+
+		span.SetAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", "/notification/send"),
+		)
+
+		var req NotificationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(ctx, "Invalid request", "error", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "invalid request")
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		if err := sendNotification(ctx, tracer, req.UserID, req.Type); err != nil {
+			slog.ErrorContext(ctx, "Notification failed", "error", err)
+			span.SetStatus(codes.Error, err.Error())
+			writeError(ctx, w, err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Notification sent", "user.id", req.UserID, "notification.type", req.Type)
+		span.SetStatus(codes.Ok, "notification sent")
+		writeJSON(ctx, w, NotificationResponse{Channels: []string{"email", "sms"}})
+	})
+	return mux
+}
+
+func sendNotification(ctx context.Context, tracer trace.Tracer, userID, notificationType string) error {
+	ctx, span := tracer.Start(ctx, "sendNotification")
+	defer span.End()
+	if err := faults.Inject(ctx, span, "sendNotification"); err != nil {
+		return err
+	}
+
+	limit := concurrency.Limit(ctx)
+	span.SetAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("notification.type", notificationType),
+		attribute.Int("concurrency.limit", limit),
+	)
+
+	// Nested: Send email and SMS, concurrently when the request allows it
+	var emailErr, smsErr error
+	concurrency.Run(ctx, limit,
+		func(ctx context.Context) { emailErr = sendEmail(ctx, tracer, userID) },
+		func(ctx context.Context) { smsErr = sendSMS(ctx, tracer, userID) },
+	)
+	if err := errors.Join(emailErr, smsErr); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	slog.DebugContext(ctx, "Notifications sent")
+	span.SetStatus(codes.Ok, "notifications sent")
+	return nil
+}
+
+func sendEmail(ctx context.Context, tracer trace.Tracer, userID string) error {
+	ctx, span := tracer.Start(ctx, "sendEmail")
+	defer span.End()
+	if err := faults.Inject(ctx, span, "sendEmail"); err != nil {
+		return err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("email.provider", "sendgrid"),
+	)
+
+	rng := random.FromContext(ctx)
+	// Simulate email sending
+	clock.Sleep(ctx, time.Duration(30+rng.Intn(30))*time.Millisecond)
+	slog.DebugContext(ctx, "Email sent")
+	span.SetStatus(codes.Ok, "email sent")
+	return nil
+}
+
+func sendSMS(ctx context.Context, tracer trace.Tracer, userID string) error {
+	ctx, span := tracer.Start(ctx, "sendSMS")
+	defer span.End()
+	if err := faults.Inject(ctx, span, "sendSMS"); err != nil {
+		return err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("sms.provider", "twilio"),
+	)
+
+	rng := random.FromContext(ctx)
+	// Simulate SMS sending
+	clock.Sleep(ctx, time.Duration(20+rng.Intn(20))*time.Millisecond)
+	slog.DebugContext(ctx, "SMS sent")
+	span.SetStatus(codes.Ok, "sms sent")
+	return nil
+}
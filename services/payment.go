@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"tempo-otlp-trace-demo/anomalies"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/faults"
+	"tempo-otlp-trace-demo/random"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PaymentRequest asks the payment service to charge a user
+type PaymentRequest struct {
+	UserID string  `json:"user_id"`
+	Amount float64 `json:"amount"`
+	Slow   bool    `json:"slow,omitempty"` // Simulate a 5s delay in processPayment
+}
+
+// PaymentResponse reports the charge
+type PaymentResponse struct {
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+}
+
+func paymentRoutes(tracer trace.Tracer) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /payment/charge", func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "POST /payment/charge",
+			trace.WithSpanKind(trace.SpanKindServer),
+		)
+		defer span.End()
+
+		span.SetAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", "/payment/charge"),
+		)
+
+		var req PaymentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.WarnContext(ctx, "Invalid request", "error", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "invalid request")
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		transactionID, err := processPayment(ctx, tracer, req.UserID, req.Amount, req.Slow)
+		if err != nil {
+			slog.ErrorContext(ctx, "Payment failed", "error", err)
+			span.SetStatus(codes.Error, err.Error())
+			writeError(ctx, w, err)
+			return
+		}
+
+		slog.InfoContext(ctx, "Payment charged", "user.id", req.UserID, "payment.transaction_id", transactionID)
+		span.SetStatus(codes.Ok, "payment charged")
+		writeJSON(ctx, w, PaymentResponse{TransactionID: transactionID, Status: "charged"})
+	})
+	return mux
+}
+
+func processPayment(ctx context.Context, tracer trace.Tracer, userID string, amount float64, simulateSlow bool) (string, error) {
+	ctx, span := tracer.Start(ctx, "processPayment")
+	defer span.End()
+	if err := faults.Inject(ctx, span, "processPayment"); err != nil {
+		return "", err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", userID),
+		attribute.Float64("payment.amount", amount),
+		attribute.String("operation.type", "payment"),
+		attribute.Bool("simulate.slow", simulateSlow),
+	)
+
+	// If simulateSlow is true, add 5 seconds delay to simulate anomaly
+	if simulateSlow {
+		span.SetAttributes(attribute.String("slow.reason", "simulated_delay"))
+		anomalies.Record(ctx, span, anomalies.Anomaly{
+			SpanName:  "processPayment",
+			Type:      anomalies.TypeLatency,
+			Source:    "request",
+			Magnitude: 5000,
+			Unit:      anomalies.UnitMilliseconds,
+		})
+		clock.Sleep(ctx, 5*time.Second)
+	}
+
+	// Nested: Call payment gateway
+	transactionID, err := callPaymentGateway(ctx, tracer, amount)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	// Nested: Record transaction
+	if err := recordTransaction(ctx, tracer, userID, amount); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+
+	slog.DebugContext(ctx, "Payment processed")
+	span.SetStatus(codes.Ok, "payment processed")
+	return transactionID, nil
+}
+
+func callPaymentGateway(ctx context.Context, tracer trace.Tracer, amount float64) (string, error) {
+	ctx, span := tracer.Start(ctx, "callPaymentGateway")
+	defer span.End()
+	if err := faults.Inject(ctx, span, "callPaymentGateway"); err != nil {
+		return "", err
+	}
+
+	span.SetAttributes(
+		attribute.String("payment.gateway", "stripe"),
+		attribute.Float64("payment.amount", amount),
+		attribute.String("http.method", "POST"),
+		attribute.String("http.url", "https://api.stripe.com/v1/charges"),
+	)
+
+	rng := random.FromContext(ctx)
+	// Simulate external API call; a timed-out attempt is retried with exponential backoff
+	retries := 0
+	for attempt := 1; attempt <= 3; attempt++ {
+		if attempt == 3 || rng.Float64() >= 0.15 {
+			clock.Sleep(ctx, time.Duration(150+rng.Intn(250))*time.Millisecond)
+			break
+		}
+
+		backoff := time.Duration(50<<(attempt-1)) * time.Millisecond
+		clock.Sleep(ctx, time.Duration(300+rng.Intn(200))*time.Millisecond)
+		slog.WarnContext(ctx, "Payment gateway timed out, retrying", "retry.attempt", attempt, "retry.backoff_ms", backoff.Milliseconds())
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("retry.attempt", attempt),
+			attribute.String("retry.reason", "gateway timeout"),
+			attribute.Int64("retry.backoff_ms", backoff.Milliseconds()),
+		))
+		clock.Sleep(ctx, backoff)
+		retries++
+	}
+	span.SetAttributes(attribute.Int("payment.retries", retries))
+
+	transactionID := fmt.Sprintf("txn_%d", rng.Int())
+	span.SetAttributes(attribute.String("payment.transaction_id", transactionID))
+	slog.DebugContext(ctx, "Payment gateway success", "payment.transaction_id", transactionID, "payment.retries", retries)
+	span.SetStatus(codes.Ok, "payment gateway success")
+	return transactionID, nil
+}
+
+func recordTransaction(ctx context.Context, tracer trace.Tracer, userID string, amount float64) error {
+	ctx, span := tracer.Start(ctx, "recordTransaction")
+	defer span.End()
+	if err := faults.Inject(ctx, span, "recordTransaction"); err != nil {
+		return err
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", userID),
+		attribute.Float64("transaction.amount", amount),
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.statement", "INSERT INTO transactions (user_id, amount) VALUES ($1, $2)"),
+	)
+
+	rng := random.FromContext(ctx)
+	// Simulate database write
+	clock.Sleep(ctx, time.Duration(20+rng.Intn(30))*time.Millisecond)
+	slog.DebugContext(ctx, "Transaction recorded")
+	span.SetStatus(codes.Ok, "transaction recorded")
+	return nil
+}
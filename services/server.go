@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/concurrency"
+	"tempo-otlp-trace-demo/faults"
+	"tempo-otlp-trace-demo/random"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// serverMiddleware continues the caller's request: it extracts the trace context and applies
+// the seed, virtual time, fan-out limit and faults the client forwarded
+func serverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		seed, ok, err := random.SeedFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !ok {
+			seed = random.NewSeed()
+		}
+		ctx = random.WithSeed(ctx, seed)
+
+		start, ok, err := clock.StartFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ok {
+			ctx = clock.WithVirtual(ctx, start)
+		}
+
+		limit, ok, err := concurrency.LimitFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ok {
+			ctx = concurrency.WithLimit(ctx, limit)
+		}
+
+		if value := r.Header.Get(faults.Header); value != "" {
+			ctx, err = faults.WithRequestHeader(ctx, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s header: %v", faults.Header, err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// writeJSON writes the response, returning the virtual time the request finished at
+// so the caller's clock can catch up
+func writeJSON(ctx context.Context, w http.ResponseWriter, response interface{}) {
+	if c, ok := clock.FromContext(ctx); ok {
+		w.Header().Set(clock.Header, c.Now().Format(time.RFC3339Nano))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeError answers with the status faults.StatusCode picks for err, returning the virtual
+// time the request failed at like writeJSON
+func writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	if c, ok := clock.FromContext(ctx); ok {
+		w.Header().Set(clock.Header, c.Now().Format(time.RFC3339Nano))
+	}
+	http.Error(w, err.Error(), faults.StatusCode(err))
+}
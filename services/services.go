@@ -0,0 +1,114 @@
+// Package services runs the downstream services of the order flow — inventory, payment and
+// notification — as separate logical services. Each has its own resource and tracer provider and
+// its own HTTP server, and the order handler calls them over real HTTP with the trace context
+// injected, so a trace spans several services as it would in a distributed system.
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"tempo-otlp-trace-demo/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Service names, used as service.name of each service's resource
+const (
+	InventoryService    = "inventory-service"
+	PaymentService      = "payment-service"
+	NotificationService = "notification-service"
+)
+
+// Clients of the downstream services, set by Start
+var (
+	Inventory    *Client
+	Payment      *Client
+	Notification *Client
+)
+
+// Cluster is the set of running downstream services
+type Cluster struct {
+	servers   []*http.Server
+	providers []*sdktrace.TracerProvider
+}
+
+// service describes a downstream service: its name, the environment variable overriding its
+// listen address, and its routes
+type service struct {
+	name    string
+	addrEnv string
+	routes  func(tracer trace.Tracer) *http.ServeMux
+	client  **Client
+}
+
+var all = []service{
+	{name: InventoryService, addrEnv: "INVENTORY_SERVICE_ADDR", routes: inventoryRoutes, client: &Inventory},
+	{name: PaymentService, addrEnv: "PAYMENT_SERVICE_ADDR", routes: paymentRoutes, client: &Payment},
+	{name: NotificationService, addrEnv: "NOTIFICATION_SERVICE_ADDR", routes: notificationRoutes, client: &Notification},
+}
+
+// Start starts every downstream service on its own listener, 127.0.0.1 on a random port unless
+// <NAME>_SERVICE_ADDR is set, and points the clients at them. The clients' spans come from
+// the global "trace-demo-service" tracer, so InitTracer must run first.
+func Start(ctx context.Context, opts ...tracing.Option) (*Cluster, error) {
+	cluster := &Cluster{}
+	caller := otel.Tracer("trace-demo-service")
+
+	for _, svc := range all {
+		tp, err := tracing.NewTracerProvider(ctx, svc.name, opts...)
+		if err != nil {
+			cluster.Shutdown(ctx)
+			return nil, fmt.Errorf("failed to initialize %s tracer: %w", svc.name, err)
+		}
+		cluster.providers = append(cluster.providers, tp)
+
+		addr := os.Getenv(svc.addrEnv)
+		if addr == "" {
+			addr = "127.0.0.1:0"
+		}
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			cluster.Shutdown(ctx)
+			return nil, fmt.Errorf("failed to start %s: %w", svc.name, err)
+		}
+
+		tracer := tracing.Tracer(tp, svc.name)
+		server := &http.Server{
+			Handler:      serverMiddleware(svc.routes(tracer)),
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+		}
+		cluster.servers = append(cluster.servers, server)
+
+		go func() {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Service stopped", "service", svc.name, "error", err)
+			}
+		}()
+
+		baseURL := "http://" + listener.Addr().String()
+		*svc.client = NewClient(caller, svc.name, baseURL)
+		slog.Info("Service listening", "service", svc.name, "url", baseURL)
+	}
+	return cluster, nil
+}
+
+// Shutdown stops the servers and flushes the spans of every service
+func (c *Cluster) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, server := range c.servers {
+		errs = append(errs, server.Shutdown(ctx))
+	}
+	for _, tp := range c.providers {
+		errs = append(errs, tp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
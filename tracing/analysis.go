@@ -0,0 +1,195 @@
+package tracing
+
+import (
+	"sort"
+)
+
+// SpanNode represents a span within the parent/child tree of a trace
+type SpanNode struct {
+	Span     *TempoSpan
+	Parent   *SpanNode
+	Children []*SpanNode
+	Depth    int
+	SelfTime int64 // Duration minus time covered by children, in microseconds
+}
+
+// EndTime returns the end time of the span in microseconds
+func (n *SpanNode) EndTime() int64 {
+	return n.Span.StartTime + n.Span.Duration
+}
+
+// SpanTree represents a trace rebuilt as a parent/child tree
+type SpanTree struct {
+	Roots []*SpanNode
+	Nodes map[string]*SpanNode
+}
+
+// CriticalPathSegment represents a time range on the critical path attributed to a single span
+type CriticalPathSegment struct {
+	SpanID        string
+	OperationName string
+	StartTime     int64 // microseconds
+	Duration      int64 // microseconds
+}
+
+// BuildSpanTree rebuilds the parent/child tree of a trace from its CHILD_OF references
+func BuildSpanTree(trace *TempoTrace) *SpanTree {
+	tree := &SpanTree{
+		Nodes: make(map[string]*SpanNode, len(trace.Spans)),
+	}
+
+	for i := range trace.Spans {
+		tree.Nodes[trace.Spans[i].SpanID] = &SpanNode{Span: &trace.Spans[i]}
+	}
+
+	// Link children to parents; spans whose parent is missing become roots
+	for i := range trace.Spans {
+		node := tree.Nodes[trace.Spans[i].SpanID]
+		if parent := tree.Nodes[parentSpanID(node.Span)]; parent != nil && parent != node {
+			node.Parent = parent
+			parent.Children = append(parent.Children, node)
+		} else {
+			tree.Roots = append(tree.Roots, node)
+		}
+	}
+
+	sortNodes(tree.Roots)
+	for _, root := range tree.Roots {
+		finalizeNode(root, 0)
+	}
+
+	return tree
+}
+
+// Root returns the longest root span of the tree, or nil for an empty trace
+func (t *SpanTree) Root() *SpanNode {
+	var root *SpanNode
+	for _, node := range t.Roots {
+		if root == nil || node.Span.Duration > root.Span.Duration {
+			root = node
+		}
+	}
+	return root
+}
+
+// Walk visits every node of the tree depth-first in start time order
+func (t *SpanTree) Walk(fn func(node *SpanNode)) {
+	var visit func(node *SpanNode)
+	visit = func(node *SpanNode) {
+		fn(node)
+		for _, child := range node.Children {
+			visit(child)
+		}
+	}
+	for _, root := range t.Roots {
+		visit(root)
+	}
+}
+
+// CriticalPath computes the critical path below the given node.
+// Walking backwards from the end of each span, the last child to finish is the one
+// the span was waiting on; time not covered by such a child is attributed to the span itself.
+// Segments are returned in chronological order.
+func CriticalPath(node *SpanNode) []CriticalPathSegment {
+	if node == nil {
+		return nil
+	}
+
+	var segments []CriticalPathSegment
+	collectCriticalPath(node, node.EndTime(), &segments)
+
+	// Segments are collected backwards in time
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	return segments
+}
+
+func collectCriticalPath(node *SpanNode, end int64, segments *[]CriticalPathSegment) {
+	cursor := end
+	for {
+		// Find the last finishing child that started before the cursor
+		var next *SpanNode
+		for _, child := range node.Children {
+			if child.Span.StartTime >= cursor {
+				continue
+			}
+			if next == nil || child.EndTime() > next.EndTime() {
+				next = child
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		childEnd := next.EndTime()
+		if childEnd > cursor {
+			childEnd = cursor
+		}
+		appendSegment(segments, node, childEnd, cursor)
+		collectCriticalPath(next, childEnd, segments)
+		cursor = next.Span.StartTime
+	}
+
+	appendSegment(segments, node, node.Span.StartTime, cursor)
+}
+
+func appendSegment(segments *[]CriticalPathSegment, node *SpanNode, start, end int64) {
+	if end <= start {
+		return
+	}
+	*segments = append(*segments, CriticalPathSegment{
+		SpanID:        node.Span.SpanID,
+		OperationName: node.Span.OperationName,
+		StartTime:     start,
+		Duration:      end - start,
+	})
+}
+
+// finalizeNode assigns depths, orders children and computes self-times recursively
+func finalizeNode(node *SpanNode, depth int) {
+	node.Depth = depth
+	sortNodes(node.Children)
+	for _, child := range node.Children {
+		finalizeNode(child, depth+1)
+	}
+	node.SelfTime = node.Span.Duration - coveredByChildren(node)
+}
+
+// coveredByChildren returns the union of the children's time ranges clipped to the node's own range
+func coveredByChildren(node *SpanNode) int64 {
+	start, end := node.Span.StartTime, node.EndTime()
+
+	var covered int64
+	cursor := start
+	// Children are sorted by start time
+	for _, child := range node.Children {
+		childStart, childEnd := child.Span.StartTime, child.EndTime()
+		if childStart < cursor {
+			childStart = cursor
+		}
+		if childEnd > end {
+			childEnd = end
+		}
+		if childEnd > childStart {
+			covered += childEnd - childStart
+			cursor = childEnd
+		}
+	}
+	return covered
+}
+
+func sortNodes(nodes []*SpanNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Span.StartTime < nodes[j].Span.StartTime
+	})
+}
+
+func parentSpanID(span *TempoSpan) string {
+	for _, ref := range span.References {
+		if ref.RefType == "CHILD_OF" {
+			return ref.SpanID
+		}
+	}
+	return ""
+}
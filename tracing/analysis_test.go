@@ -0,0 +1,127 @@
+package tracing
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testSpan builds a span named id; parent is referenced as CHILD_OF unless refType says otherwise
+func testSpan(id, parent string, start, duration int64, refType ...string) TempoSpan {
+	span := TempoSpan{SpanID: id, OperationName: id, StartTime: start, Duration: duration}
+	if parent != "" {
+		ref := "CHILD_OF"
+		if len(refType) > 0 {
+			ref = refType[0]
+		}
+		span.References = []TempoReference{{RefType: ref, SpanID: parent}}
+	}
+	return span
+}
+
+func TestCriticalPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		spans    []TempoSpan
+		want     []string // name@start+duration
+		rootSelf int64
+	}{
+		{
+			name: "sequential children",
+			spans: []TempoSpan{
+				testSpan("root", "", 0, 100),
+				testSpan("a", "root", 10, 30),
+				testSpan("b", "root", 50, 40),
+			},
+			want:     []string{"root@0+10", "a@10+30", "root@40+10", "b@50+40", "root@90+10"},
+			rootSelf: 30,
+		},
+		{
+			name: "overlapping children",
+			spans: []TempoSpan{
+				testSpan("root", "", 0, 100),
+				testSpan("a", "root", 10, 50),
+				testSpan("b", "root", 30, 50),
+			},
+			// b finishes last; before b started the root was waiting on a
+			want:     []string{"root@0+10", "a@10+20", "b@30+50", "root@80+20"},
+			rootSelf: 30,
+		},
+		{
+			name: "child outlives parent",
+			spans: []TempoSpan{
+				testSpan("root", "", 0, 100),
+				testSpan("a", "root", 50, 100),
+			},
+			want:     []string{"root@0+50", "a@50+50"},
+			rootSelf: 50,
+		},
+		{
+			name: "nested",
+			spans: []TempoSpan{
+				testSpan("root", "", 0, 100),
+				testSpan("a", "root", 0, 80),
+				testSpan("a1", "a", 20, 20),
+				testSpan("a2", "a", 30, 40),
+			},
+			// a1 overlaps a2, so it is on the path only until a2 starts
+			want:     []string{"a@0+20", "a1@20+10", "a2@30+40", "a@70+10", "root@80+20"},
+			rootSelf: 20,
+		},
+		{
+			name: "follows from is not waited on",
+			spans: []TempoSpan{
+				testSpan("root", "", 0, 100),
+				testSpan("a", "root", 10, 20),
+				testSpan("async", "root", 40, 30, "FOLLOWS_FROM"),
+			},
+			want:     []string{"root@0+10", "a@10+20", "root@30+70"},
+			rootSelf: 80,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := BuildSpanTree(&TempoTrace{Spans: tt.spans})
+			root := tree.Nodes["root"]
+
+			var got []string
+			for _, segment := range CriticalPath(root) {
+				got = append(got, fmt.Sprintf("%s@%d+%d", segment.OperationName, segment.StartTime, segment.Duration))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CriticalPath = %v, want %v", got, tt.want)
+			}
+			if root.SelfTime != tt.rootSelf {
+				t.Errorf("root SelfTime = %d, want %d", root.SelfTime, tt.rootSelf)
+			}
+		})
+	}
+}
+
+func TestSpanTreeRoots(t *testing.T) {
+	tree := BuildSpanTree(&TempoTrace{Spans: []TempoSpan{
+		testSpan("consumer", "producer", 200, 300, "FOLLOWS_FROM"),
+		testSpan("producer", "", 0, 100),
+		testSpan("orphan", "missing", 50, 10),
+		testSpan("child", "producer", 10, 20),
+	}})
+
+	var roots []string
+	for _, root := range tree.Roots {
+		roots = append(roots, root.Span.SpanID)
+	}
+	// Roots are in start time order; FOLLOWS_FROM and missing parents start their own trees
+	if want := []string{"producer", "orphan", "consumer"}; !reflect.DeepEqual(roots, want) {
+		t.Errorf("Roots = %v, want %v", roots, want)
+	}
+	if root := tree.Root(); root == nil || root.Span.SpanID != "consumer" {
+		t.Errorf("Root() = %v, want the longest root, consumer", root)
+	}
+	if child := tree.Nodes["child"]; child.Parent != tree.Nodes["producer"] || child.Depth != 1 {
+		t.Errorf("child parent = %v, depth %d", child.Parent, child.Depth)
+	}
+	if got := (&SpanTree{}).Root(); got != nil {
+		t.Errorf("Root() of an empty tree = %v, want nil", got)
+	}
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BaggageAttributesEnvVar lists the baggage members copied onto every span as attributes, comma
+// separated: their keys (default: tenant,user.tier), none, or * for every member a caller sends
+const BaggageAttributesEnvVar = "DEMO_BAGGAGE_ATTRIBUTES"
+
+// defaultBaggageAttributes are the members copied when DEMO_BAGGAGE_ATTRIBUTES is unset.
+// Baggage comes from the caller, so only an allowlist is copied by default.
+const defaultBaggageAttributes = "tenant,user.tier"
+
+// baggageAttributePrefix namespaces the copied members, so a caller cannot set the attributes
+// the handlers set themselves
+const baggageAttributePrefix = "baggage."
+
+// baggageSpanProcessor copies the baggage of a span's context, such as a tenant or user tier set
+// by an upstream gateway, onto the span as baggage.<key>, so traces can be searched by it in Tempo
+type baggageSpanProcessor struct {
+	keys map[string]bool // nil copies every member
+}
+
+// newBaggageSpanProcessor returns the processor of DEMO_BAGGAGE_ATTRIBUTES, or nil when it copies nothing
+func newBaggageSpanProcessor() *baggageSpanProcessor {
+	p := &baggageSpanProcessor{}
+	for _, key := range strings.Split(getEnv(BaggageAttributesEnvVar, defaultBaggageAttributes), ",") {
+		switch key = strings.TrimSpace(key); key {
+		case "*":
+			return &baggageSpanProcessor{}
+		case "none", "":
+		default:
+			if p.keys == nil {
+				p.keys = make(map[string]bool)
+			}
+			p.keys[key] = true
+		}
+	}
+	if p.keys == nil {
+		return nil
+	}
+	return p
+}
+
+func (p *baggageSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	members := baggage.FromContext(parent).Members()
+	if len(members) == 0 {
+		return
+	}
+
+	// Attributes the span was started with take precedence over baggage of the same name
+	set := make(map[attribute.Key]bool)
+	for _, kv := range s.Attributes() {
+		set[kv.Key] = true
+	}
+
+	var attrs []attribute.KeyValue
+	for _, member := range members {
+		key := attribute.Key(baggageAttributePrefix + member.Key())
+		if (p.keys != nil && !p.keys[member.Key()]) || set[key] {
+			continue
+		}
+		attrs = append(attrs, key.String(member.Value()))
+	}
+	s.SetAttributes(attrs...)
+}
+
+func (p *baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (p *baggageSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (p *baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
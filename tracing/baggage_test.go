@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// baggageAttributes starts a span under baggage with the processor of DEMO_BAGGAGE_ATTRIBUTES=value
+// and returns the attributes it ended with
+func baggageAttributes(t *testing.T, value string, startAttrs ...attribute.KeyValue) map[attribute.Key]string {
+	t.Helper()
+	t.Setenv(BaggageAttributesEnvVar, value)
+
+	recorder := tracetest.NewSpanRecorder()
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithSpanProcessor(recorder)}
+	if p := newBaggageSpanProcessor(); p != nil {
+		opts = append(opts, sdktrace.WithSpanProcessor(p))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	defer provider.Shutdown(context.Background())
+
+	bag, err := baggage.Parse("tenant=acme,user.tier=gold,http.route=/spoofed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+	_, span := provider.Tracer("test").Start(ctx, "span", trace.WithAttributes(startAttrs...))
+	span.End()
+
+	attrs := make(map[attribute.Key]string)
+	for _, kv := range recorder.Ended()[0].Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	return attrs
+}
+
+func TestBaggageSpanProcessor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[attribute.Key]string
+	}{
+		{value: "", want: map[attribute.Key]string{"baggage.tenant": "acme", "baggage.user.tier": "gold"}},
+		{value: "tenant", want: map[attribute.Key]string{"baggage.tenant": "acme"}},
+		{value: "none", want: map[attribute.Key]string{}},
+		{value: "*", want: map[attribute.Key]string{
+			"baggage.tenant": "acme", "baggage.user.tier": "gold", "baggage.http.route": "/spoofed"}},
+	}
+
+	for _, tt := range tests {
+		got := baggageAttributes(t, tt.value)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: attributes = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for key, value := range tt.want {
+			if got[key] != value {
+				t.Errorf("%q: %s = %q, want %q", tt.value, key, got[key], value)
+			}
+		}
+	}
+}
+
+func TestBaggageDoesNotOverrideSpanAttributes(t *testing.T) {
+	got := baggageAttributes(t, "*", attribute.String("baggage.tenant", "initech"), attribute.String("http.route", "/api/order/create"))
+	if got["baggage.tenant"] != "initech" {
+		t.Errorf("baggage.tenant = %q, want the span's own value", got["baggage.tenant"])
+	}
+	if got["http.route"] != "/api/order/create" {
+		t.Errorf("http.route = %q, baggage must not replace it", got["http.route"])
+	}
+}
@@ -0,0 +1,281 @@
+package tracing
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPollInterval caps the exponential backoff between polls for a trace
+const maxPollInterval = 5 * time.Second
+
+// defaultPollTimeout is how long a trace that Tempo is still ingesting is polled for by default
+const defaultPollTimeout = 5 * time.Second
+
+// TempoClient is a client for Tempo's query API.
+// It supports multi-tenant Tempo (X-Scope-OrgID), basic or bearer auth for gateways,
+// TLS options and polling with exponential backoff while a trace is still being ingested.
+type TempoClient struct {
+	BaseURL      string
+	TenantID     string // Sent as X-Scope-OrgID
+	Username     string // Basic auth, used when BearerToken is empty
+	Password     string
+	BearerToken  string
+	QueryFormat  string        // "protobuf" (default) or "json"
+	PollTimeout  time.Duration // How long to keep polling while Tempo returns 404; zero disables polling
+	PollInterval time.Duration // Initial backoff between polls, doubled after each attempt
+	HTTPClient   *http.Client
+}
+
+// StatusError is returned when Tempo answers with a non-200 status
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Tempo returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsTraceNotFound reports whether err means Tempo does not (yet) have the trace
+func IsTraceNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+var (
+	defaultClient     *TempoClient
+	defaultClientErr  error
+	defaultClientOnce sync.Once
+)
+
+// DefaultTempoClient returns the client configured from the environment, created on first use
+func DefaultTempoClient() (*TempoClient, error) {
+	defaultClientOnce.Do(func() {
+		defaultClient, defaultClientErr = NewTempoClientFromEnv()
+	})
+	return defaultClient, defaultClientErr
+}
+
+// NewTempoClientFromEnv creates a Tempo client configured from TEMPO_* environment variables
+func NewTempoClientFromEnv() (*TempoClient, error) {
+	timeout, err := getDurationEnv("TEMPO_TIMEOUT", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	pollTimeout, err := getDurationEnv("TEMPO_POLL_TIMEOUT", defaultPollTimeout)
+	if err != nil {
+		return nil, err
+	}
+	pollInterval, err := getDurationEnv("TEMPO_POLL_INTERVAL", 250*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := tlsConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &TempoClient{
+		BaseURL:      strings.TrimRight(GetTempoURL(), "/"),
+		TenantID:     os.Getenv("TEMPO_TENANT_ID"),
+		Username:     os.Getenv("TEMPO_USERNAME"),
+		Password:     os.Getenv("TEMPO_PASSWORD"),
+		BearerToken:  os.Getenv("TEMPO_BEARER_TOKEN"),
+		QueryFormat:  strings.ToLower(os.Getenv("TEMPO_QUERY_FORMAT")),
+		PollTimeout:  pollTimeout,
+		PollInterval: pollInterval,
+		HTTPClient: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		},
+	}, nil
+}
+
+// tlsConfigFromEnv builds the TLS configuration from TEMPO_TLS_* environment variables
+func tlsConfigFromEnv() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: os.Getenv("TEMPO_TLS_INSECURE_SKIP_VERIFY") == "true",
+	}
+
+	if caFile := os.Getenv("TEMPO_TLS_CA_FILE"); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TEMPO_TLS_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in TEMPO_TLS_CA_FILE %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	certFile, keyFile := os.Getenv("TEMPO_TLS_CERT_FILE"), os.Getenv("TEMPO_TLS_KEY_FILE")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Tempo client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// QueryTrace fetches a trace by ID. While Tempo returns 404 it polls with
+// exponential backoff until the trace appears, PollTimeout elapses or ctx is done.
+func (c *TempoClient) QueryTrace(ctx context.Context, traceID string) (*TempoTrace, error) {
+	if c.PollTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.PollTimeout)
+		defer cancel()
+	}
+
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = 250 * time.Millisecond
+	}
+
+	for {
+		trace, err := c.queryTraceOnce(ctx, traceID)
+		if err == nil || c.PollTimeout <= 0 || !IsTraceNotFound(err) {
+			return trace, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > maxPollInterval {
+			interval = maxPollInterval
+		}
+	}
+}
+
+// queryTraceOnce fetches a trace, requesting protobuf first since it is much cheaper
+// to decode for large traces, and falling back to JSON (OTLP or Jaeger)
+func (c *TempoClient) queryTraceOnce(ctx context.Context, traceID string) (*TempoTrace, error) {
+	path := "/api/traces/" + url.PathEscape(traceID)
+
+	accept := protobufContentType + ", application/json;q=0.9"
+	if c.QueryFormat == "json" {
+		accept = "application/json"
+	}
+
+	body, contentType, err := c.get(ctx, path, nil, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if isProtobufContentType(contentType) {
+		otlpTrace, err := decodeOTLPProtobuf(body)
+		if err == nil {
+			return convertOTLPToJaeger(otlpTrace, traceID)
+		}
+
+		// Fall back to JSON if the protobuf payload could not be decoded
+		body, _, err = c.get(ctx, path, nil, "application/json")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parseTraceJSON(body, traceID)
+}
+
+// Search searches Tempo for traces using TraceQL, tag filters, durations and a time range
+func (c *TempoClient) Search(ctx context.Context, params SearchParams) (*SearchResponse, error) {
+	body, _, err := c.get(ctx, "/api/search", params.Values(), "application/json")
+	if err != nil {
+		return nil, err
+	}
+
+	var result SearchResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Tempo search response: %w", err)
+	}
+	if result.Traces == nil {
+		result.Traces = make([]TraceSearchResult, 0)
+	}
+
+	return &result, nil
+}
+
+// get performs an authenticated GET against Tempo and returns the body together with its Content-Type
+func (c *TempoClient) get(ctx context.Context, path string, query url.Values, accept string) ([]byte, string, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", accept)
+	if c.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", c.TenantID)
+	}
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	} else if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query Tempo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, "", &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// getDurationEnv reads a duration such as "10s" or a number of seconds from the environment
+func getDurationEnv(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return d, nil
+}
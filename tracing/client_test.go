@@ -0,0 +1,224 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testTraceID = "2f3e0cee77ae5dc9c17ade3689eb2e54"
+
+// jaegerTraceJSON is a minimal Jaeger format trace, the JSON fallback accepted by parseTraceJSON
+const jaegerTraceJSON = `{"traceID":"` + testTraceID + `","spans":[{"traceID":"` + testTraceID +
+	`","spanID":"00f067aa0ba902b7","operationName":"POST /api/order/create","startTime":1,"duration":2}]}`
+
+// newTempoServer starts a stand-in for Tempo that answers 404 for the first notFound
+// trace queries and the trace afterwards, and counts the queries it received
+func newTempoServer(t *testing.T, notFound int32, queries *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/traces/"+testTraceID {
+			http.NotFound(w, r)
+			return
+		}
+		if atomic.AddInt32(queries, 1) <= notFound {
+			http.Error(w, "trace not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(jaegerTraceJSON))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestQueryTraceRetriesWithBackoff(t *testing.T) {
+	var queries int32
+	server := newTempoServer(t, 2, &queries)
+
+	client := &TempoClient{
+		BaseURL:      server.URL,
+		PollTimeout:  5 * time.Second,
+		PollInterval: 10 * time.Millisecond,
+	}
+
+	start := time.Now()
+	trace, err := client.QueryTrace(context.Background(), testTraceID)
+	if err != nil {
+		t.Fatalf("QueryTrace: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&queries); got != 3 {
+		t.Errorf("queries = %d, want 3", got)
+	}
+	// Two polls with a doubling backoff wait at least 10ms + 20ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("elapsed = %v, want at least 30ms of backoff", elapsed)
+	}
+	if len(trace.Spans) != 1 || trace.Spans[0].SpanID != "00f067aa0ba902b7" {
+		t.Errorf("Spans = %+v", trace.Spans)
+	}
+}
+
+func TestQueryTraceNotFoundWithoutPolling(t *testing.T) {
+	var queries int32
+	server := newTempoServer(t, 1, &queries)
+
+	client := &TempoClient{BaseURL: server.URL}
+	_, err := client.QueryTrace(context.Background(), testTraceID)
+
+	if !IsTraceNotFound(err) {
+		t.Fatalf("err = %v, want trace not found", err)
+	}
+	if got := atomic.LoadInt32(&queries); got != 1 {
+		t.Errorf("queries = %d, want 1", got)
+	}
+}
+
+func TestQueryTracePollTimeout(t *testing.T) {
+	var queries int32
+	server := newTempoServer(t, 1<<30, &queries)
+
+	client := &TempoClient{
+		BaseURL:      server.URL,
+		PollTimeout:  100 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := client.QueryTrace(context.Background(), testTraceID)
+
+	if !IsTraceNotFound(err) {
+		t.Fatalf("err = %v, want trace not found", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("polling ran for %v, want it to stop after the 100ms poll timeout", elapsed)
+	}
+	if got := atomic.LoadInt32(&queries); got < 2 {
+		t.Errorf("queries = %d, want at least 2", got)
+	}
+}
+
+func TestQueryTraceStopsWhenContextDone(t *testing.T) {
+	var queries int32
+	server := newTempoServer(t, 1<<30, &queries)
+
+	client := &TempoClient{
+		BaseURL:      server.URL,
+		PollTimeout:  time.Minute,
+		PollInterval: 10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := client.QueryTrace(ctx, testTraceID); err == nil {
+		t.Fatal("expected error after the caller's context is done")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("polling ran for %v after the caller's context was done", elapsed)
+	}
+}
+
+func TestQueryTraceHTTPTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &TempoClient{
+		BaseURL:    server.URL,
+		HTTPClient: &http.Client{Timeout: 50 * time.Millisecond},
+	}
+
+	start := time.Now()
+	_, err := client.QueryTrace(context.Background(), testTraceID)
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if IsTraceNotFound(err) {
+		t.Errorf("err = %v, a timeout must not be reported as not found", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("query took %v, want it to time out after 50ms", elapsed)
+	}
+}
+
+func TestQueryTraceSendsTenantAndAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		client TempoClient
+		check  func(t *testing.T, r *http.Request)
+	}{
+		{
+			name:   "bearer",
+			client: TempoClient{TenantID: "team-a", BearerToken: "secret", QueryFormat: "json"},
+			check: func(t *testing.T, r *http.Request) {
+				if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+					t.Errorf("Authorization = %q", got)
+				}
+				if got := r.Header.Get("Accept"); got != "application/json" {
+					t.Errorf("Accept = %q, want application/json", got)
+				}
+			},
+		},
+		{
+			name:   "basic",
+			client: TempoClient{TenantID: "team-a", Username: "user", Password: "pass"},
+			check: func(t *testing.T, r *http.Request) {
+				if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pass" {
+					t.Errorf("BasicAuth = %q, %q, %v", username, password, ok)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("X-Scope-OrgID"); got != "team-a" {
+					t.Errorf("X-Scope-OrgID = %q, want team-a", got)
+				}
+				tt.check(t, r)
+				w.Write([]byte(jaegerTraceJSON))
+			}))
+			defer server.Close()
+
+			client := tt.client
+			client.BaseURL = server.URL
+			if _, err := client.QueryTrace(context.Background(), testTraceID); err != nil {
+				t.Fatalf("QueryTrace: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetDurationEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: time.Minute},
+		{value: "1.5", want: 1500 * time.Millisecond},
+		{value: "250ms", want: 250 * time.Millisecond},
+		{value: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("TEMPO_TEST_DURATION", tt.value)
+		got, err := getDurationEnv("TEMPO_TEST_DURATION", time.Minute)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("getDurationEnv(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
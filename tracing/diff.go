@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"fmt"
+)
+
+// SpanPair represents a span of the base trace aligned with a span of the target trace
+type SpanPair struct {
+	Path   string // Tree position, e.g. "POST /api/order/create/processPayment[0]"
+	Base   *SpanNode
+	Target *SpanNode
+}
+
+// UnmatchedSpan represents a span present in only one of two compared traces
+type UnmatchedSpan struct {
+	Path string
+	Node *SpanNode
+}
+
+// TreeDiff represents the alignment of two span trees
+type TreeDiff struct {
+	Pairs   []SpanPair
+	Added   []UnmatchedSpan // Only in the target trace
+	Missing []UnmatchedSpan // Only in the base trace
+}
+
+// DiffSpanTrees aligns two span trees by operation name and tree position.
+// Roots with the same name are paired with each other; below them the n-th child named X of a base span
+// is paired with the n-th child named X of the aligned target span, in start time order.
+// Subtrees without a counterpart are reported as added or missing, as are both
+// trees entirely when their roots have different names.
+func DiffSpanTrees(base, target *SpanNode) TreeDiff {
+	diff := TreeDiff{
+		Pairs:   make([]SpanPair, 0),
+		Added:   make([]UnmatchedSpan, 0),
+		Missing: make([]UnmatchedSpan, 0),
+	}
+	if base == nil || target == nil || base.Span.OperationName != target.Span.OperationName {
+		if base != nil {
+			collectUnmatched(base, base.Span.OperationName, &diff.Missing)
+		}
+		if target != nil {
+			collectUnmatched(target, target.Span.OperationName, &diff.Added)
+		}
+		return diff
+	}
+
+	alignNodes(base, target, base.Span.OperationName, &diff)
+	return diff
+}
+
+func alignNodes(base, target *SpanNode, path string, diff *TreeDiff) {
+	diff.Pairs = append(diff.Pairs, SpanPair{Path: path, Base: base, Target: target})
+
+	baseChildren := childrenByName(base)
+	targetChildren := childrenByName(target)
+
+	// Walk base children in start time order so the output follows the trace
+	seen := make(map[string]int)
+	for _, child := range base.Children {
+		name := child.Span.OperationName
+		index := seen[name]
+		seen[name]++
+
+		childPath := fmt.Sprintf("%s/%s[%d]", path, name, index)
+		if index < len(targetChildren[name]) {
+			alignNodes(child, targetChildren[name][index], childPath, diff)
+		} else {
+			collectUnmatched(child, childPath, &diff.Missing)
+		}
+	}
+
+	seen = make(map[string]int)
+	for _, child := range target.Children {
+		name := child.Span.OperationName
+		index := seen[name]
+		seen[name]++
+
+		if index >= len(baseChildren[name]) {
+			collectUnmatched(child, fmt.Sprintf("%s/%s[%d]", path, name, index), &diff.Added)
+		}
+	}
+}
+
+func childrenByName(node *SpanNode) map[string][]*SpanNode {
+	children := make(map[string][]*SpanNode)
+	for _, child := range node.Children {
+		children[child.Span.OperationName] = append(children[child.Span.OperationName], child)
+	}
+	return children
+}
+
+func collectUnmatched(node *SpanNode, path string, out *[]UnmatchedSpan) {
+	*out = append(*out, UnmatchedSpan{Path: path, Node: node})
+
+	seen := make(map[string]int)
+	for _, child := range node.Children {
+		name := child.Span.OperationName
+		collectUnmatched(child, fmt.Sprintf("%s/%s[%d]", path, name, seen[name]), out)
+		seen[name]++
+	}
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import "testing"
+
+// newTestNode builds a span node with the given children
+func newTestNode(name string, children ...*SpanNode) *SpanNode {
+	node := &SpanNode{Span: &TempoSpan{SpanID: name, OperationName: name}, Children: children}
+	for _, child := range children {
+		child.Parent = node
+	}
+	return node
+}
+
+func TestDiffSpanTrees(t *testing.T) {
+	base := newTestNode("POST /api/order/create",
+		newTestNode("validateOrder"),
+		newTestNode("processItem"),
+		newTestNode("processItem"),
+	)
+	target := newTestNode("POST /api/order/create",
+		newTestNode("processItem"),
+		newTestNode("processPayment"),
+	)
+
+	diff := DiffSpanTrees(base, target)
+
+	wantPairs := []string{"POST /api/order/create", "POST /api/order/create/processItem[0]"}
+	if len(diff.Pairs) != len(wantPairs) {
+		t.Fatalf("Pairs = %+v, want %v", diff.Pairs, wantPairs)
+	}
+	for i, path := range wantPairs {
+		if diff.Pairs[i].Path != path {
+			t.Errorf("Pairs[%d].Path = %q, want %q", i, diff.Pairs[i].Path, path)
+		}
+	}
+	if len(diff.Missing) != 2 || diff.Missing[1].Path != "POST /api/order/create/processItem[1]" {
+		t.Errorf("Missing = %+v", diff.Missing)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Path != "POST /api/order/create/processPayment[0]" {
+		t.Errorf("Added = %+v", diff.Added)
+	}
+}
+
+func TestDiffSpanTreesDifferentRoots(t *testing.T) {
+	base := newTestNode("POST /api/order/create", newTestNode("processPayment"))
+	target := newTestNode("GET /api/report/generate", newTestNode("queryDatabase"))
+
+	diff := DiffSpanTrees(base, target)
+
+	if len(diff.Pairs) != 0 {
+		t.Errorf("Pairs = %+v, want none for different roots", diff.Pairs)
+	}
+	if len(diff.Missing) != 2 || len(diff.Added) != 2 {
+		t.Errorf("Missing = %+v, Added = %+v, want both trees unmatched", diff.Missing, diff.Added)
+	}
+}
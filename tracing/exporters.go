@@ -0,0 +1,321 @@
+package tracing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// TracesExporterEnvVar lists the exporters every span is sent to, comma separated:
+// otlp (default), console (JSON lines on stderr, as stdout carries the JSON logs), file (JSON lines appended to DEMO_TRACES_FILE) or none
+const TracesExporterEnvVar = "OTEL_TRACES_EXPORTER"
+
+// TracesFileEnvVar is the file the file exporter appends to
+const TracesFileEnvVar = "DEMO_TRACES_FILE"
+
+// newSpanExporter creates the exporters of OTEL_TRACES_EXPORTER, fanning out to all of them;
+// it returns nil when spans are not exported at all
+func newSpanExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	var exporters multiSpanExporter
+	for _, name := range strings.Split(getEnv(TracesExporterEnvVar, "otlp"), ",") {
+		var exporter sdktrace.SpanExporter
+		var err error
+
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "otlp":
+			exporter, err = newOTLPSpanExporter(ctx)
+		case "console":
+			exporter, err = otlptrace.New(ctx, &jsonlClient{path: "-"})
+		case "file":
+			exporter, err = otlptrace.New(ctx, &jsonlClient{path: getEnv(TracesFileEnvVar, "traces.jsonl")})
+		case "none", "":
+			continue
+		default:
+			err = fmt.Errorf("unsupported %s %q: expected otlp, console, file or none", TracesExporterEnvVar, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+
+	switch len(exporters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return exporters[0], nil
+	default:
+		return exporters, nil
+	}
+}
+
+// newOTLPSpanExporter creates the OTLP exporter configured by the OTEL_EXPORTER_OTLP_* variables
+func newOTLPSpanExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	cfg, err := OTLPConfigFromEnv(SignalTraces)
+	if err != nil {
+		return nil, err
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Protocol {
+	case ProtocolGRPC:
+		opts := OTLPGRPCOptions[otlptracegrpc.Option]{
+			WithEndpoint:       otlptracegrpc.WithEndpoint,
+			WithHeaders:        otlptracegrpc.WithHeaders,
+			WithTimeout:        otlptracegrpc.WithTimeout,
+			WithInsecure:       otlptracegrpc.WithInsecure,
+			WithTLSCredentials: otlptracegrpc.WithTLSCredentials,
+			WithCompressor:     otlptracegrpc.WithCompressor,
+		}.Options(cfg)
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	case ProtocolHTTPProtobuf:
+		opts := OTLPHTTPOptions[otlptracehttp.Option]{
+			WithEndpointURL:     otlptracehttp.WithEndpointURL,
+			WithHeaders:         otlptracehttp.WithHeaders,
+			WithTimeout:         otlptracehttp.WithTimeout,
+			WithTLSClientConfig: otlptracehttp.WithTLSClientConfig,
+			Gzip:                otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
+		}.Options(cfg)
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ProtocolHTTPJSON:
+		exporter, err = otlptrace.New(ctx, newJSONClient(cfg))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// jsonClient sends traces over OTLP/HTTP encoded as JSON, which the SDK's exporters do not support
+type jsonClient struct {
+	cfg    OTLPConfig
+	client *http.Client
+}
+
+func newJSONClient(cfg OTLPConfig) *jsonClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg.TLS
+	return &jsonClient{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout, Transport: transport}}
+}
+
+func (c *jsonClient) Start(context.Context) error { return nil }
+
+func (c *jsonClient) Stop(context.Context) error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *jsonClient) UploadTraces(ctx context.Context, spans []*tracepb.ResourceSpans) error {
+	body, err := marshalOTLPJSON(spans)
+	if err != nil {
+		return err
+	}
+
+	var encoding string
+	if c.cfg.Compression == "gzip" {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body, encoding = buf.Bytes(), "gzip"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	for key, value := range c.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export traces: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("OTLP endpoint %s returned status %d: %s", c.cfg.URL, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// jsonlClient appends each batch of spans as one line of OTLP JSON to a file, or to stderr for "-",
+// in the format of the Collector's file exporter
+type jsonlClient struct {
+	path string
+	file *jsonlFile
+}
+
+func (c *jsonlClient) Start(context.Context) error {
+	file, err := openJSONLFile(c.path)
+	if err != nil {
+		return err
+	}
+	c.file = file
+	return nil
+}
+
+func (c *jsonlClient) Stop(context.Context) error {
+	return c.file.release()
+}
+
+func (c *jsonlClient) UploadTraces(_ context.Context, spans []*tracepb.ResourceSpans) error {
+	line, err := marshalOTLPJSON(spans)
+	if err != nil {
+		return err
+	}
+	return c.file.writeLine(line)
+}
+
+// jsonlFile is a file shared by the JSONL exporters of all tracer providers, so that lines
+// from different services never interleave
+type jsonlFile struct {
+	path string
+	w    io.Writer
+	refs int
+	mu   sync.Mutex
+}
+
+var (
+	jsonlFilesMu sync.Mutex
+	jsonlFiles   = make(map[string]*jsonlFile)
+)
+
+// openJSONLFile opens path for appending, or returns the already open file
+func openJSONLFile(path string) (*jsonlFile, error) {
+	jsonlFilesMu.Lock()
+	defer jsonlFilesMu.Unlock()
+
+	if f, ok := jsonlFiles[path]; ok {
+		f.refs++
+		return f, nil
+	}
+
+	var w io.Writer = os.Stderr
+	if path != "-" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open traces file: %w", err)
+		}
+		w = file
+	}
+
+	f := &jsonlFile{path: path, w: w, refs: 1}
+	jsonlFiles[path] = f
+	return f, nil
+}
+
+func (f *jsonlFile) writeLine(line []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err := f.w.Write(append(line, '\n'))
+	return err
+}
+
+// release closes the file once its last exporter is done with it
+func (f *jsonlFile) release() error {
+	jsonlFilesMu.Lock()
+	defer jsonlFilesMu.Unlock()
+
+	f.refs--
+	if f.refs > 0 {
+		return nil
+	}
+	delete(jsonlFiles, f.path)
+	if closer, ok := f.w.(io.Closer); ok && f.path != "-" {
+		return closer.Close()
+	}
+	return nil
+}
+
+// marshalOTLPJSON encodes spans as an OTLP/JSON export request. OTLP/JSON differs from the
+// protobuf JSON mapping in that trace and span IDs are hex rather than base64 encoded.
+func marshalOTLPJSON(spans []*tracepb.ResourceSpans) ([]byte, error) {
+	data, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: spans})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	var request any
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("failed to encode spans: %w", err)
+	}
+	if err := hexEncodeIDs(request); err != nil {
+		return nil, err
+	}
+	return json.Marshal(request)
+}
+
+// hexEncodeIDs rewrites the base64 traceId, spanId and parentSpanId fields of spans and links to hex
+func hexEncodeIDs(v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if s, ok := value.(string); ok && (key == "traceId" || key == "spanId" || key == "parentSpanId") {
+				id, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("failed to encode %s %q: %w", key, s, err)
+				}
+				v[key] = hex.EncodeToString(id)
+				continue
+			}
+			if err := hexEncodeIDs(value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, value := range v {
+			if err := hexEncodeIDs(value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// multiSpanExporter sends every batch of spans to all of its exporters
+type multiSpanExporter []sdktrace.SpanExporter
+
+func (m multiSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	var errs []error
+	for _, exporter := range m {
+		errs = append(errs, exporter.ExportSpans(ctx, spans))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiSpanExporter) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, exporter := range m {
+		errs = append(errs, exporter.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -2,63 +2,129 @@ package tracing
 
 import (
 	"context"
-	"fmt"
-	"log"
-	"math/rand"
+	"log/slog"
 	"os"
+	"tempo-otlp-trace-demo/clock"
+	"tempo-otlp-trace-demo/random"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// Option configures InitTracer and NewTracerProvider
+type Option func(*options)
+
+type options struct {
+	blockingExport bool
+}
+
+// WithBlockingExport makes span export wait for room in the export queue instead of dropping spans
+// when it is full, for bulk generation such as backfills
+func WithBlockingExport() Option {
+	return func(o *options) {
+		o.blockingExport = true
+	}
+}
+
 // InitTracer initializes the OpenTelemetry tracer provider
-func InitTracer(ctx context.Context) (*sdktrace.TracerProvider, error) {
-	endpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4317")
-	serviceName := getEnv("OTEL_SERVICE_NAME", "trace-demo-service")
+func InitTracer(ctx context.Context, opts ...Option) (*sdktrace.TracerProvider, error) {
+	serviceName := ServiceName()
+	tp, err := NewTracerProvider(ctx, serviceName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	propagator, err := newPropagatorFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// Set global tracer provider and propagator; spans of requests on a virtual clock get its timestamps
+	otel.SetTracerProvider(virtualTimeProvider{tp})
+	otel.SetTextMapPropagator(propagator)
+
+	slog.InfoContext(ctx, "Tracer initialized", "service", serviceName)
+	return tp, nil
+}
+
+// NewTracerProvider creates a tracer provider with its own resource for serviceName, exporting to
+// the exporters of OTEL_TRACES_EXPORTER. It is not registered globally; InitTracer does that for the main service.
+func NewTracerProvider(ctx context.Context, serviceName string, opts ...Option) (*sdktrace.TracerProvider, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	disabled, err := SDKDisabled()
+	if err != nil {
+		return nil, err
+	}
+	if disabled {
+		slog.InfoContext(ctx, "OpenTelemetry SDK disabled, spans are not recorded", "service", serviceName)
+		return sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())), nil
+	}
+
+	slog.InfoContext(ctx, "Initializing tracer", "exporters", getEnv(TracesExporterEnvVar, "otlp"), "service", serviceName)
 
-	log.Printf("Initializing tracer with endpoint: %s, service: %s", endpoint, serviceName)
+	batcherOpts, err := batchOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := newSpanExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := NewResource(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
 
-	// Create OTLP gRPC exporter
-	exporter, err := otlptracegrpc.New(ctx,
-		otlptracegrpc.WithEndpoint(endpoint),
-		otlptracegrpc.WithInsecure(),
-	)
+	metrics, err := newMetricsSpanProcessor(serviceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		return nil, err
 	}
 
-	// Create resource with service information
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("1.0.0"),
-			attribute.String("environment", "demo"),
-		),
-	)
+	sampler, err := newSamplerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	tail, err := tailSamplerFromEnv()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource: %w", err)
+		return nil, err
 	}
 
-	// Create tracer provider with 100% sampling for demo
-	tp := sdktrace.NewTracerProvider(
+	// Sample every trace unless OTEL_TRACES_SAMPLER, DEMO_SAMPLING_RULES or DEMO_TAIL_SAMPLING say otherwise
+	tpOpts := []sdktrace.TracerProviderOption{
 		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(1.0))),
-		sdktrace.WithBatcher(exporter),
-	)
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(seedSpanProcessor{}),
+		sdktrace.WithSpanProcessor(metrics),
+	}
+	if baggage := newBaggageSpanProcessor(); baggage != nil {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(baggage))
+	}
 
-	// Set global tracer provider and propagator
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if exporter != nil {
+		if o.blockingExport {
+			batcherOpts = append(batcherOpts, sdktrace.WithBlocking())
+		}
+		var export sdktrace.SpanProcessor = sdktrace.NewBatchSpanProcessor(exporter, batcherOpts...)
+		if tail != nil {
+			export = tailSamplingProcessor{tail, export}
+		}
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(export))
+	}
 
-	log.Println("Tracer initialized successfully")
-	return tp, nil
+	return sdktrace.NewTracerProvider(tpOpts...), nil
+}
+
+// Tracer returns a tracer of tp that, like the global one, timestamps spans of requests
+// on a virtual clock with the clock's time
+func Tracer(tp trace.TracerProvider, name string) trace.Tracer {
+	return virtualTimeProvider{tp}.Tracer(name)
 }
 
 // SimulateWork creates a span and simulates work with random duration
@@ -70,8 +136,8 @@ func SimulateWork(ctx context.Context, tracer trace.Tracer, spanName string, min
 	span.SetAttributes(attrs...)
 
 	// Simulate work with random duration
-	duration := time.Duration(minMs+rand.Intn(maxMs-minMs+1)) * time.Millisecond
-	time.Sleep(duration)
+	duration := time.Duration(minMs+random.FromContext(ctx).Intn(maxMs-minMs+1)) * time.Millisecond
+	clock.Sleep(ctx, duration)
 }
 
 // SimulateWorkWithContext creates a span and returns context for nested spans
@@ -80,8 +146,8 @@ func SimulateWorkWithContext(ctx context.Context, tracer trace.Tracer, spanName
 	span.SetAttributes(attrs...)
 
 	// Simulate work with random duration
-	duration := time.Duration(minMs+rand.Intn(maxMs-minMs+1)) * time.Millisecond
-	time.Sleep(duration)
+	duration := time.Duration(minMs+random.FromContext(ctx).Intn(maxMs-minMs+1)) * time.Millisecond
+	clock.Sleep(ctx, duration)
 
 	return ctx, span
 }
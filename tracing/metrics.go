@@ -0,0 +1,220 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MetricsExporterEnvVar set to none stops pushing metrics over OTLP; /metrics is always served
+const MetricsExporterEnvVar = "OTEL_METRICS_EXPORTER"
+
+// maxMetricSeries bounds the attribute sets of each instrument, as a safety net against
+// labels that grow with the requests
+const maxMetricSeries = 2000
+
+// stepIndexSuffix matches the index suffixes of spans named per item, such as processItem-3
+var stepIndexSuffix = regexp.MustCompile(`(-[0-9]+)+$`)
+
+// durationBuckets are the histogram bounds in seconds: the HTTP semantic conventions' buckets,
+// extended for the multi-second reports and simulated slow payments
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10, 15, 30}
+
+// InitMeter initializes the global meter provider. Metrics are pushed over OTLP as configured by
+// the OTEL_EXPORTER_OTLP_* variables, unless OTEL_METRICS_EXPORTER=none, and the returned handler serves them in the
+// Prometheus format; exemplars are included when the scrape asks for OpenMetrics.
+func InitMeter(ctx context.Context) (*sdkmetric.MeterProvider, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+
+	disabled, err := SDKDisabled()
+	if err != nil {
+		return nil, nil, err
+	}
+	if disabled {
+		// Nothing is collected, /metrics stays empty
+		mp := sdkmetric.NewMeterProvider()
+		otel.SetMeterProvider(mp)
+		return mp, handler, nil
+	}
+
+	promExporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create Prometheus exporter: %w", err)
+	}
+
+	res, err := NewResource(ctx, ServiceName())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []sdkmetric.Option{
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(promExporter),
+		sdkmetric.WithCardinalityLimit(maxMetricSeries),
+	}
+
+	switch exporter := strings.ToLower(os.Getenv(MetricsExporterEnvVar)); exporter {
+	case "", "otlp":
+		otlpExporter, err := newOTLPMetricExporter(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(otlpExporter)))
+	case "none", "prometheus":
+	default:
+		return nil, nil, fmt.Errorf("unsupported %s %q: expected otlp, prometheus or none", MetricsExporterEnvVar, exporter)
+	}
+
+	mp := sdkmetric.NewMeterProvider(opts...)
+	otel.SetMeterProvider(mp)
+	return mp, handler, nil
+}
+
+// newOTLPMetricExporter creates the OTLP exporter configured by the OTEL_EXPORTER_OTLP_* variables.
+// The SDK has no JSON encoding for metrics, so http/json sends protobuf to the same endpoint.
+func newOTLPMetricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	cfg, err := OTLPConfigFromEnv(SignalMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	var exporter sdkmetric.Exporter
+	switch cfg.Protocol {
+	case ProtocolGRPC:
+		opts := OTLPGRPCOptions[otlpmetricgrpc.Option]{
+			WithEndpoint:       otlpmetricgrpc.WithEndpoint,
+			WithHeaders:        otlpmetricgrpc.WithHeaders,
+			WithTimeout:        otlpmetricgrpc.WithTimeout,
+			WithInsecure:       otlpmetricgrpc.WithInsecure,
+			WithTLSCredentials: otlpmetricgrpc.WithTLSCredentials,
+			WithCompressor:     otlpmetricgrpc.WithCompressor,
+		}.Options(cfg)
+		exporter, err = otlpmetricgrpc.New(ctx, opts...)
+	case ProtocolHTTPProtobuf, ProtocolHTTPJSON:
+		opts := OTLPHTTPOptions[otlpmetrichttp.Option]{
+			WithEndpointURL:     otlpmetrichttp.WithEndpointURL,
+			WithHeaders:         otlpmetrichttp.WithHeaders,
+			WithTimeout:         otlpmetrichttp.WithTimeout,
+			WithTLSClientConfig: otlpmetrichttp.WithTLSClientConfig,
+			Gzip:                otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression),
+		}.Options(cfg)
+		exporter, err = otlpmetrichttp.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// metricsSpanProcessor derives RED metrics from the spans as they end: the duration of every
+// request per route, and of every internal step per span name, in histograms whose counts give
+// the rates and whose error.type attribute gives the errors. Each measurement carries its span's
+// context, so the histogram buckets get exemplars linking them to traces.
+type metricsSpanProcessor struct {
+	service  string
+	requests metric.Float64Histogram
+	steps    metric.Float64Histogram
+}
+
+// newMetricsSpanProcessor records the metrics of service's spans with the global meter provider,
+// which InitMeter may set later
+func newMetricsSpanProcessor(service string) (*metricsSpanProcessor, error) {
+	meter := otel.Meter("trace-demo-service")
+
+	requests, err := meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of HTTP server requests"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request duration histogram: %w", err)
+	}
+	steps, err := meter.Float64Histogram("demo.step.duration",
+		metric.WithDescription("Duration of the internal steps of the requests, by span name"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create step duration histogram: %w", err)
+	}
+
+	return &metricsSpanProcessor{service: service, requests: requests, steps: steps}, nil
+}
+
+func (p *metricsSpanProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *metricsSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	attrs := make(map[attribute.Key]attribute.Value, len(s.Attributes()))
+	for _, kv := range s.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	// Scenario and simulated spans are named by the request; they would only add noise
+	if spanType := attrs["span.type"].AsString(); spanType == "scenario" || spanType == "simulated" {
+		return
+	}
+
+	ctx := trace.ContextWithSpanContext(context.Background(), s.SpanContext())
+	duration := s.EndTime().Sub(s.StartTime()).Seconds()
+
+	labels := []attribute.KeyValue{attribute.String("service", p.service)}
+	if s.Status().Code == codes.Error {
+		labels = append(labels, semconv.ErrorTypeKey.String(errorType(s)))
+	}
+
+	if s.SpanKind() == trace.SpanKindServer {
+		labels = append(labels,
+			semconv.HTTPRequestMethodKey.String(attrs["http.method"].AsString()),
+			semconv.HTTPRoute(attrs["http.route"].AsString()),
+		)
+		p.requests.Record(ctx, duration, metric.WithAttributes(labels...))
+		return
+	}
+
+	labels = append(labels, attribute.String("step", stepName(s.Name())))
+	p.steps.Record(ctx, duration, metric.WithAttributes(labels...))
+}
+
+func (p *metricsSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (p *metricsSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// stepName returns the step label of a span: its name without an index suffix, so that all
+// processItem-N spans share one series
+func stepName(spanName string) string {
+	if step := stepIndexSuffix.ReplaceAllString(spanName, ""); step != "" {
+		return step
+	}
+	return spanName
+}
+
+// errorType returns the type of the first exception a failed span recorded, or _OTHER
+func errorType(s sdktrace.ReadOnlySpan) string {
+	for _, event := range s.Events() {
+		if event.Name != semconv.ExceptionEventName {
+			continue
+		}
+		for _, kv := range event.Attributes {
+			if kv.Key == semconv.ExceptionTypeKey {
+				return kv.Value.AsString()
+			}
+		}
+	}
+	return "_OTHER"
+}
@@ -0,0 +1,19 @@
+package tracing
+
+import "testing"
+
+func TestStepName(t *testing.T) {
+	tests := map[string]string{
+		"processItem-3":   "processItem",
+		"processItem-120": "processItem",
+		"level-2-span-1":  "level-2-span",
+		"processPayment":  "processPayment",
+		"GET /inventory":  "GET /inventory",
+		"-1":              "-1",
+	}
+	for name, want := range tests {
+		if got := stepName(name); got != want {
+			t.Errorf("stepName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package tracing
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OTLP JSON format structures, as returned by Tempo's /api/traces/{id}
+
+// OTLPTrace represents a trace in OTLP JSON format.
+// Tempo returns "batches"; plain OTLP exports use "resourceSpans".
+type OTLPTrace struct {
+	Batches       []OTLPBatch `json:"batches"`
+	ResourceSpans []OTLPBatch `json:"resourceSpans"`
+}
+
+// OTLPBatch represents the spans emitted by a single resource
+type OTLPBatch struct {
+	Resource   OTLPResource    `json:"resource"`
+	ScopeSpans []OTLPScopeSpan `json:"scopeSpans"`
+	// InstrumentationLibrarySpans is the pre-1.0 name of ScopeSpans
+	InstrumentationLibrarySpans []OTLPScopeSpan `json:"instrumentationLibrarySpans"`
+}
+
+// OTLPResource represents the resource (service, host, ...) that emitted a batch
+type OTLPResource struct {
+	Attributes []OTLPAttribute `json:"attributes"`
+}
+
+// OTLPScopeSpan represents the spans emitted by a single instrumentation scope
+type OTLPScopeSpan struct {
+	Scope OTLPScope  `json:"scope"`
+	Spans []OTLPSpan `json:"spans"`
+}
+
+// OTLPScope represents an instrumentation scope
+type OTLPScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// OTLPSpan represents a span in OTLP JSON format
+type OTLPSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              OTLPEnum        `json:"kind"`
+	StartTimeUnixNano json.Number     `json:"startTimeUnixNano" swaggertype:"string"`
+	EndTimeUnixNano   json.Number     `json:"endTimeUnixNano" swaggertype:"string"`
+	Attributes        []OTLPAttribute `json:"attributes"`
+	Events            []OTLPEvent     `json:"events,omitempty"`
+	Links             []OTLPLink      `json:"links,omitempty"`
+	Status            OTLPStatus      `json:"status"`
+}
+
+// OTLPEvent represents a timestamped event recorded on a span
+type OTLPEvent struct {
+	TimeUnixNano json.Number     `json:"timeUnixNano" swaggertype:"string"`
+	Name         string          `json:"name"`
+	Attributes   []OTLPAttribute `json:"attributes,omitempty"`
+}
+
+// OTLPLink represents a link from a span to a span in the same or another trace
+type OTLPLink struct {
+	TraceID    string          `json:"traceId"`
+	SpanID     string          `json:"spanId"`
+	TraceState string          `json:"traceState,omitempty"`
+	Attributes []OTLPAttribute `json:"attributes,omitempty"`
+}
+
+// OTLPAttribute represents a key/value attribute
+type OTLPAttribute struct {
+	Key   string    `json:"key"`
+	Value OTLPValue `json:"value"`
+}
+
+// OTLPValue represents an attribute value; exactly one field is set
+type OTLPValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	IntValue    *json.Number    `json:"intValue,omitempty" swaggertype:"string"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	BytesValue  *string         `json:"bytesValue,omitempty"`
+	ArrayValue  *OTLPArrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *OTLPKvlist     `json:"kvlistValue,omitempty"`
+}
+
+// OTLPArrayValue represents an array attribute value
+type OTLPArrayValue struct {
+	Values []OTLPValue `json:"values"`
+}
+
+// OTLPKvlist represents a nested key/value list attribute value
+type OTLPKvlist struct {
+	Values []OTLPAttribute `json:"values"`
+}
+
+// OTLPStatus represents the status of a span
+type OTLPStatus struct {
+	Code    OTLPEnum `json:"code"`
+	Message string   `json:"message,omitempty"`
+}
+
+// OTLPEnum represents an OTLP enum value, which may be encoded as its number or its name
+type OTLPEnum string
+
+// UnmarshalJSON accepts both the numeric and the string form of an enum
+func (e *OTLPEnum) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*e = OTLPEnum(s)
+		return nil
+	}
+
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid OTLP enum value %s", string(data))
+	}
+	*e = OTLPEnum(strconv.Itoa(n))
+	return nil
+}
+
+var spanKindNames = map[string]string{
+	"0": "unspecified", "SPAN_KIND_UNSPECIFIED": "unspecified",
+	"1": "internal", "SPAN_KIND_INTERNAL": "internal",
+	"2": "server", "SPAN_KIND_SERVER": "server",
+	"3": "client", "SPAN_KIND_CLIENT": "client",
+	"4": "producer", "SPAN_KIND_PRODUCER": "producer",
+	"5": "consumer", "SPAN_KIND_CONSUMER": "consumer",
+}
+
+var statusCodeNames = map[string]string{
+	"0": "UNSET", "STATUS_CODE_UNSET": "UNSET",
+	"1": "OK", "STATUS_CODE_OK": "OK",
+	"2": "ERROR", "STATUS_CODE_ERROR": "ERROR",
+}
+
+// SpanKind returns the Jaeger-style lower-case span kind, e.g. "server"
+func (s OTLPSpan) SpanKind() string {
+	if kind, ok := spanKindNames[string(s.Kind)]; ok {
+		return kind
+	}
+	return strings.ToLower(strings.TrimPrefix(string(s.Kind), "SPAN_KIND_"))
+}
+
+// StatusCode returns the status code name: UNSET, OK or ERROR
+func (s OTLPStatus) StatusCode() string {
+	if code, ok := statusCodeNames[string(s.Code)]; ok {
+		return code
+	}
+	return strings.TrimPrefix(string(s.Code), "STATUS_CODE_")
+}
+
+// allBatches returns the batches regardless of which field Tempo used
+func (t *OTLPTrace) allBatches() []OTLPBatch {
+	if len(t.Batches) > 0 {
+		return t.Batches
+	}
+	return t.ResourceSpans
+}
+
+// allScopeSpans returns the scope spans regardless of which field the exporter used
+func (b *OTLPBatch) allScopeSpans() []OTLPScopeSpan {
+	if len(b.ScopeSpans) > 0 {
+		return b.ScopeSpans
+	}
+	return b.InstrumentationLibrarySpans
+}
+
+// NormalizeTraceID converts a trace ID in hex or base64 form to 32 lower-case hex characters
+func NormalizeTraceID(id string) string {
+	return normalizeID(id, 16)
+}
+
+// NormalizeSpanID converts a span ID in hex or base64 form to 16 lower-case hex characters
+func NormalizeSpanID(id string) string {
+	return normalizeID(id, 8)
+}
+
+// normalizeID converts an ID of byteLen bytes to lower-case hex.
+// OTLP JSON from Tempo encodes IDs as padded base64 while Grafana and the SDK display hex;
+// Jaeger JSON may also drop leading zeros from hex IDs. Hex is checked first, as short hex IDs
+// are often valid base64 too; base64 is only tried for the padded length of byteLen bytes.
+func normalizeID(id string, byteLen int) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return ""
+	}
+
+	if len(id) <= 2*byteLen && isHex(id) {
+		return strings.Repeat("0", 2*byteLen-len(id)) + strings.ToLower(id)
+	}
+
+	if len(id) == base64.StdEncoding.EncodedLen(byteLen) {
+		for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+			if decoded, err := encoding.DecodeString(id); err == nil && len(decoded) == byteLen {
+				return hex.EncodeToString(decoded)
+			}
+		}
+	}
+
+	return id
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// convertOTLPToJaeger converts OTLP trace format to Jaeger format.
+// IDs are normalized to hex, each batch keeps its own resource as the span process,
+// span kind and status become tags, events (including exceptions) become logs and links become
+// FOLLOWS_FROM references carrying the link attributes as tags.
+func convertOTLPToJaeger(otlp *OTLPTrace, traceID string) (*TempoTrace, error) {
+	traceID = NormalizeTraceID(traceID)
+	trace := &TempoTrace{
+		TraceID:   traceID,
+		Spans:     make([]TempoSpan, 0),
+		Processes: make([]TempoProcess, 0),
+	}
+
+	for _, batch := range otlp.allBatches() {
+		process := convertResource(batch.Resource)
+		trace.Processes = append(trace.Processes, process)
+
+		for _, scopeSpan := range batch.allScopeSpans() {
+			for _, otlpSpan := range scopeSpan.Spans {
+				trace.Spans = append(trace.Spans, convertOTLPSpan(otlpSpan, scopeSpan.Scope, process, traceID))
+			}
+		}
+	}
+
+	return trace, nil
+}
+
+func convertResource(resource OTLPResource) TempoProcess {
+	process := TempoProcess{
+		ServiceName: "unknown-service",
+		Tags:        make([]TempoTag, 0, len(resource.Attributes)),
+	}
+	for _, attr := range resource.Attributes {
+		if attr.Key == "service.name" && attr.Value.StringValue != nil {
+			process.ServiceName = *attr.Value.StringValue
+			continue
+		}
+		process.Tags = append(process.Tags, convertAttribute(attr))
+	}
+	return process
+}
+
+func convertOTLPSpan(otlpSpan OTLPSpan, scope OTLPScope, process TempoProcess, traceID string) TempoSpan {
+	startTime := parseUnixNano(otlpSpan.StartTimeUnixNano)
+	endTime := parseUnixNano(otlpSpan.EndTimeUnixNano)
+
+	spanTraceID := traceID
+	if otlpSpan.TraceID != "" {
+		spanTraceID = NormalizeTraceID(otlpSpan.TraceID)
+	}
+
+	// Convert attributes to tags
+	tags := make([]TempoTag, 0, len(otlpSpan.Attributes)+5)
+	for _, attr := range otlpSpan.Attributes {
+		tags = append(tags, convertAttribute(attr))
+	}
+	if kind := otlpSpan.SpanKind(); kind != "" && kind != "unspecified" {
+		tags = append(tags, TempoTag{Key: "span.kind", Type: "string", Value: kind})
+	}
+	if code := otlpSpan.Status.StatusCode(); code != "" && code != "UNSET" {
+		tags = append(tags, TempoTag{Key: "otel.status_code", Type: "string", Value: code})
+		if code == "ERROR" {
+			tags = append(tags, TempoTag{Key: "error", Type: "bool", Value: true})
+		}
+	}
+	if otlpSpan.Status.Message != "" {
+		tags = append(tags, TempoTag{Key: "otel.status_description", Type: "string", Value: otlpSpan.Status.Message})
+	}
+	if scope.Name != "" {
+		tags = append(tags, TempoTag{Key: "otel.scope.name", Type: "string", Value: scope.Name})
+	}
+
+	// Parent becomes CHILD_OF, links become FOLLOWS_FROM
+	references := make([]TempoReference, 0, 1+len(otlpSpan.Links))
+	if otlpSpan.ParentSpanID != "" {
+		references = append(references, TempoReference{
+			RefType: "CHILD_OF",
+			TraceID: spanTraceID,
+			SpanID:  NormalizeSpanID(otlpSpan.ParentSpanID),
+		})
+	}
+	for _, link := range otlpSpan.Links {
+		ref := TempoReference{
+			RefType: "FOLLOWS_FROM",
+			TraceID: NormalizeTraceID(link.TraceID),
+			SpanID:  NormalizeSpanID(link.SpanID),
+		}
+		for _, attr := range link.Attributes {
+			ref.Tags = append(ref.Tags, convertAttribute(attr))
+		}
+		references = append(references, ref)
+	}
+
+	// Events become logs, with the event name as the "event" field
+	logs := make([]TempoLog, 0, len(otlpSpan.Events))
+	for _, event := range otlpSpan.Events {
+		fields := make([]TempoTag, 0, len(event.Attributes)+1)
+		fields = append(fields, TempoTag{Key: "event", Type: "string", Value: event.Name})
+		for _, attr := range event.Attributes {
+			fields = append(fields, convertAttribute(attr))
+		}
+		logs = append(logs, TempoLog{
+			Timestamp: parseUnixNano(event.TimeUnixNano) / 1000,
+			Fields:    fields,
+		})
+	}
+
+	return TempoSpan{
+		TraceID:       spanTraceID,
+		SpanID:        NormalizeSpanID(otlpSpan.SpanID),
+		OperationName: otlpSpan.Name,
+		StartTime:     startTime / 1000, // Convert nanoseconds to microseconds
+		Duration:      (endTime - startTime) / 1000,
+		Tags:          tags,
+		Logs:          logs,
+		References:    references,
+		Process:       process,
+	}
+}
+
+// convertAttribute converts an OTLP attribute to a Jaeger tag, keeping the value's type
+func convertAttribute(attr OTLPAttribute) TempoTag {
+	tag := TempoTag{Key: attr.Key}
+	v := attr.Value
+
+	switch {
+	case v.StringValue != nil:
+		tag.Type, tag.Value = "string", *v.StringValue
+	case v.IntValue != nil:
+		if n, err := v.IntValue.Int64(); err == nil {
+			tag.Type, tag.Value = "int64", n
+		} else {
+			tag.Type, tag.Value = "string", v.IntValue.String()
+		}
+	case v.DoubleValue != nil:
+		tag.Type, tag.Value = "float64", *v.DoubleValue
+	case v.BoolValue != nil:
+		tag.Type, tag.Value = "bool", *v.BoolValue
+	case v.BytesValue != nil:
+		tag.Type, tag.Value = "binary", *v.BytesValue
+	default:
+		// Arrays and kvlists are flattened to their JSON representation
+		tag.Type, tag.Value = "string", valueToJSON(v)
+	}
+	return tag
+}
+
+// plainValue converts an OTLP value to a plain Go value
+func plainValue(v OTLPValue) interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.IntValue != nil:
+		if n, err := v.IntValue.Int64(); err == nil {
+			return n
+		}
+		return v.IntValue.String()
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.BytesValue != nil:
+		return *v.BytesValue
+	case v.ArrayValue != nil:
+		values := make([]interface{}, 0, len(v.ArrayValue.Values))
+		for _, item := range v.ArrayValue.Values {
+			values = append(values, plainValue(item))
+		}
+		return values
+	case v.KvlistValue != nil:
+		values := make(map[string]interface{}, len(v.KvlistValue.Values))
+		for _, item := range v.KvlistValue.Values {
+			values[item.Key] = plainValue(item.Value)
+		}
+		return values
+	}
+	return nil
+}
+
+func valueToJSON(v OTLPValue) string {
+	data, err := json.Marshal(plainValue(v))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func parseUnixNano(n json.Number) int64 {
+	value, err := strconv.ParseInt(string(n), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
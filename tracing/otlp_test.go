@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		byteLen int
+		want    string
+	}{
+		{"hex trace ID", "2F3E0CEE77AE5DC9C17ADE3689EB2E54", 16, "2f3e0cee77ae5dc9c17ade3689eb2e54"},
+		{"hex span ID", "00f067aa0ba902b7", 8, "00f067aa0ba902b7"},
+		{"short hex trace ID", "7ae5dc9c17ade3689eb2e54", 16, "0000000007ae5dc9c17ade3689eb2e54"},
+		// 11 characters are also valid unpadded base64 for 8 bytes; Jaeger drops leading zeros
+		{"short hex span ID", "f067aa0ba90", 8, "00000f067aa0ba90"},
+		{"short hex span ID of base64 length", "0067aa0ba902", 8, "00000067aa0ba902"},
+		{"base64 trace ID", "Lz4M7neuXcnBet42iesuVA==", 16, "2f3e0cee77ae5dc9c17ade3689eb2e54"},
+		{"base64 span ID", "APBnqgupArc=", 8, "00f067aa0ba902b7"},
+		{"base64 span ID without hex digits", "t61rcWkgMzE=", 8, "b7ad6b7169203331"},
+		{"URL-safe base64 trace ID", "-_8AAAAAAAAAAAAAAAAAAQ==", 16, "fbff0000000000000000000000000001"},
+		{"standard base64 span ID", "+//7/wAAAAE=", 8, "fbfffbff00000001"},
+		{"whitespace", " 00f067aa0ba902b7\n", 8, "00f067aa0ba902b7"},
+		{"empty", "", 8, ""},
+		{"not an ID", "not-an-id", 8, "not-an-id"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeID(tt.id, tt.byteLen); got != tt.want {
+			t.Errorf("%s: normalizeID(%q, %d) = %q, want %q", tt.name, tt.id, tt.byteLen, got, tt.want)
+		}
+	}
+}
+
+// otlpTraceJSON is a trace as Tempo returns it in OTLP JSON: base64 IDs, enums by name or number,
+// int64 as strings, and every kind of attribute value
+const otlpTraceJSON = `{"batches":[{
+  "resource":{"attributes":[
+    {"key":"service.name","value":{"stringValue":"payment-service"}},
+    {"key":"host.name","value":{"stringValue":"node-1"}}]},
+  "scopeSpans":[{"scope":{"name":"trace-demo-service"},"spans":[{
+    "traceId":"Lz4M7neuXcnBet42iesuVA==","spanId":"APBnqgupArc=","parentSpanId":"t61rcWkgMzE=",
+    "name":"processPayment","kind":"SPAN_KIND_CLIENT",
+    "startTimeUnixNano":"1700000000000000000","endTimeUnixNano":"1700000000250000000",
+    "attributes":[
+      {"key":"amount","value":{"doubleValue":99.5}},
+      {"key":"retries","value":{"intValue":"3"}},
+      {"key":"approved","value":{"boolValue":true}},
+      {"key":"methods","value":{"arrayValue":{"values":[{"stringValue":"card"},{"intValue":"2"}]}}},
+      {"key":"card","value":{"kvlistValue":{"values":[{"key":"brand","value":{"stringValue":"visa"}},{"key":"ratio","value":{"doubleValue":0.5}}]}}}],
+    "events":[{"timeUnixNano":"1700000000100000000","name":"exception","attributes":[
+      {"key":"exception.message","value":{"stringValue":"card declined"}}]}],
+    "links":[{"traceId":"-_8AAAAAAAAAAAAAAAAAAQ==","spanId":"+//7/wAAAAE=","attributes":[
+      {"key":"link.type","value":{"stringValue":"batch"}}]}],
+    "status":{"code":2,"message":"card declined"}}]}]}]}`
+
+func TestConvertOTLPToJaeger(t *testing.T) {
+	var otlp OTLPTrace
+	if err := json.Unmarshal([]byte(otlpTraceJSON), &otlp); err != nil {
+		t.Fatal(err)
+	}
+	trace, err := convertOTLPToJaeger(&otlp, "Lz4M7neuXcnBet42iesuVA==")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trace.TraceID != "2f3e0cee77ae5dc9c17ade3689eb2e54" || len(trace.Spans) != 1 {
+		t.Fatalf("trace = %s with %d spans", trace.TraceID, len(trace.Spans))
+	}
+	span := trace.Spans[0]
+	if span.SpanID != "00f067aa0ba902b7" || span.StartTime != 1700000000000000 || span.Duration != 250000 {
+		t.Errorf("span = %s at %d for %d", span.SpanID, span.StartTime, span.Duration)
+	}
+	if span.Process.ServiceName != "payment-service" || len(span.Process.Tags) != 1 {
+		t.Errorf("process = %+v", span.Process)
+	}
+
+	tags := make(map[string]TempoTag)
+	for _, tag := range span.Tags {
+		tags[tag.Key] = tag
+	}
+	wantTags := map[string]TempoTag{
+		"amount":                  {Key: "amount", Type: "float64", Value: 99.5},
+		"retries":                 {Key: "retries", Type: "int64", Value: int64(3)},
+		"approved":                {Key: "approved", Type: "bool", Value: true},
+		"methods":                 {Key: "methods", Type: "string", Value: `["card",2]`},
+		"card":                    {Key: "card", Type: "string", Value: `{"brand":"visa","ratio":0.5}`},
+		"span.kind":               {Key: "span.kind", Type: "string", Value: "client"},
+		"otel.status_code":        {Key: "otel.status_code", Type: "string", Value: "ERROR"},
+		"error":                   {Key: "error", Type: "bool", Value: true},
+		"otel.status_description": {Key: "otel.status_description", Type: "string", Value: "card declined"},
+		"otel.scope.name":         {Key: "otel.scope.name", Type: "string", Value: "trace-demo-service"},
+	}
+	if !reflect.DeepEqual(tags, wantTags) {
+		t.Errorf("tags = %+v\nwant %+v", tags, wantTags)
+	}
+
+	wantRefs := []TempoReference{
+		{RefType: "CHILD_OF", TraceID: "2f3e0cee77ae5dc9c17ade3689eb2e54", SpanID: "b7ad6b7169203331"},
+		{RefType: "FOLLOWS_FROM", TraceID: "fbff0000000000000000000000000001", SpanID: "fbfffbff00000001",
+			Tags: []TempoTag{{Key: "link.type", Type: "string", Value: "batch"}}},
+	}
+	if !reflect.DeepEqual(span.References, wantRefs) {
+		t.Errorf("references = %+v\nwant %+v", span.References, wantRefs)
+	}
+
+	wantLogs := []TempoLog{{Timestamp: 1700000000100000, Fields: []TempoTag{
+		{Key: "event", Type: "string", Value: "exception"},
+		{Key: "exception.message", Type: "string", Value: "card declined"},
+	}}}
+	if !reflect.DeepEqual(span.Logs, wantLogs) {
+		t.Errorf("logs = %+v\nwant %+v", span.Logs, wantLogs)
+	}
+}
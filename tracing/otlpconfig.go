@@ -0,0 +1,230 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// OTLP protocols, selected by OTEL_EXPORTER_OTLP_PROTOCOL or OTEL_EXPORTER_OTLP_<SIGNAL>_PROTOCOL
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+	ProtocolHTTPJSON     = "http/json"
+)
+
+// OTLP signals, the <SIGNAL> of the signal specific OTEL_EXPORTER_OTLP_<SIGNAL>_* variables
+const (
+	SignalTraces  = "TRACES"
+	SignalMetrics = "METRICS"
+	SignalLogs    = "LOGS"
+)
+
+// OTLPConfig is where and how a signal is exported over OTLP
+type OTLPConfig struct {
+	Protocol    string
+	Endpoint    string // host:port, for gRPC
+	URL         string // Full URL including the signal's path, such as http://localhost:4318/v1/traces, for HTTP
+	Insecure    bool   // Plain text instead of TLS
+	TLS         *tls.Config
+	Headers     map[string]string
+	Compression string // "gzip" or "none"
+	Timeout     time.Duration
+}
+
+// OTLPConfigFromEnv reads the OTLP configuration of signal from the standard environment variables,
+// each OTEL_EXPORTER_OTLP_<SIGNAL>_* variable taking precedence over its OTEL_EXPORTER_OTLP_* one:
+// PROTOCOL, ENDPOINT, INSECURE, CERTIFICATE, CLIENT_CERTIFICATE, CLIENT_KEY, HEADERS, COMPRESSION and TIMEOUT.
+// Endpoints without a scheme, such as otel-collector:4317, are plain text unless INSECURE is false
+// or a certificate is configured.
+func OTLPConfigFromEnv(signal string) (OTLPConfig, error) {
+	cfg := OTLPConfig{
+		Protocol:    strings.ToLower(otlpEnv(signal, "PROTOCOL", ProtocolGRPC)),
+		Compression: strings.ToLower(otlpEnv(signal, "COMPRESSION", "none")),
+	}
+
+	switch cfg.Protocol {
+	case ProtocolGRPC, ProtocolHTTPProtobuf, ProtocolHTTPJSON:
+	default:
+		return cfg, fmt.Errorf("unsupported OTLP protocol %q: expected grpc, http/protobuf or http/json", cfg.Protocol)
+	}
+	if cfg.Compression != "gzip" && cfg.Compression != "none" {
+		return cfg, fmt.Errorf("unsupported OTLP compression %q: expected gzip or none", cfg.Compression)
+	}
+
+	timeout := otlpEnv(signal, "TIMEOUT", "10000")
+	ms, err := strconv.Atoi(timeout)
+	if err != nil || ms <= 0 {
+		return cfg, fmt.Errorf("invalid OTLP timeout %q: expected a positive number of milliseconds", timeout)
+	}
+	cfg.Timeout = time.Duration(ms) * time.Millisecond
+
+	if cfg.Headers, err = parseOTLPHeaders(otlpEnv(signal, "HEADERS", "")); err != nil {
+		return cfg, err
+	}
+	if cfg.TLS, err = otlpTLSConfig(signal); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.setEndpoint(signal); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// setEndpoint resolves the endpoint and transport security of signal. A signal specific endpoint
+// is used as is, while the signal's path, such as /v1/traces, is appended to a generic HTTP one.
+func (c *OTLPConfig) setEndpoint(signal string) error {
+	endpoint, path := os.Getenv("OTEL_EXPORTER_OTLP_"+signal+"_ENDPOINT"), ""
+	if endpoint == "" {
+		endpoint, path = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/v1/"+strings.ToLower(signal)
+	}
+	if endpoint == "" {
+		endpoint = "localhost:4317"
+		if c.Protocol != ProtocolGRPC {
+			endpoint = "localhost:4318"
+		}
+	}
+
+	if !strings.Contains(endpoint, "://") {
+		insecure, err := strconv.ParseBool(otlpEnv(signal, "INSECURE", strconv.FormatBool(len(c.TLS.Certificates) == 0 && c.TLS.RootCAs == nil)))
+		if err != nil {
+			return fmt.Errorf("invalid OTLP insecure setting: %w", err)
+		}
+		scheme := "https"
+		if insecure {
+			scheme = "http"
+		}
+		endpoint = scheme + "://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid OTLP endpoint %q: expected host:port or an http(s) URL", endpoint)
+	}
+
+	c.Insecure = u.Scheme == "http"
+	c.Endpoint = u.Host
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	c.URL = u.String()
+	return nil
+}
+
+// otlpEnv returns OTEL_EXPORTER_OTLP_<signal>_<name>, else OTEL_EXPORTER_OTLP_<name>, else defaultValue
+func otlpEnv(signal, name, defaultValue string) string {
+	if value := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_" + name); value != "" {
+		return value
+	}
+	return getEnv("OTEL_EXPORTER_OTLP_"+name, defaultValue)
+}
+
+// parseOTLPHeaders parses comma separated key=value pairs with percent-encoded values
+func parseOTLPHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid OTLP header %q: expected key=value", pair)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP header %q: %w", key, err)
+		}
+		headers[key] = decoded
+	}
+	return headers, nil
+}
+
+// otlpTLSConfig builds the TLS configuration of signal from its CA and client certificate files
+func otlpTLSConfig(signal string) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if caFile := otlpEnv(signal, "CERTIFICATE", ""); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OTLP certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in OTLP certificate %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	certFile, keyFile := otlpEnv(signal, "CLIENT_CERTIFICATE", ""), otlpEnv(signal, "CLIENT_KEY", "")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OTLP client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// OTLPGRPCOptions are the option constructors of one of the SDK's OTLP gRPC exporter packages,
+// such as otlptracegrpc, so the same configuration is applied to the exporters of every signal
+type OTLPGRPCOptions[O any] struct {
+	WithEndpoint       func(string) O
+	WithHeaders        func(map[string]string) O
+	WithTimeout        func(time.Duration) O
+	WithInsecure       func() O
+	WithTLSCredentials func(credentials.TransportCredentials) O
+	WithCompressor     func(string) O
+}
+
+// Options returns the gRPC exporter options for cfg
+func (o OTLPGRPCOptions[O]) Options(cfg OTLPConfig) []O {
+	opts := []O{
+		o.WithEndpoint(cfg.Endpoint),
+		o.WithHeaders(cfg.Headers),
+		o.WithTimeout(cfg.Timeout),
+	}
+	if cfg.Insecure {
+		opts = append(opts, o.WithInsecure())
+	} else {
+		opts = append(opts, o.WithTLSCredentials(credentials.NewTLS(cfg.TLS)))
+	}
+	if cfg.Compression == "gzip" {
+		opts = append(opts, o.WithCompressor("gzip"))
+	}
+	return opts
+}
+
+// OTLPHTTPOptions are the option constructors of one of the SDK's OTLP HTTP exporter packages,
+// such as otlptracehttp. Gzip is the package's WithCompression(GzipCompression) option.
+type OTLPHTTPOptions[O any] struct {
+	WithEndpointURL     func(string) O
+	WithHeaders         func(map[string]string) O
+	WithTimeout         func(time.Duration) O
+	WithTLSClientConfig func(*tls.Config) O
+	Gzip                O
+}
+
+// Options returns the HTTP exporter options for cfg
+func (o OTLPHTTPOptions[O]) Options(cfg OTLPConfig) []O {
+	opts := []O{
+		o.WithEndpointURL(cfg.URL),
+		o.WithHeaders(cfg.Headers),
+		o.WithTimeout(cfg.Timeout),
+	}
+	if !cfg.Insecure {
+		opts = append(opts, o.WithTLSClientConfig(cfg.TLS))
+	}
+	if cfg.Compression == "gzip" {
+		opts = append(opts, o.Gzip)
+	}
+	return opts
+}
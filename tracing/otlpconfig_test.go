@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"crypto/tls"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Options are rendered as strings, standing in for one exporter package's option type
+var (
+	testGRPCOptions = OTLPGRPCOptions[string]{
+		WithEndpoint:       func(endpoint string) string { return "endpoint=" + endpoint },
+		WithHeaders:        func(headers map[string]string) string { return fmt.Sprint("headers=", headers) },
+		WithTimeout:        func(timeout time.Duration) string { return "timeout=" + timeout.String() },
+		WithInsecure:       func() string { return "insecure" },
+		WithTLSCredentials: func(credentials.TransportCredentials) string { return "tls" },
+		WithCompressor:     func(name string) string { return "compressor=" + name },
+	}
+	testHTTPOptions = OTLPHTTPOptions[string]{
+		WithEndpointURL:     func(url string) string { return "url=" + url },
+		WithHeaders:         func(headers map[string]string) string { return fmt.Sprint("headers=", headers) },
+		WithTimeout:         func(timeout time.Duration) string { return "timeout=" + timeout.String() },
+		WithTLSClientConfig: func(*tls.Config) string { return "tls" },
+		Gzip:                "gzip",
+	}
+)
+
+func TestOTLPOptions(t *testing.T) {
+	headers := map[string]string{"x-api-key": "secret"}
+	plain := OTLPConfig{Endpoint: "collector:4317", URL: "http://collector:4318/v1/traces", Insecure: true,
+		Headers: headers, Compression: "none", Timeout: 10 * time.Second}
+	secure := OTLPConfig{Endpoint: "collector:4317", URL: "https://collector:4318/v1/traces", TLS: &tls.Config{},
+		Headers: headers, Compression: "gzip", Timeout: time.Second}
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"grpc plain", testGRPCOptions.Options(plain),
+			[]string{"endpoint=collector:4317", "headers=map[x-api-key:secret]", "timeout=10s", "insecure"}},
+		{"grpc tls gzip", testGRPCOptions.Options(secure),
+			[]string{"endpoint=collector:4317", "headers=map[x-api-key:secret]", "timeout=1s", "tls", "compressor=gzip"}},
+		{"http plain", testHTTPOptions.Options(plain),
+			[]string{"url=http://collector:4318/v1/traces", "headers=map[x-api-key:secret]", "timeout=10s"}},
+		{"http tls gzip", testHTTPOptions.Options(secure),
+			[]string{"url=https://collector:4318/v1/traces", "headers=map[x-api-key:secret]", "timeout=1s", "tls", "gzip"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: options = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP protobuf decoding, for Tempo's application/protobuf response of /api/traces/{id}.
+// Tempo's protobuf Trace message ({ repeated ResourceSpans batches = 1 }) is wire-compatible
+// with OTLP TracesData, so it decodes directly into tracepb.TracesData.
+
+const protobufContentType = "application/protobuf"
+
+// isProtobufContentType reports whether a Content-Type header denotes a protobuf payload
+func isProtobufContentType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	return mediaType == protobufContentType || mediaType == "application/x-protobuf"
+}
+
+// decodeOTLPProtobuf decodes a protobuf encoded trace into the OTLP model
+func decodeOTLPProtobuf(body []byte) (*OTLPTrace, error) {
+	var data tracepb.TracesData
+	if err := proto.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode protobuf trace: %w", err)
+	}
+
+	trace := &OTLPTrace{
+		Batches: make([]OTLPBatch, 0, len(data.ResourceSpans)),
+	}
+	for _, rs := range data.ResourceSpans {
+		batch := OTLPBatch{
+			Resource:   OTLPResource{Attributes: protoAttributes(rs.GetResource().GetAttributes())},
+			ScopeSpans: make([]OTLPScopeSpan, 0, len(rs.ScopeSpans)),
+		}
+		for _, ss := range rs.ScopeSpans {
+			scopeSpan := OTLPScopeSpan{
+				Scope: OTLPScope{
+					Name:    ss.GetScope().GetName(),
+					Version: ss.GetScope().GetVersion(),
+				},
+				Spans: make([]OTLPSpan, 0, len(ss.Spans)),
+			}
+			for _, s := range ss.Spans {
+				scopeSpan.Spans = append(scopeSpan.Spans, protoSpan(s))
+			}
+			batch.ScopeSpans = append(batch.ScopeSpans, scopeSpan)
+		}
+		trace.Batches = append(trace.Batches, batch)
+	}
+
+	return trace, nil
+}
+
+func protoSpan(s *tracepb.Span) OTLPSpan {
+	span := OTLPSpan{
+		TraceID:           hex.EncodeToString(s.TraceId),
+		SpanID:            hex.EncodeToString(s.SpanId),
+		ParentSpanID:      hex.EncodeToString(s.ParentSpanId),
+		TraceState:        s.TraceState,
+		Name:              s.Name,
+		Kind:              OTLPEnum(strconv.Itoa(int(s.Kind))),
+		StartTimeUnixNano: unixNanoNumber(s.StartTimeUnixNano),
+		EndTimeUnixNano:   unixNanoNumber(s.EndTimeUnixNano),
+		Attributes:        protoAttributes(s.Attributes),
+		Status: OTLPStatus{
+			Code:    OTLPEnum(strconv.Itoa(int(s.GetStatus().GetCode()))),
+			Message: s.GetStatus().GetMessage(),
+		},
+	}
+
+	for _, e := range s.Events {
+		span.Events = append(span.Events, OTLPEvent{
+			TimeUnixNano: unixNanoNumber(e.TimeUnixNano),
+			Name:         e.Name,
+			Attributes:   protoAttributes(e.Attributes),
+		})
+	}
+	for _, l := range s.Links {
+		span.Links = append(span.Links, OTLPLink{
+			TraceID:    hex.EncodeToString(l.TraceId),
+			SpanID:     hex.EncodeToString(l.SpanId),
+			TraceState: l.TraceState,
+			Attributes: protoAttributes(l.Attributes),
+		})
+	}
+
+	return span
+}
+
+func protoAttributes(kvs []*commonpb.KeyValue) []OTLPAttribute {
+	attrs := make([]OTLPAttribute, 0, len(kvs))
+	for _, kv := range kvs {
+		attrs = append(attrs, OTLPAttribute{Key: kv.Key, Value: protoValue(kv.Value)})
+	}
+	return attrs
+}
+
+func protoValue(v *commonpb.AnyValue) OTLPValue {
+	var value OTLPValue
+	switch x := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		value.StringValue = &x.StringValue
+	case *commonpb.AnyValue_IntValue:
+		n := json.Number(strconv.FormatInt(x.IntValue, 10))
+		value.IntValue = &n
+	case *commonpb.AnyValue_DoubleValue:
+		value.DoubleValue = &x.DoubleValue
+	case *commonpb.AnyValue_BoolValue:
+		value.BoolValue = &x.BoolValue
+	case *commonpb.AnyValue_BytesValue:
+		encoded := base64.StdEncoding.EncodeToString(x.BytesValue)
+		value.BytesValue = &encoded
+	case *commonpb.AnyValue_ArrayValue:
+		array := &OTLPArrayValue{Values: make([]OTLPValue, 0, len(x.ArrayValue.GetValues()))}
+		for _, item := range x.ArrayValue.GetValues() {
+			array.Values = append(array.Values, protoValue(item))
+		}
+		value.ArrayValue = array
+	case *commonpb.AnyValue_KvlistValue:
+		value.KvlistValue = &OTLPKvlist{Values: protoAttributes(x.KvlistValue.GetValues())}
+	}
+	return value
+}
+
+func unixNanoNumber(n uint64) json.Number {
+	return json.Number(strconv.FormatUint(n, 10))
+}
@@ -0,0 +1,170 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+// newProtobufTrace returns a marshalled tracepb.TracesData, the wire format of Tempo's protobuf response
+func newProtobufTrace(t *testing.T) []byte {
+	t.Helper()
+	data := &tracepb.TracesData{ResourceSpans: []*tracepb.ResourceSpans{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			{Key: "service.name", Value: stringValue("payment-service")},
+		}},
+		ScopeSpans: []*tracepb.ScopeSpans{{
+			Scope: &commonpb.InstrumentationScope{Name: "trace-demo-service"},
+			Spans: []*tracepb.Span{{
+				TraceId:           mustHex(t, testTraceID),
+				SpanId:            mustHex(t, "00f067aa0ba902b7"),
+				ParentSpanId:      mustHex(t, "b7ad6b7169203331"),
+				Name:              "processPayment",
+				Kind:              tracepb.Span_SPAN_KIND_CLIENT,
+				StartTimeUnixNano: 1700000000000000000,
+				EndTimeUnixNano:   1700000000250000000,
+				Attributes: []*commonpb.KeyValue{
+					{Key: "amount", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: 99.5}}},
+					{Key: "retries", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 3}}},
+				},
+				Events: []*tracepb.Span_Event{{
+					TimeUnixNano: 1700000000100000000,
+					Name:         "exception",
+					Attributes:   []*commonpb.KeyValue{{Key: "exception.message", Value: stringValue("card declined")}},
+				}},
+				Links: []*tracepb.Span_Link{{
+					TraceId: mustHex(t, "fbff0000000000000000000000000001"),
+					SpanId:  mustHex(t, "fbfffbff00000001"),
+				}},
+				Status: &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: "card declined"},
+			}},
+		}},
+	}}}
+	body, err := proto.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// newFormatServer starts a Tempo stand-in that answers protobuf requests with protobufBody
+// and JSON requests with jsonBody, recording the Accept header of each request
+func newFormatServer(t *testing.T, protobufBody []byte, jsonBody string, accepts *[]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		mu.Lock()
+		*accepts = append(*accepts, accept)
+		mu.Unlock()
+
+		if strings.HasPrefix(accept, protobufContentType) {
+			w.Header().Set("Content-Type", protobufContentType)
+			w.Write(protobufBody)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(jsonBody))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestQueryTraceProtobuf(t *testing.T) {
+	var accepts []string
+	server := newFormatServer(t, newProtobufTrace(t), `{}`, &accepts)
+
+	trace, err := (&TempoClient{BaseURL: server.URL}).QueryTrace(context.Background(), testTraceID)
+	if err != nil {
+		t.Fatalf("QueryTrace: %v", err)
+	}
+	if len(accepts) != 1 {
+		t.Errorf("requests = %q, want a single protobuf request", accepts)
+	}
+
+	if len(trace.Spans) != 1 {
+		t.Fatalf("Spans = %+v", trace.Spans)
+	}
+	span := trace.Spans[0]
+	if span.SpanID != "00f067aa0ba902b7" || span.OperationName != "processPayment" || span.Duration != 250000 {
+		t.Errorf("span = %s %s for %d", span.SpanID, span.OperationName, span.Duration)
+	}
+	if span.Process.ServiceName != "payment-service" {
+		t.Errorf("service = %q", span.Process.ServiceName)
+	}
+
+	wantRefs := []TempoReference{
+		{RefType: "CHILD_OF", TraceID: testTraceID, SpanID: "b7ad6b7169203331"},
+		{RefType: "FOLLOWS_FROM", TraceID: "fbff0000000000000000000000000001", SpanID: "fbfffbff00000001"},
+	}
+	if !reflect.DeepEqual(span.References, wantRefs) {
+		t.Errorf("references = %+v, want %+v", span.References, wantRefs)
+	}
+
+	tags := make(map[string]interface{})
+	for _, tag := range span.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	for key, want := range map[string]interface{}{"amount": 99.5, "retries": int64(3), "span.kind": "client", "error": true} {
+		if tags[key] != want {
+			t.Errorf("tag %s = %#v, want %#v", key, tags[key], want)
+		}
+	}
+	if len(span.Logs) != 1 || span.Logs[0].Timestamp != 1700000000100000 {
+		t.Errorf("logs = %+v", span.Logs)
+	}
+}
+
+func TestQueryTraceFallsBackToJSON(t *testing.T) {
+	var accepts []string
+	// A payload that is not a valid protobuf message: field 1 announces more bytes than follow
+	server := newFormatServer(t, []byte{0x0a, 0xff, 0x01}, otlpTraceJSON, &accepts)
+
+	trace, err := (&TempoClient{BaseURL: server.URL}).QueryTrace(context.Background(), testTraceID)
+	if err != nil {
+		t.Fatalf("QueryTrace: %v", err)
+	}
+
+	if len(accepts) != 2 || accepts[1] != "application/json" {
+		t.Errorf("requests = %q, want protobuf then application/json", accepts)
+	}
+	if len(trace.Spans) != 1 || trace.Spans[0].SpanID != "00f067aa0ba902b7" {
+		t.Errorf("Spans = %+v", trace.Spans)
+	}
+}
+
+func TestQueryTraceJSONFormat(t *testing.T) {
+	var accepts []string
+	server := newFormatServer(t, nil, otlpTraceJSON, &accepts)
+
+	client := &TempoClient{BaseURL: server.URL, QueryFormat: "json"}
+	if _, err := client.QueryTrace(context.Background(), testTraceID); err != nil {
+		t.Fatalf("QueryTrace: %v", err)
+	}
+	if !reflect.DeepEqual(accepts, []string{"application/json"}) {
+		t.Errorf("requests = %q, want only application/json", accepts)
+	}
+}
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// PropagatorsEnvVar lists the propagators injecting and extracting context in HTTP headers, comma
+// separated: tracecontext (traceparent), baggage, b3 (single b3 header), b3multi (X-B3-* headers),
+// jaeger (uber-trace-id, without its uberctx-* baggage) or none (default: tracecontext,baggage).
+// Incoming context is extracted from whichever headers are present, the later propagators winning;
+// outgoing requests carry the headers of all of them.
+const PropagatorsEnvVar = "OTEL_PROPAGATORS"
+
+// newPropagatorFromEnv returns the composite propagator of OTEL_PROPAGATORS
+func newPropagatorFromEnv() (propagation.TextMapPropagator, error) {
+	var propagators []propagation.TextMapPropagator
+	for _, name := range strings.Split(getEnv(PropagatorsEnvVar, "tracecontext,baggage"), ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case "jaeger":
+			propagators = append(propagators, jaeger.Jaeger{})
+		case "none", "":
+		default:
+			return nil, fmt.Errorf("unsupported %s %q: expected tracecontext, baggage, b3, b3multi, jaeger or none", PropagatorsEnvVar, name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
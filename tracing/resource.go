@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"tempo-otlp-trace-demo/buildinfo"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// ResourceAttributesEnvVar adds comma separated key=value resource attributes, overriding the detected ones
+const ResourceAttributesEnvVar = "OTEL_RESOURCE_ATTRIBUTES"
+
+// Build attributes, from buildinfo
+const (
+	BuildCommitKey   = attribute.Key("build.commit")
+	BuildDateKey     = attribute.Key("build.date")
+	BuildModifiedKey = attribute.Key("build.modified")
+)
+
+// legacyEnvironmentKey mirrors deployment.environment for queries written before it existed
+const legacyEnvironmentKey = attribute.Key("environment")
+
+// ServiceName returns the name of the main service, from OTEL_SERVICE_NAME or else the service.name
+// of OTEL_RESOURCE_ATTRIBUTES
+func ServiceName() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	for _, pair := range strings.Split(os.Getenv(ResourceAttributesEnvVar), ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok && strings.TrimSpace(key) == string(semconv.ServiceNameKey) && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return "trace-demo-service"
+}
+
+// NewResource describes serviceName for its traces, metrics and logs: its build, host, OS, process
+// and container, then OTEL_RESOURCE_ATTRIBUTES, which overrides them, and finally its name.
+// Detectors that fail, such as the container one outside a container, are skipped.
+func NewResource(ctx context.Context, serviceName string) (*resource.Resource, error) {
+	build := buildinfo.Get()
+	defaults := []attribute.KeyValue{
+		semconv.ServiceVersion(build.Version),
+		semconv.DeploymentEnvironment("demo"),
+	}
+	if build.Commit != "" {
+		defaults = append(defaults, BuildCommitKey.String(build.Commit), BuildModifiedKey.Bool(build.Modified))
+	}
+	if build.Date != "" {
+		defaults = append(defaults, BuildDateKey.String(build.Date))
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOS(),
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithContainer(),
+		resource.WithAttributes(defaults...),
+		resource.WithFromEnv(),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+	)
+	if errors.Is(err, resource.ErrPartialResource) {
+		slog.DebugContext(ctx, "Some resource detectors failed", "service", serviceName, "error", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	if _, ok := res.Set().Value(legacyEnvironmentKey); !ok {
+		env, _ := res.Set().Value(semconv.DeploymentEnvironmentKey)
+		if res, err = resource.Merge(res, resource.NewSchemaless(legacyEnvironmentKey.String(env.AsString()))); err != nil {
+			return nil, fmt.Errorf("failed to create resource: %w", err)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,265 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Sampling environment variables. OTEL_TRACES_SAMPLER takes the SDK's samplers, always_on, always_off,
+// traceidratio and their parentbased_ variants, plus ratelimiting and parentbased_ratelimiting,
+// whose OTEL_TRACES_SAMPLER_ARG is the number of traces sampled per second.
+const (
+	SamplerEnvVar       = "OTEL_TRACES_SAMPLER"
+	SamplerArgEnvVar    = "OTEL_TRACES_SAMPLER_ARG"
+	SamplingRulesEnvVar = "DEMO_SAMPLING_RULES"
+)
+
+// Attributes recording on each sampled root span why its trace was kept
+const (
+	// SamplingDecisionKey names the sampler that kept the trace: always_on, traceidratio,
+	// ratelimiting, rule, or tail_error, tail_slow and tail_baseline with tail sampling
+	SamplingDecisionKey = attribute.Key("sampling.decision")
+	// SamplingRatioKey is the probability that the trace was kept, if known; a kept trace
+	// stands for 1/ratio traces when counting
+	SamplingRatioKey = attribute.Key("sampling.ratio")
+	// SamplingRuleKey is the route of the DEMO_SAMPLING_RULES rule that sampled the trace
+	SamplingRuleKey = attribute.Key("sampling.rule")
+)
+
+// newSamplerFromEnv builds the sampler of OTEL_TRACES_SAMPLER, with the per-route rules of
+// DEMO_SAMPLING_RULES taking precedence for the root spans of their routes. The default samples
+// every trace. Spans that are not sampled are still recorded, so RED metrics count them.
+func newSamplerFromEnv() (sdktrace.Sampler, error) {
+	name := strings.ToLower(strings.TrimSpace(getEnv(SamplerEnvVar, "parentbased_always_on")))
+	parentBased := strings.HasPrefix(name, "parentbased_")
+
+	root, err := newRootSampler(strings.TrimPrefix(name, "parentbased_"), os.Getenv(SamplerArgEnvVar))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", SamplerEnvVar, name, err)
+	}
+
+	rules, err := parseSamplingRules(os.Getenv(SamplingRulesEnvVar))
+	if err != nil {
+		return nil, err
+	}
+
+	var sampler sdktrace.Sampler = routeSampler{rules: rules, fallback: root}
+	if parentBased {
+		sampler = sdktrace.ParentBased(sampler)
+	}
+	return recordingSampler{sampler}, nil
+}
+
+// newRootSampler returns the sampler named name, without its parentbased_ prefix, configured by arg
+func newRootSampler(name, arg string) (sdktrace.Sampler, error) {
+	switch name {
+	case "always_on":
+		return labeledSampler{sdktrace.AlwaysSample(), "always_on", 1}, nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		ratio, err := parseRatio(arg, 1)
+		if err != nil {
+			return nil, err
+		}
+		return labeledSampler{sdktrace.TraceIDRatioBased(ratio), "traceidratio", ratio}, nil
+	case "ratelimiting":
+		rate, err := parseRate(arg, 1)
+		if err != nil {
+			return nil, err
+		}
+		return labeledSampler{newRateLimitingSampler(rate), "ratelimiting", 0}, nil
+	default:
+		return nil, fmt.Errorf("expected always_on, always_off, traceidratio or ratelimiting, optionally prefixed with parentbased_")
+	}
+}
+
+// samplingRule samples the root spans of route with its own sampler
+type samplingRule struct {
+	route   string
+	sampler sdktrace.Sampler
+}
+
+// parseSamplingRules parses comma separated route=ratio or route=N/s rules, such as
+// "/api/report/generate=1,/api/search=0.05,/api/batch/process=2/s"
+func parseSamplingRules(value string) ([]samplingRule, error) {
+	var rules []samplingRule
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		route, arg, ok := strings.Cut(field, "=")
+		route, arg = strings.TrimSpace(route), strings.TrimSpace(arg)
+		if !ok || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("invalid %s rule %q: expected <route>=<ratio> or <route>=<traces>/s", SamplingRulesEnvVar, field)
+		}
+
+		rule := samplingRule{route: route}
+		if perSecond, ok := strings.CutSuffix(arg, "/s"); ok {
+			rate, err := parseRate(perSecond, 0)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s rule %q: %w", SamplingRulesEnvVar, field, err)
+			}
+			rule.sampler = labeledSampler{newRateLimitingSampler(rate), "rule", 0}
+		} else {
+			ratio, err := parseRatio(arg, 0)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s rule %q: %w", SamplingRulesEnvVar, field, err)
+			}
+			rule.sampler = labeledSampler{sdktrace.TraceIDRatioBased(ratio), "rule", ratio}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseRatio parses a sampling probability between 0 and 1; empty means defaultValue
+func parseRatio(value string, defaultValue float64) (float64, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("ratio %q must be a number between 0 and 1", value)
+	}
+	return ratio, nil
+}
+
+// parseRate parses a positive number of traces per second; empty means defaultValue
+func parseRate(value string, defaultValue float64) (float64, error) {
+	if value == "" && defaultValue > 0 {
+		return defaultValue, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("rate %q must be a positive number of traces per second", value)
+	}
+	return rate, nil
+}
+
+// routeSampler samples root spans with the rule of their route, if any, and fallback otherwise
+type routeSampler struct {
+	rules    []samplingRule
+	fallback sdktrace.Sampler
+}
+
+func (s routeSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if route := spanRoute(p); route != "" {
+		for _, rule := range s.rules {
+			if rule.route == route {
+				result := rule.sampler.ShouldSample(p)
+				if result.Decision == sdktrace.RecordAndSample {
+					result.Attributes = append(result.Attributes, SamplingRuleKey.String(rule.route))
+				}
+				return result
+			}
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s routeSampler) Description() string {
+	routes := make([]string, len(s.rules))
+	for i, rule := range s.rules {
+		routes[i] = rule.route + "=" + rule.sampler.Description()
+	}
+	return fmt.Sprintf("RouteSampler{%s,fallback:%s}", strings.Join(routes, ","), s.fallback.Description())
+}
+
+// spanRoute returns the http.route a span starts with or, as the handlers set it after starting
+// their spans, the path of span names such as "POST /api/order/create"
+func spanRoute(p sdktrace.SamplingParameters) string {
+	for _, kv := range p.Attributes {
+		if kv.Key == semconv.HTTPRouteKey {
+			return kv.Value.AsString()
+		}
+	}
+	if _, path, ok := strings.Cut(p.Name, " "); ok && strings.HasPrefix(path, "/") {
+		return path
+	}
+	return ""
+}
+
+// labeledSampler records why it sampled a trace on the span it decided for
+type labeledSampler struct {
+	sampler  sdktrace.Sampler
+	decision string
+	ratio    float64 // 0 when the probability is not known in advance
+}
+
+func (s labeledSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.sampler.ShouldSample(p)
+	if result.Decision == sdktrace.RecordAndSample {
+		result.Attributes = append(result.Attributes, SamplingDecisionKey.String(s.decision))
+		if s.ratio > 0 {
+			result.Attributes = append(result.Attributes, SamplingRatioKey.Float64(s.ratio))
+		}
+	}
+	return result
+}
+
+func (s labeledSampler) Description() string {
+	return s.sampler.Description()
+}
+
+// rateLimitingSampler samples up to rate traces per second, with bursts of up to one second's worth
+type rateLimitingSampler struct {
+	rate float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimitingSampler(rate float64) *rateLimitingSampler {
+	return &rateLimitingSampler{rate: rate, tokens: max(rate, 1)}
+}
+
+func (s *rateLimitingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	psc := trace.SpanContextFromContext(p.ParentContext)
+	result := sdktrace.SamplingResult{Decision: sdktrace.Drop, Tracestate: psc.TraceState()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if !s.last.IsZero() {
+		s.tokens = min(s.tokens+now.Sub(s.last).Seconds()*s.rate, max(s.rate, 1))
+	}
+	s.last = now
+
+	if s.tokens >= 1 {
+		s.tokens--
+		result.Decision = sdktrace.RecordAndSample
+	}
+	return result
+}
+
+func (s *rateLimitingSampler) Description() string {
+	return fmt.Sprintf("RateLimitingSampler{%g}", s.rate)
+}
+
+// recordingSampler records the spans its sampler drops instead of discarding them: they are
+// not exported, but the span processors, such as the RED metrics, still see them end
+type recordingSampler struct {
+	sdktrace.Sampler
+}
+
+func (s recordingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := s.Sampler.ShouldSample(p)
+	if result.Decision == sdktrace.Drop {
+		result.Decision = sdktrace.RecordOnly
+	}
+	return result
+}
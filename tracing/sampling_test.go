@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func TestRouteSampler(t *testing.T) {
+	rules, err := parseSamplingRules("/api/report/generate=1, /api/search=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampler := routeSampler{rules: rules, fallback: labeledSampler{sdktrace.AlwaysSample(), "always_on", 1}}
+
+	tests := []struct {
+		name     string
+		attrs    []attribute.KeyValue
+		decision sdktrace.SamplingDecision
+		want     map[attribute.Key]string
+	}{
+		{name: "POST /api/report/generate", decision: sdktrace.RecordAndSample,
+			want: map[attribute.Key]string{"sampling.decision": "rule", "sampling.ratio": "1", "sampling.rule": "/api/report/generate"}},
+		{name: "handler", attrs: []attribute.KeyValue{semconv.HTTPRoute("/api/report/generate")}, decision: sdktrace.RecordAndSample,
+			want: map[attribute.Key]string{"sampling.decision": "rule", "sampling.ratio": "1", "sampling.rule": "/api/report/generate"}},
+		{name: "GET /api/search", decision: sdktrace.Drop, want: map[attribute.Key]string{}},
+		{name: "GET /api/order/create", decision: sdktrace.RecordAndSample,
+			want: map[attribute.Key]string{"sampling.decision": "always_on", "sampling.ratio": "1"}},
+		{name: "db.query", decision: sdktrace.RecordAndSample,
+			want: map[attribute.Key]string{"sampling.decision": "always_on", "sampling.ratio": "1"}},
+	}
+
+	for _, tt := range tests {
+		result := sampler.ShouldSample(sdktrace.SamplingParameters{Name: tt.name, Attributes: tt.attrs})
+		if result.Decision != tt.decision {
+			t.Errorf("%s: decision = %v, want %v", tt.name, result.Decision, tt.decision)
+		}
+		got := make(map[attribute.Key]string)
+		for _, kv := range result.Attributes {
+			got[kv.Key] = kv.Value.Emit()
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: attributes = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for key, value := range tt.want {
+			if got[key] != value {
+				t.Errorf("%s: %s = %q, want %q", tt.name, key, got[key], value)
+			}
+		}
+	}
+}
+
+func TestParseSamplingRules(t *testing.T) {
+	rules, err := parseSamplingRules("/api/search=0.05,,/api/batch/process=2/s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].route != "/api/search" || rules[1].route != "/api/batch/process" {
+		t.Fatalf("rules = %+v", rules)
+	}
+	if got := rules[1].sampler.Description(); got != "RateLimitingSampler{2}" {
+		t.Errorf("rate rule sampler = %s, want RateLimitingSampler{2}", got)
+	}
+
+	for _, value := range []string{"api/search=1", "/api/search", "/api/search=2", "/api/search=0/s", "/api/search=x/s"} {
+		if _, err := parseSamplingRules(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestRateLimitingSampler(t *testing.T) {
+	sampler := newRateLimitingSampler(3)
+
+	sampled := 0
+	for range 10 {
+		if sampler.ShouldSample(sdktrace.SamplingParameters{}).Decision == sdktrace.RecordAndSample {
+			sampled++
+		}
+	}
+	if sampled != 3 {
+		t.Errorf("sampled %d of a burst of 10, want 3", sampled)
+	}
+
+	// A second later the bucket is full again, but holds no more than a second's worth
+	sampler.last = sampler.last.Add(-10 * time.Second)
+	sampled = 0
+	for range 10 {
+		if sampler.ShouldSample(sdktrace.SamplingParameters{}).Decision == sdktrace.RecordAndSample {
+			sampled++
+		}
+	}
+	if sampled != 3 {
+		t.Errorf("sampled %d after refilling, want 3", sampled)
+	}
+}
+
+func TestRateLimitingSamplerBelowOnePerSecond(t *testing.T) {
+	sampler := newRateLimitingSampler(0.5)
+
+	if sampler.ShouldSample(sdktrace.SamplingParameters{}).Decision != sdktrace.RecordAndSample {
+		t.Fatal("first trace was not sampled")
+	}
+	if sampler.ShouldSample(sdktrace.SamplingParameters{}).Decision != sdktrace.Drop {
+		t.Fatal("second trace was sampled")
+	}
+	sampler.last = sampler.last.Add(-2 * time.Second)
+	if sampler.ShouldSample(sdktrace.SamplingParameters{}).Decision != sdktrace.RecordAndSample {
+		t.Error("trace two seconds later was not sampled")
+	}
+}
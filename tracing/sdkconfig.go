@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SDKDisabledEnvVar set to true turns every signal into a no-op: spans are not recorded,
+// metrics are not collected and nothing is exported. Context is still propagated.
+const SDKDisabledEnvVar = "OTEL_SDK_DISABLED"
+
+// Batch span processor environment variables
+const (
+	BSPScheduleDelayEnvVar      = "OTEL_BSP_SCHEDULE_DELAY"
+	BSPExportTimeoutEnvVar      = "OTEL_BSP_EXPORT_TIMEOUT"
+	BSPMaxQueueSizeEnvVar       = "OTEL_BSP_MAX_QUEUE_SIZE"
+	BSPMaxExportBatchSizeEnvVar = "OTEL_BSP_MAX_EXPORT_BATCH_SIZE"
+)
+
+// SDKDisabled reports whether OTEL_SDK_DISABLED turns the SDK off
+func SDKDisabled() (bool, error) {
+	value := os.Getenv(SDKDisabledEnvVar)
+	if value == "" {
+		return false, nil
+	}
+	disabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %w", SDKDisabledEnvVar, value, err)
+	}
+	return disabled, nil
+}
+
+// batchOptionsFromEnv reads the batch span processor settings of the OTEL_BSP_* variables.
+// The SDK reads them too, but ignores invalid values; they are rejected here instead.
+func batchOptionsFromEnv() ([]sdktrace.BatchSpanProcessorOption, error) {
+	var opts []sdktrace.BatchSpanProcessorOption
+
+	if ms, ok, err := positiveIntEnv(BSPScheduleDelayEnvVar); err != nil {
+		return nil, err
+	} else if ok {
+		opts = append(opts, sdktrace.WithBatchTimeout(time.Duration(ms)*time.Millisecond))
+	}
+	if ms, ok, err := positiveIntEnv(BSPExportTimeoutEnvVar); err != nil {
+		return nil, err
+	} else if ok {
+		opts = append(opts, sdktrace.WithExportTimeout(time.Duration(ms)*time.Millisecond))
+	}
+
+	queueSize, hasQueueSize, err := positiveIntEnv(BSPMaxQueueSizeEnvVar)
+	if err != nil {
+		return nil, err
+	}
+	if hasQueueSize {
+		opts = append(opts, sdktrace.WithMaxQueueSize(queueSize))
+	}
+	batchSize, hasBatchSize, err := positiveIntEnv(BSPMaxExportBatchSizeEnvVar)
+	if err != nil {
+		return nil, err
+	}
+	if hasBatchSize {
+		if hasQueueSize && batchSize > queueSize {
+			return nil, fmt.Errorf("%s %d must not exceed %s %d", BSPMaxExportBatchSizeEnvVar, batchSize, BSPMaxQueueSizeEnvVar, queueSize)
+		}
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(batchSize))
+	}
+
+	return opts, nil
+}
+
+// positiveIntEnv reads a positive integer from the environment; ok is false when key is unset
+func positiveIntEnv(key string) (value int, ok bool, err error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return 0, false, nil
+	}
+	value, err = strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		return 0, false, fmt.Errorf("invalid %s %q: must be a positive integer", key, raw)
+	}
+	return value, true, nil
+}
@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchParams represents the parameters of a Tempo search
+type SearchParams struct {
+	Query           string            // TraceQL query, e.g. { name = "POST /api/order/create" && duration > 2s }
+	Tags            map[string]string // Tag filters, sent as logfmt
+	MinDuration     string            // e.g. "2s"
+	MaxDuration     string            // e.g. "10s"
+	Limit           int               // Maximum number of traces
+	SpansPerSpanSet int               // Maximum number of spans returned per span set
+	Start           time.Time         // Start of the search window
+	End             time.Time         // End of the search window
+}
+
+// SearchResponse represents the response of Tempo's /api/search endpoint
+type SearchResponse struct {
+	Traces  []TraceSearchResult `json:"traces"`
+	Metrics SearchMetrics       `json:"metrics"`
+}
+
+// TraceSearchResult represents a trace matching a search
+type TraceSearchResult struct {
+	TraceID           string    `json:"traceID"`
+	RootServiceName   string    `json:"rootServiceName"`
+	RootTraceName     string    `json:"rootTraceName"`
+	StartTimeUnixNano string    `json:"startTimeUnixNano"`
+	DurationMs        int64     `json:"durationMs"`
+	SpanSet           *SpanSet  `json:"spanSet,omitempty"`
+	SpanSets          []SpanSet `json:"spanSets,omitempty"`
+}
+
+// SpanSet represents the spans of a trace matched by a TraceQL query
+type SpanSet struct {
+	Spans   []SpanSetSpan `json:"spans"`
+	Matched int           `json:"matched"`
+}
+
+// SpanSetSpan represents a span matched by a TraceQL query
+type SpanSetSpan struct {
+	SpanID            string          `json:"spanID"`
+	Name              string          `json:"name"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	DurationNanos     string          `json:"durationNanos"`
+	Attributes        []OTLPAttribute `json:"attributes,omitempty"`
+}
+
+// SearchMetrics represents the search statistics returned by Tempo
+type SearchMetrics struct {
+	InspectedTraces int    `json:"inspectedTraces,omitempty"`
+	InspectedBytes  string `json:"inspectedBytes,omitempty"`
+	TotalBlocks     int    `json:"totalBlocks,omitempty"`
+	CompletedJobs   int    `json:"completedJobs,omitempty"`
+	TotalJobs       int    `json:"totalJobs,omitempty"`
+}
+
+// SearchTraces searches Tempo for traces using the client configured from the environment
+func SearchTraces(ctx context.Context, params SearchParams) (*SearchResponse, error) {
+	client, err := DefaultTempoClient()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Tempo client configuration: %w", err)
+	}
+	return client.Search(ctx, params)
+}
+
+// Values encodes the search parameters as Tempo query parameters
+func (p SearchParams) Values() url.Values {
+	values := url.Values{}
+	if p.Query != "" {
+		values.Set("q", p.Query)
+	}
+	if len(p.Tags) > 0 {
+		values.Set("tags", encodeLogfmt(p.Tags))
+	}
+	if p.MinDuration != "" {
+		values.Set("minDuration", p.MinDuration)
+	}
+	if p.MaxDuration != "" {
+		values.Set("maxDuration", p.MaxDuration)
+	}
+	if p.Limit > 0 {
+		values.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.SpansPerSpanSet > 0 {
+		values.Set("spss", strconv.Itoa(p.SpansPerSpanSet))
+	}
+	if !p.Start.IsZero() {
+		values.Set("start", strconv.FormatInt(p.Start.Unix(), 10))
+	}
+	if !p.End.IsZero() {
+		values.Set("end", strconv.FormatInt(p.End.Unix(), 10))
+	}
+	return values
+}
+
+// ParseLogfmt parses tag filters in logfmt form, e.g. `http.method=POST service.name="trace demo"`
+func ParseLogfmt(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid tag filter %q: expected key=value", s)
+		}
+		key := s[:eq]
+		if strings.ContainsAny(key, " \"") {
+			return nil, fmt.Errorf("invalid tag key %q", key)
+		}
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted value for tag %q: %w", key, err)
+			}
+			value, _ = strconv.Unquote(quoted)
+			s = s[len(quoted):]
+			if s != "" && s[0] != ' ' {
+				return nil, fmt.Errorf("unexpected %q after quoted value for tag %q", s, key)
+			}
+		} else if sp := strings.IndexByte(s, ' '); sp >= 0 {
+			value = s[:sp]
+			s = s[sp:]
+		} else {
+			value = s
+			s = ""
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+func encodeLogfmt(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := tags[key]
+		if value == "" || strings.ContainsAny(value, " \"=") {
+			value = strconv.Quote(value)
+		}
+		parts = append(parts, key+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
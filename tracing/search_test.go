@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogfmtRoundTrip(t *testing.T) {
+	tags := map[string]string{
+		"http.method":  "POST",
+		"service.name": "trace demo",
+		"empty":        "",
+		"quote":        `say "hi"`,
+		"backslash":    `C:\temp\x`,
+		"equals":       "a=b",
+		"mixed":        `\"= `,
+	}
+
+	encoded := encodeLogfmt(tags)
+	parsed, err := ParseLogfmt(encoded)
+	if err != nil {
+		t.Fatalf("ParseLogfmt(%q): %v", encoded, err)
+	}
+	if !reflect.DeepEqual(parsed, tags) {
+		t.Errorf("round trip of %q = %v, want %v", encoded, parsed, tags)
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{input: `http.method=POST service.name="trace demo"`, want: map[string]string{"http.method": "POST", "service.name": "trace demo"}},
+		{input: `msg="a \"quoted\" word" x=1`, want: map[string]string{"msg": `a "quoted" word`, "x": "1"}},
+		{input: `path="C:\\temp"`, want: map[string]string{"path": `C:\temp`}},
+		{input: `  `, want: map[string]string{}},
+		{input: `novalue`, wantErr: true},
+		{input: `key="unterminated`, wantErr: true},
+		{input: `key="a"b`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLogfmt(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLogfmt(%q) = %v, want error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLogfmt(%q): %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseLogfmt(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	end := start.Add(time.Hour)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/search" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		want := map[string]string{
+			"q":           `{ name = "POST /api/order/create" && duration > 2s }`,
+			"tags":        `service.name="trace demo"`,
+			"minDuration": "2s",
+			"limit":       "5",
+			"start":       "1700000000",
+			"end":         "1700003600",
+		}
+		for key, value := range want {
+			if got := query.Get(key); got != value {
+				t.Errorf("query %s = %q, want %q", key, got, value)
+			}
+		}
+		if got := r.Header.Get("X-Scope-OrgID"); got != "team-a" {
+			t.Errorf("X-Scope-OrgID = %q, want team-a", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"traces":[{"traceID":"2f3e0cee77ae5dc9c17ade3689eb2e54","rootServiceName":"trace-demo",` +
+			`"rootTraceName":"POST /api/order/create","durationMs":2350}],"metrics":{"inspectedTraces":12}}`))
+	}))
+	defer server.Close()
+
+	client := &TempoClient{BaseURL: server.URL, TenantID: "team-a"}
+	result, err := client.Search(context.Background(), SearchParams{
+		Query:       `{ name = "POST /api/order/create" && duration > 2s }`,
+		Tags:        map[string]string{"service.name": "trace demo"},
+		MinDuration: "2s",
+		Limit:       5,
+		Start:       start,
+		End:         end,
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	if len(result.Traces) != 1 || result.Traces[0].DurationMs != 2350 {
+		t.Errorf("Traces = %+v", result.Traces)
+	}
+	if result.Metrics.InspectedTraces != 12 {
+		t.Errorf("InspectedTraces = %d, want 12", result.Metrics.InspectedTraces)
+	}
+}
+
+func TestSearchEmptyResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"metrics":{}}`))
+	}))
+	defer server.Close()
+
+	client := &TempoClient{BaseURL: server.URL}
+	result, err := client.Search(context.Background(), SearchParams{Query: "{}"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if result.Traces == nil || len(result.Traces) != 0 {
+		t.Errorf("Traces = %#v, want empty non-nil slice", result.Traces)
+	}
+}
+
+func TestSearchErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid TraceQL query", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := &TempoClient{BaseURL: server.URL}
+	_, err := client.Search(context.Background(), SearchParams{Query: "{"})
+
+	statusErr, ok := err.(*StatusError)
+	if !ok || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v, want *StatusError with 400", err)
+	}
+}
@@ -0,0 +1,29 @@
+package tracing
+
+import (
+	"context"
+	"tempo-otlp-trace-demo/random"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// seedSpanProcessor records the request seed on the first span a request creates in this service,
+// so any trace can be regenerated by replaying its request with X-Seed
+type seedSpanProcessor struct{}
+
+func (seedSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	seed, ok := random.SeedFromContext(parent)
+	if !ok {
+		return
+	}
+	if sc := trace.SpanContextFromContext(parent); sc.IsValid() && !sc.IsRemote() {
+		return
+	}
+	s.SetAttributes(attribute.Int64("demo.seed", seed))
+}
+
+func (seedSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (seedSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (seedSpanProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,272 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tail sampling environment variables. With DEMO_TAIL_SAMPLING=true the spans of each trace are
+// held back until its root span ends, then the whole trace is exported if one of its spans failed
+// or took at least DEMO_TAIL_SAMPLING_LATENCY, and otherwise with probability DEMO_TAIL_SAMPLING_RATIO.
+const (
+	TailSamplingEnvVar        = "DEMO_TAIL_SAMPLING"
+	TailSamplingLatencyEnvVar = "DEMO_TAIL_SAMPLING_LATENCY"
+	TailSamplingRatioEnvVar   = "DEMO_TAIL_SAMPLING_RATIO"
+)
+
+// maxTailTraces bounds the traces held back at once; the spans of further traces are exported
+// without waiting for a decision
+const maxTailTraces = 10000
+
+// maxTailDecisions is how many decided traces are remembered, for spans ending after their root
+const maxTailDecisions = 10000
+
+// tailSampler holds the spans of the traces started in this process until their root ends.
+// It is shared by the tracer providers of all the services, so that a trace is kept or dropped
+// as a whole, and each provider's tailSamplingProcessor hands it the spans of its service.
+type tailSampler struct {
+	latency  time.Duration
+	baseline sdktrace.Sampler
+	ratio    float64
+
+	mu        sync.Mutex
+	traces    map[trace.TraceID]*tailTrace
+	decisions map[trace.TraceID]bool
+	decided   []trace.TraceID
+}
+
+// tailTrace is a trace waiting for its root span to end
+type tailTrace struct {
+	started map[trace.SpanID]bool
+	spans   []tailSpan
+}
+
+// tailSpan is an ended span waiting for its trace's decision, with the processor exporting it
+type tailSpan struct {
+	span sdktrace.ReadOnlySpan
+	next sdktrace.SpanProcessor
+}
+
+var (
+	tailSamplerOnce sync.Once
+	sharedTail      *tailSampler
+	tailSamplerErr  error
+)
+
+// tailSamplerFromEnv returns the tail sampler shared by all tracer providers, or nil when
+// DEMO_TAIL_SAMPLING is not enabled
+func tailSamplerFromEnv() (*tailSampler, error) {
+	tailSamplerOnce.Do(func() {
+		value := os.Getenv(TailSamplingEnvVar)
+		if value == "" {
+			return
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			tailSamplerErr = fmt.Errorf("invalid %s %q: %w", TailSamplingEnvVar, value, err)
+			return
+		}
+		if !enabled {
+			return
+		}
+
+		latency, err := getDurationEnv(TailSamplingLatencyEnvVar, time.Second)
+		if err != nil {
+			tailSamplerErr = err
+			return
+		}
+		ratio, err := parseRatio(os.Getenv(TailSamplingRatioEnvVar), 0.1)
+		if err != nil {
+			tailSamplerErr = fmt.Errorf("invalid %s: %w", TailSamplingRatioEnvVar, err)
+			return
+		}
+		sharedTail = newTailSampler(latency, ratio)
+	})
+	return sharedTail, tailSamplerErr
+}
+
+func newTailSampler(latency time.Duration, ratio float64) *tailSampler {
+	return &tailSampler{
+		latency:   latency,
+		baseline:  sdktrace.TraceIDRatioBased(ratio),
+		ratio:     ratio,
+		traces:    make(map[trace.TraceID]*tailTrace),
+		decisions: make(map[trace.TraceID]bool),
+	}
+}
+
+// tailSamplingProcessor passes the sampled spans of its service through the tail sampler
+// before handing the kept ones to next, which exports them
+type tailSamplingProcessor struct {
+	sampler *tailSampler
+	next    sdktrace.SpanProcessor
+}
+
+func (p tailSamplingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if s.SpanContext().IsSampled() {
+		p.sampler.start(s)
+	}
+	p.next.OnStart(parent, s)
+}
+
+func (p tailSamplingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		p.next.OnEnd(s)
+		return
+	}
+	for _, kept := range p.sampler.end(s, p.next) {
+		kept.next.OnEnd(kept.span)
+	}
+}
+
+// Shutdown exports the spans of this service that are still waiting for a decision
+func (p tailSamplingProcessor) Shutdown(ctx context.Context) error {
+	for _, pending := range p.sampler.release(p.next) {
+		pending.next.OnEnd(pending.span)
+	}
+	return p.next.Shutdown(ctx)
+}
+
+func (p tailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// start registers a span of a trace, so that its children are not mistaken for roots
+func (t *tailSampler) start(s sdktrace.ReadWriteSpan) {
+	sc := s.SpanContext()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.decisions[sc.TraceID()]; ok {
+		return
+	}
+	tt, ok := t.traces[sc.TraceID()]
+	if !ok {
+		if len(t.traces) >= maxTailTraces {
+			return
+		}
+		tt = &tailTrace{started: make(map[trace.SpanID]bool)}
+		t.traces[sc.TraceID()] = tt
+	}
+	tt.started[sc.SpanID()] = true
+}
+
+// end holds back s until its trace is decided, and returns the spans to export now
+func (t *tailSampler) end(s sdktrace.ReadOnlySpan, next sdktrace.SpanProcessor) []tailSpan {
+	traceID := s.SpanContext().TraceID()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if keep, ok := t.decisions[traceID]; ok {
+		if keep {
+			return []tailSpan{{s, next}}
+		}
+		return nil
+	}
+	tt, ok := t.traces[traceID]
+	if !ok {
+		// The trace did not fit in the buffer
+		return []tailSpan{{s, next}}
+	}
+
+	tt.spans = append(tt.spans, tailSpan{s, next})
+	// The root is the span whose parent did not start in this process: the trace's first span,
+	// or the span continuing a trace started by an outside caller
+	if parent := s.Parent(); parent.IsValid() && tt.started[parent.SpanID()] {
+		return nil
+	}
+
+	delete(t.traces, traceID)
+	decision, ratio, keep := t.decide(s, tt.spans)
+	t.remember(traceID, keep)
+	if !keep {
+		return nil
+	}
+	tt.spans[len(tt.spans)-1].span = decidedSpan{s, decision, ratio}
+	return tt.spans
+}
+
+// decide keeps traces with a failed or slow span, and a baseline share of the others
+func (t *tailSampler) decide(root sdktrace.ReadOnlySpan, spans []tailSpan) (string, float64, bool) {
+	headRatio := 1.0
+	for _, kv := range root.Attributes() {
+		if kv.Key == SamplingRatioKey {
+			headRatio = kv.Value.AsFloat64()
+		}
+	}
+
+	for _, ts := range spans {
+		if ts.span.Status().Code == codes.Error {
+			return "tail_error", headRatio, true
+		}
+	}
+	for _, ts := range spans {
+		if ts.span.EndTime().Sub(ts.span.StartTime()) >= t.latency {
+			return "tail_slow", headRatio, true
+		}
+	}
+
+	result := t.baseline.ShouldSample(sdktrace.SamplingParameters{TraceID: root.SpanContext().TraceID()})
+	return "tail_baseline", headRatio * t.ratio, result.Decision == sdktrace.RecordAndSample
+}
+
+// remember records the decision of a trace for its late spans, forgetting the oldest decisions
+func (t *tailSampler) remember(traceID trace.TraceID, keep bool) {
+	if len(t.decided) >= maxTailDecisions {
+		delete(t.decisions, t.decided[0])
+		t.decided = t.decided[1:]
+	}
+	t.decisions[traceID] = keep
+	t.decided = append(t.decided, traceID)
+}
+
+// release returns and forgets the held back spans that next would export
+func (t *tailSampler) release(next sdktrace.SpanProcessor) []tailSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var released []tailSpan
+	for _, tt := range t.traces {
+		remaining := tt.spans[:0]
+		for _, ts := range tt.spans {
+			if ts.next == next {
+				released = append(released, ts)
+			} else {
+				remaining = append(remaining, ts)
+			}
+		}
+		tt.spans = remaining
+	}
+	return released
+}
+
+// decidedSpan is a root span kept by the tail sampler, with the sampling attributes of the decision
+type decidedSpan struct {
+	sdktrace.ReadOnlySpan
+	decision string
+	ratio    float64
+}
+
+func (s decidedSpan) Attributes() []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(s.ReadOnlySpan.Attributes())+2)
+	for _, kv := range s.ReadOnlySpan.Attributes() {
+		if kv.Key != SamplingDecisionKey && kv.Key != SamplingRatioKey {
+			attrs = append(attrs, kv)
+		}
+	}
+	return append(attrs,
+		SamplingDecisionKey.String(s.decision),
+		SamplingRatioKey.Float64(s.ratio),
+	)
+}
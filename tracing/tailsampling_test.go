@@ -0,0 +1,223 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tailTestSpan describes a synthetic span of a trace driven through a tailSampler
+type tailTestSpan struct {
+	traceID  byte
+	id       byte
+	parent   byte // 0 for a span without parent
+	duration time.Duration
+	failed   bool
+	attrs    []attribute.KeyValue
+}
+
+func (s tailTestSpan) spanContext(id byte) trace.SpanContext {
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{s.traceID},
+		SpanID:     trace.SpanID{id},
+		TraceFlags: trace.FlagsSampled,
+	})
+}
+
+// startedSpan is the ReadWriteSpan handed to tailSampler.start, which only reads its context
+type startedSpan struct {
+	sdktrace.ReadWriteSpan
+	sc trace.SpanContext
+}
+
+func (s startedSpan) SpanContext() trace.SpanContext { return s.sc }
+
+func (s tailTestSpan) started() sdktrace.ReadWriteSpan {
+	return startedSpan{sc: s.spanContext(s.id)}
+}
+
+func (s tailTestSpan) ended() sdktrace.ReadOnlySpan {
+	stub := tracetest.SpanStub{
+		Name:        string('a' + s.id - 1),
+		SpanContext: s.spanContext(s.id),
+		StartTime:   time.Unix(0, 0),
+		EndTime:     time.Unix(0, 0).Add(s.duration),
+		Attributes:  s.attrs,
+	}
+	if s.parent != 0 {
+		stub.Parent = s.spanContext(s.parent)
+	}
+	if s.failed {
+		stub.Status = sdktrace.Status{Code: codes.Error}
+	}
+	return stub.Snapshot()
+}
+
+// spanNames returns the names of the spans released by tailSampler.end
+func spanNames(spans []tailSpan) []string {
+	names := make([]string, len(spans))
+	for i, ts := range spans {
+		names[i] = ts.span.Name()
+	}
+	return names
+}
+
+func TestTailSamplerHoldsSpansUntilRootEnds(t *testing.T) {
+	sampler := newTailSampler(time.Second, 1)
+	next := tracetest.NewSpanRecorder()
+
+	root := tailTestSpan{traceID: 1, id: 1, attrs: []attribute.KeyValue{
+		SamplingDecisionKey.String("always_on"), SamplingRatioKey.Float64(0.5)}}
+	child := tailTestSpan{traceID: 1, id: 2, parent: 1}
+	grandchild := tailTestSpan{traceID: 1, id: 3, parent: 2}
+	for _, s := range []tailTestSpan{root, child, grandchild} {
+		sampler.start(s.started())
+	}
+
+	if got := sampler.end(grandchild.ended(), next); len(got) != 0 {
+		t.Fatalf("grandchild released %v before the root ended", spanNames(got))
+	}
+	if got := sampler.end(child.ended(), next); len(got) != 0 {
+		t.Fatalf("child released %v before the root ended", spanNames(got))
+	}
+	got := sampler.end(root.ended(), next)
+	if names := spanNames(got); len(names) != 3 || names[0] != "c" || names[1] != "b" || names[2] != "a" {
+		t.Fatalf("released %v, want [c b a]", names)
+	}
+	if len(sampler.traces) != 0 {
+		t.Errorf("%d traces still held", len(sampler.traces))
+	}
+
+	attrs := make(map[attribute.Key]string)
+	for _, kv := range got[2].span.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	if attrs[SamplingDecisionKey] != "tail_baseline" || attrs[SamplingRatioKey] != "0.5" {
+		t.Errorf("root sampling attributes = %v, want tail_baseline with ratio 0.5", attrs)
+	}
+
+	// A span ending after its trace was decided follows the decision
+	late := tailTestSpan{traceID: 1, id: 4, parent: 1}
+	sampler.start(late.started())
+	if got := sampler.end(late.ended(), next); len(got) != 1 {
+		t.Errorf("late span of a kept trace released %v", spanNames(got))
+	}
+}
+
+func TestTailSamplerContinuedTraceRoot(t *testing.T) {
+	sampler := newTailSampler(time.Second, 1)
+	next := tracetest.NewSpanRecorder()
+
+	// The server span continues a trace whose parent span, 9, started in the caller's process
+	server := tailTestSpan{traceID: 2, id: 1, parent: 9}
+	client := tailTestSpan{traceID: 2, id: 2, parent: 1}
+	sampler.start(server.started())
+	sampler.start(client.started())
+
+	if got := sampler.end(client.ended(), next); len(got) != 0 {
+		t.Fatalf("client span released %v before its parent ended", spanNames(got))
+	}
+	if got := sampler.end(server.ended(), next); len(got) != 2 {
+		t.Errorf("server span released %v, want the whole trace", spanNames(got))
+	}
+}
+
+func TestTailSamplerDecision(t *testing.T) {
+	tests := []struct {
+		name     string
+		ratio    float64
+		child    tailTestSpan
+		keep     bool
+		decision string
+	}{
+		{name: "failed", ratio: 0, child: tailTestSpan{failed: true}, keep: true, decision: "tail_error"},
+		{name: "slow", ratio: 0, child: tailTestSpan{duration: time.Second}, keep: true, decision: "tail_slow"},
+		{name: "fast", ratio: 0, child: tailTestSpan{duration: time.Second - 1}, keep: false},
+		{name: "baseline", ratio: 1, child: tailTestSpan{}, keep: true, decision: "tail_baseline"},
+	}
+
+	for i, tt := range tests {
+		sampler := newTailSampler(time.Second, tt.ratio)
+		next := tracetest.NewSpanRecorder()
+
+		root := tailTestSpan{traceID: byte(i + 1), id: 1}
+		child := tt.child
+		child.traceID, child.id, child.parent = root.traceID, 2, 1
+		sampler.start(root.started())
+		sampler.start(child.started())
+		sampler.end(child.ended(), next)
+		got := sampler.end(root.ended(), next)
+
+		if !tt.keep {
+			if len(got) != 0 {
+				t.Errorf("%s: kept %v", tt.name, spanNames(got))
+			}
+			continue
+		}
+		if len(got) != 2 {
+			t.Errorf("%s: released %v, want both spans", tt.name, spanNames(got))
+			continue
+		}
+		decision := ""
+		for _, kv := range got[1].span.Attributes() {
+			if kv.Key == SamplingDecisionKey {
+				decision = kv.Value.AsString()
+			}
+		}
+		if decision != tt.decision {
+			t.Errorf("%s: decision = %q, want %q", tt.name, decision, tt.decision)
+		}
+	}
+}
+
+func TestTailSamplerBufferOverflow(t *testing.T) {
+	sampler := newTailSampler(time.Second, 0)
+	next := tracetest.NewSpanRecorder()
+	for i := range maxTailTraces {
+		sampler.traces[trace.TraceID{0xff, byte(i >> 8), byte(i)}] = &tailTrace{started: make(map[trace.SpanID]bool)}
+	}
+
+	// Neither span of a trace past the buffer waits for a decision, though it would be dropped
+	root := tailTestSpan{traceID: 1, id: 1}
+	child := tailTestSpan{traceID: 1, id: 2, parent: 1}
+	sampler.start(root.started())
+	sampler.start(child.started())
+	if got := sampler.end(child.ended(), next); len(got) != 1 {
+		t.Errorf("child of an unbuffered trace released %v", spanNames(got))
+	}
+	if got := sampler.end(root.ended(), next); len(got) != 1 {
+		t.Errorf("root of an unbuffered trace released %v", spanNames(got))
+	}
+	if len(sampler.traces) != maxTailTraces {
+		t.Errorf("%d traces held, want %d", len(sampler.traces), maxTailTraces)
+	}
+}
+
+func TestTailSamplerReleasesOwnSpans(t *testing.T) {
+	sampler := newTailSampler(time.Second, 0)
+	orders, payments := tracetest.NewSpanRecorder(), tracetest.NewSpanRecorder()
+
+	root := tailTestSpan{traceID: 1, id: 1}
+	order := tailTestSpan{traceID: 1, id: 2, parent: 1}
+	payment := tailTestSpan{traceID: 1, id: 3, parent: 1}
+	for _, s := range []tailTestSpan{root, order, payment} {
+		sampler.start(s.started())
+	}
+	sampler.end(order.ended(), orders)
+	sampler.end(payment.ended(), payments)
+
+	if got := spanNames(sampler.release(orders)); len(got) != 1 || got[0] != "b" {
+		t.Errorf("released %v for the order service, want [b]", got)
+	}
+	if got := spanNames(sampler.release(orders)); len(got) != 0 {
+		t.Errorf("released %v twice", got)
+	}
+	if got := spanNames(sampler.release(payments)); len(got) != 1 || got[0] != "c" {
+		t.Errorf("released %v for the payment service, want [c]", got)
+	}
+}
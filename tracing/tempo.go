@@ -1,24 +1,24 @@
 package tracing
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"time"
 )
 
 // TempoSpan represents a span from Tempo
 type TempoSpan struct {
-	TraceID       string                 `json:"traceID"`
-	SpanID        string                 `json:"spanID"`
-	OperationName string                 `json:"operationName"`
-	StartTime     int64                  `json:"startTime"`
-	Duration      int64                  `json:"duration"`
-	Tags          []TempoTag             `json:"tags"`
-	References    []TempoReference       `json:"references"`
-	Process       TempoProcess           `json:"process"`
+	TraceID       string           `json:"traceID"`
+	SpanID        string           `json:"spanID"`
+	OperationName string           `json:"operationName"`
+	StartTime     int64            `json:"startTime"`
+	Duration      int64            `json:"duration"`
+	Tags          []TempoTag       `json:"tags"`
+	Logs          []TempoLog       `json:"logs,omitempty"`
+	References    []TempoReference `json:"references"`
+	Process       TempoProcess     `json:"process"`
 }
 
 // TempoTag represents a tag/attribute in a span
@@ -28,11 +28,18 @@ type TempoTag struct {
 	Value interface{} `json:"value"`
 }
 
+// TempoLog represents a timestamped log (OTLP span event) in a span
+type TempoLog struct {
+	Timestamp int64      `json:"timestamp"` // microseconds
+	Fields    []TempoTag `json:"fields"`
+}
+
 // TempoReference represents a reference to another span
 type TempoReference struct {
-	RefType string `json:"refType"`
-	TraceID string `json:"traceID"`
-	SpanID  string `json:"spanID"`
+	RefType string     `json:"refType"`
+	TraceID string     `json:"traceID"`
+	SpanID  string     `json:"spanID"`
+	Tags    []TempoTag `json:"tags,omitempty"` // Attributes of a span link
 }
 
 // TempoProcess represents the process information
@@ -43,8 +50,8 @@ type TempoProcess struct {
 
 // TempoTrace represents a complete trace from Tempo
 type TempoTrace struct {
-	TraceID   string       `json:"traceID"`
-	Spans     []TempoSpan  `json:"spans"`
+	TraceID   string         `json:"traceID"`
+	Spans     []TempoSpan    `json:"spans"`
 	Processes []TempoProcess `json:"processes"`
 }
 
@@ -53,56 +60,6 @@ type TempoResponse struct {
 	Data []TempoTrace `json:"data"`
 }
 
-// OTLP format structures
-type OTLPBatch struct {
-	Resource   OTLPResource    `json:"resource"`
-	ScopeSpans []OTLPScopeSpan `json:"scopeSpans"`
-}
-
-type OTLPResource struct {
-	Attributes []OTLPAttribute `json:"attributes"`
-}
-
-type OTLPScopeSpan struct {
-	Scope OTLPScope  `json:"scope"`
-	Spans []OTLPSpan `json:"spans"`
-}
-
-type OTLPScope struct {
-	Name string `json:"name"`
-}
-
-type OTLPSpan struct {
-	TraceID           string          `json:"traceId"`
-	SpanID            string          `json:"spanId"`
-	ParentSpanID      string          `json:"parentSpanId,omitempty"`
-	Name              string          `json:"name"`
-	Kind              string          `json:"kind"` // Changed from int to string
-	StartTimeUnixNano string          `json:"startTimeUnixNano"`
-	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
-	Attributes        []OTLPAttribute `json:"attributes"`
-	Status            OTLPStatus      `json:"status"`
-}
-
-type OTLPAttribute struct {
-	Key   string     `json:"key"`
-	Value OTLPValue  `json:"value"`
-}
-
-type OTLPValue struct {
-	StringValue string `json:"stringValue,omitempty"`
-	IntValue    string `json:"intValue,omitempty"`
-	BoolValue   bool   `json:"boolValue,omitempty"`
-}
-
-type OTLPStatus struct {
-	Code string `json:"code"` // Changed from int to string
-}
-
-type OTLPTrace struct {
-	Batches []OTLPBatch `json:"batches"`
-}
-
 // GetTempoURL returns the Tempo query URL from environment or default
 func GetTempoURL() string {
 	url := os.Getenv("TEMPO_URL")
@@ -112,37 +69,23 @@ func GetTempoURL() string {
 	return url
 }
 
-// QueryTraceByID queries Tempo for a trace by trace ID
-func QueryTraceByID(traceID string) (*TempoTrace, error) {
-	tempoURL := GetTempoURL()
-	url := fmt.Sprintf("%s/api/traces/%s", tempoURL, traceID)
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query Tempo: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Tempo returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	body, err := io.ReadAll(resp.Body)
+// QueryTraceByID queries Tempo for a trace by trace ID using the client configured from the environment.
+// Polling stops as soon as ctx is done, e.g. when the HTTP caller disconnects.
+func QueryTraceByID(ctx context.Context, traceID string) (*TempoTrace, error) {
+	client, err := DefaultTempoClient()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("invalid Tempo client configuration: %w", err)
 	}
+	return client.QueryTrace(ctx, traceID)
+}
 
+// parseTraceJSON parses a JSON trace in OTLP or Jaeger format
+func parseTraceJSON(body []byte, traceID string) (*TempoTrace, error) {
 	// Try to parse as OTLP format first
 	var otlpTrace OTLPTrace
-	if err := json.Unmarshal(body, &otlpTrace); err == nil && len(otlpTrace.Batches) > 0 {
+	if err := json.Unmarshal(body, &otlpTrace); err == nil && len(otlpTrace.allBatches()) > 0 {
 		// Convert OTLP format to Jaeger format
 		return convertOTLPToJaeger(&otlpTrace, traceID)
-	} else {
 	}
 
 	// Fallback to Jaeger format
@@ -151,88 +94,27 @@ func QueryTraceByID(traceID string) (*TempoTrace, error) {
 		return nil, fmt.Errorf("failed to parse Tempo response: %w", err)
 	}
 
+	normalizeJaegerIDs(&trace)
 	return &trace, nil
 }
 
-// convertOTLPToJaeger converts OTLP trace format to Jaeger format
-func convertOTLPToJaeger(otlp *OTLPTrace, traceID string) (*TempoTrace, error) {
-	
-	trace := &TempoTrace{
-		TraceID: traceID,
-		Spans:   make([]TempoSpan, 0),
-	}
-
-	// Extract service name from resource attributes
-	serviceName := "unknown-service"
-	if len(otlp.Batches) > 0 && len(otlp.Batches[0].Resource.Attributes) > 0 {
-		for _, attr := range otlp.Batches[0].Resource.Attributes {
-			if attr.Key == "service.name" {
-				serviceName = attr.Value.StringValue
-				break
-			}
-		}
-	}
-
-	// Convert spans
-	spanCount := 0
-	for _, batch := range otlp.Batches {
-		for _, scopeSpan := range batch.ScopeSpans {
-			for _, otlpSpan := range scopeSpan.Spans {
-				spanCount++
-				// Calculate duration
-				var startTime, endTime int64
-				fmt.Sscanf(otlpSpan.StartTimeUnixNano, "%d", &startTime)
-				fmt.Sscanf(otlpSpan.EndTimeUnixNano, "%d", &endTime)
-				durationMicros := (endTime - startTime) / 1000 // Convert nanoseconds to microseconds
-
-				// Convert attributes to tags
-				tags := make([]TempoTag, 0, len(otlpSpan.Attributes))
-				for _, attr := range otlpSpan.Attributes {
-					value := attr.Value.StringValue
-					if value == "" && attr.Value.IntValue != "" {
-						value = attr.Value.IntValue
-					}
-					tags = append(tags, TempoTag{
-						Key:   attr.Key,
-						Type:  "string",
-						Value: value,
-					})
-				}
-
-				// Convert references - keep base64 format for parent span ID
-				references := make([]TempoReference, 0)
-				if otlpSpan.ParentSpanID != "" {
-					references = append(references, TempoReference{
-						RefType: "CHILD_OF",
-						TraceID: traceID,
-						SpanID:  otlpSpan.ParentSpanID, // Keep base64 format
-					})
-				}
-
-				// Keep the original base64 span ID from OTLP
-				span := TempoSpan{
-					TraceID:       traceID,
-					SpanID:        otlpSpan.SpanID, // Keep base64 format
-					OperationName: otlpSpan.Name,
-					StartTime:     startTime / 1000, // Convert to microseconds
-					Duration:      durationMicros,
-					Tags:          tags,
-					References:    references,
-					Process: TempoProcess{
-						ServiceName: serviceName,
-					},
-				}
-
-				trace.Spans = append(trace.Spans, span)
-			}
+// normalizeJaegerIDs normalizes the IDs of a Jaeger format trace to zero-padded lower-case hex
+func normalizeJaegerIDs(trace *TempoTrace) {
+	trace.TraceID = NormalizeTraceID(trace.TraceID)
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		span.TraceID = NormalizeTraceID(span.TraceID)
+		span.SpanID = NormalizeSpanID(span.SpanID)
+		for j := range span.References {
+			span.References[j].TraceID = NormalizeTraceID(span.References[j].TraceID)
+			span.References[j].SpanID = NormalizeSpanID(span.References[j].SpanID)
 		}
 	}
-
-	return trace, nil
 }
 
-// FindSpanByID finds a specific span in a trace by span ID
+// FindSpanByID finds a specific span in a trace by span ID, given in hex or base64 form
 func FindSpanByID(trace *TempoTrace, spanID string) *TempoSpan {
+	spanID = NormalizeSpanID(spanID)
 	for i := range trace.Spans {
 		if trace.Spans[i].SpanID == spanID {
 			return &trace.Spans[i]
@@ -243,6 +125,7 @@ func FindSpanByID(trace *TempoTrace, spanID string) *TempoSpan {
 
 // FindChildSpans finds all child spans of a given span
 func FindChildSpans(trace *TempoTrace, parentSpanID string) []TempoSpan {
+	parentSpanID = NormalizeSpanID(parentSpanID)
 	var children []TempoSpan
 	for _, span := range trace.Spans {
 		for _, ref := range span.References {
@@ -264,6 +147,85 @@ func GetSpanAttributes(span *TempoSpan) map[string]string {
 	return attrs
 }
 
+// SpanEvent represents a span event, recorded as a log of the span
+type SpanEvent struct {
+	Name       string
+	Timestamp  int64 // microseconds
+	Attributes map[string]string
+}
+
+// SpanException represents an exception event recorded by RecordError
+type SpanException struct {
+	Type       string
+	Message    string
+	StackTrace string
+	Timestamp  int64 // microseconds
+}
+
+// SpanLink represents a link from a span to a span in the same or another trace
+type SpanLink struct {
+	TraceID    string
+	SpanID     string
+	Attributes map[string]string
+}
+
+// GetSpanEvents extracts the events of a span, including exceptions, in recording order
+func GetSpanEvents(span *TempoSpan) []SpanEvent {
+	events := make([]SpanEvent, 0, len(span.Logs))
+	for _, log := range span.Logs {
+		event := SpanEvent{
+			Timestamp:  log.Timestamp,
+			Attributes: make(map[string]string, len(log.Fields)),
+		}
+		for _, field := range log.Fields {
+			if field.Key == "event" {
+				event.Name = fmt.Sprintf("%v", field.Value)
+				continue
+			}
+			event.Attributes[field.Key] = fmt.Sprintf("%v", field.Value)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// GetSpanExceptions extracts the exception events of a span
+func GetSpanExceptions(span *TempoSpan) []SpanException {
+	exceptions := make([]SpanException, 0)
+	for _, event := range GetSpanEvents(span) {
+		if event.Name != "exception" {
+			continue
+		}
+		exceptions = append(exceptions, SpanException{
+			Type:       event.Attributes["exception.type"],
+			Message:    event.Attributes["exception.message"],
+			StackTrace: event.Attributes["exception.stacktrace"],
+			Timestamp:  event.Timestamp,
+		})
+	}
+	return exceptions
+}
+
+// GetSpanLinks extracts the links of a span from its FOLLOWS_FROM references
+func GetSpanLinks(span *TempoSpan) []SpanLink {
+	links := make([]SpanLink, 0)
+	for _, ref := range span.References {
+		if ref.RefType != "FOLLOWS_FROM" {
+			continue
+		}
+		link := SpanLink{
+			TraceID:    ref.TraceID,
+			SpanID:     ref.SpanID,
+			Attributes: make(map[string]string, len(ref.Tags)),
+		}
+		for _, tag := range ref.Tags {
+			link.Attributes[tag.Key] = fmt.Sprintf("%v", tag.Value)
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
 // FormatDuration formats duration in microseconds to human-readable format
 func FormatDuration(durationMicros int64) string {
 	duration := time.Duration(durationMicros) * time.Microsecond
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"tempo-otlp-trace-demo/clock"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// virtualTimeProvider hands out tracers that timestamp spans with the virtual clock
+// of the request, if it has one, instead of the wall clock
+type virtualTimeProvider struct {
+	trace.TracerProvider
+}
+
+func (p virtualTimeProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return virtualTimeTracer{Tracer: p.TracerProvider.Tracer(name, opts...)}
+}
+
+type virtualTimeTracer struct {
+	trace.Tracer
+}
+
+// Start starts the span at the virtual time of ctx; an explicit trace.WithTimestamp still wins
+func (t virtualTimeTracer) Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	c, ok := clock.FromContext(ctx)
+	if !ok {
+		return t.Tracer.Start(ctx, spanName, opts...)
+	}
+
+	opts = append([]trace.SpanStartOption{trace.WithTimestamp(c.Now())}, opts...)
+	ctx, span := t.Tracer.Start(ctx, spanName, opts...)
+	virtual := virtualTimeSpan{Span: span, clock: c}
+	return trace.ContextWithSpan(ctx, virtual), virtual
+}
+
+// virtualTimeSpan ends and records events at the virtual time of the clock it was started on
+type virtualTimeSpan struct {
+	trace.Span
+	clock *clock.Clock
+}
+
+func (s virtualTimeSpan) End(opts ...trace.SpanEndOption) {
+	s.Span.End(append([]trace.SpanEndOption{trace.WithTimestamp(s.clock.Now())}, opts...)...)
+}
+
+func (s virtualTimeSpan) AddEvent(name string, opts ...trace.EventOption) {
+	s.Span.AddEvent(name, append([]trace.EventOption{trace.WithTimestamp(s.clock.Now())}, opts...)...)
+}
+
+func (s virtualTimeSpan) RecordError(err error, opts ...trace.EventOption) {
+	s.Span.RecordError(err, append([]trace.EventOption{trace.WithTimestamp(s.clock.Now())}, opts...)...)
+}